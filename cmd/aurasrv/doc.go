@@ -1,7 +1,7 @@
 /*
-Package aurasrv represents standalone RPC server for maintain a distributed counter.
+Package aurasrv represents standalone RPC server for maintain distributed counters.
 
-Server provides simple API for incrementing and getting counter value.
+Server provides simple API for incrementing and getting values of counters addressed by ID.
 
 */
 package main
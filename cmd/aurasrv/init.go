@@ -21,7 +21,9 @@ var (
 
 func init() {
 	var err error
-	usage := "aurasrv\nStarts REST HTTP server to maintain distributed counter.\n"
+	usage := "aurasrv [options] [migrate [-to version] [-dry-run] [-status]]\n" +
+		"Starts REST HTTP (and gRPC) server to maintain distributed counters.\n" +
+		"With `migrate` subcommand migrates schema of mysql database (up to the latest version by default) and exits.\n"
 	envFile := ""
 	help := false
 	flag.StringVar(
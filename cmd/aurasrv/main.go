@@ -1,6 +1,8 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
@@ -9,15 +11,26 @@ import (
 
 	"github.com/wtask-go/auracounter/pkg/logging"
 
+	"github.com/wtask-go/auracounter/internal/httpcore/jsonrpc"
 	"github.com/wtask-go/auracounter/internal/httpcore/rest"
+	"github.com/wtask-go/auracounter/internal/httpcore/ws"
+	"github.com/wtask-go/auracounter/internal/memcore"
+	"github.com/wtask-go/auracounter/internal/outbox"
+	"github.com/wtask-go/auracounter/internal/respcore"
+	"github.com/wtask-go/auracounter/internal/tcpcore"
 
 	"github.com/wtask-go/auracounter/internal/api"
 
 	"github.com/wtask-go/auracounter/internal/config"
 
 	"github.com/wtask-go/auracounter/internal/counter"
+	"github.com/wtask-go/auracounter/internal/counter/datastore/file"
+	"github.com/wtask-go/auracounter/internal/counter/datastore/memory"
 	"github.com/wtask-go/auracounter/internal/counter/datastore/mysql"
+	"github.com/wtask-go/auracounter/internal/counter/datastore/postgres"
+	"github.com/wtask-go/auracounter/internal/counter/datastore/sqlite"
 
+	"github.com/wtask-go/auracounter/internal/grpccore"
 	"github.com/wtask-go/auracounter/internal/httpcore"
 )
 
@@ -28,7 +41,7 @@ func main() {
 		os.Exit(exitCode)
 	}()
 
-	logger := logging.NewStdout(logging.WithDefaultDecoration("aurasrv", nil))
+	logger := loggerFactory(conf)
 	defer logger.Close()
 
 	logger.Infof("Initialization started ...")
@@ -41,24 +54,61 @@ func main() {
 	}
 	defer storage.Close()
 
+	if flag.Arg(0) == migrateCommand {
+		exitCode = runMigrate(flag.Args()[1:], storage, logger)
+		return
+	}
+
 	if err = storage.EnsureLatest(); err != nil {
 		logger.Errorf("Can't ensure storage has latest version: %v", err)
 		exitCode = 1
 		return
 	}
 
-	service, err := counter.NewCyclicCounterService(conf.CounterID, storage.Repository())
+	auditLog, err := auditFactory(conf, storage)
+	if err != nil {
+		logger.Errorf("Can't initialize audit log: %v", err)
+		exitCode = 1
+		return
+	}
+	audit := counter.WithAuditLog(auditLog, func(record *counter.AuditRecord, err error) {
+		logger.Errorf("Counter #%d %s audit failed: %v", record.CounterID, record.Operation, err)
+	})
+	if auditLog == nil {
+		// audit is disabled
+		audit = nil
+	}
+
+	events := counter.NewEventBus()
+	service, err := counter.NewCyclicCounterService(
+		storage.Repository(),
+		counter.WithWrapHook(func(counterID int, state counter.State) {
+			logger.Infof("Counter #%d wrapped %d time(s), value %d, cycle %d", counterID, state.Wraps, state.Value, state.Cycle)
+		}),
+		audit,
+		counter.WithIdempotencyTTL(conf.CounterIdempotencyTTL),
+		counter.WithEventBus(events),
+	)
 	if err != nil {
 		logger.Errorf("Can't initialize counter service: %v", err)
 		exitCode = 1
 		return
 	}
 
-	logger.Infof("Initialization done, server is starting ...")
+	relay, err := relayFactory(conf, storage, logger)
+	if err != nil {
+		logger.Errorf("Can't initialize outbox relay: %v", err)
+		exitCode = 1
+		return
+	}
+	if relay != nil {
+		relay.Start()
+		logger.Infof("Outbox relay is delivering events to %s", conf.CounterOutbox.Sink)
+	}
 
-	server := newRESTServer(conf, service, logger)
+	logger.Infof("Initialization done, server is starting ...")
 
-	shutdown, err := httpcore.LaunchServer(server, 3*time.Second)
+	shutdown, err := launchServers(conf, service, events, logger)
 	if err != nil {
 		logger.Errorf("Can't launch server: %v", err)
 		exitCode = 1
@@ -66,27 +116,199 @@ func main() {
 	}
 	logger.Infof("Server is ready!")
 
-	sig := make(chan os.Signal)
+	sig := make(chan os.Signal, 1)
 	signal.Notify(sig, os.Interrupt)
 	<-sig
+	// event streams are finished before shutdown of servers
+	events.Close()
 	if err := shutdown(10 * time.Second); err != nil {
 		logger.Errorf("Server shutdown failed: %v", err)
 		exitCode = 2
 	}
+	if relay != nil {
+		// pending events are delivered after restart
+		if err := relay.Stop(10 * time.Second); err != nil {
+			logger.Errorf("Outbox relay stop failed: %v", err)
+			exitCode = 2
+		}
+	}
 	logger.Infof("Server has stopped, bye ( ᴗ_ ᴗ)")
 }
 
+// loggerFactory - builds logger of the server, it writes to stderr when stdout is taken by outbox events.
+func loggerFactory(cfg *config.Application) logging.Interface {
+	decoration := logging.WithDefaultDecoration("aurasrv", nil)
+	if cfg.CounterOutbox.Sink == config.StdoutOutbox {
+		return logging.NewStderr(decoration)
+	}
+	return logging.NewStdout(decoration)
+}
+
 func storageFactory(cfg *config.Application) (counter.Storage, error) {
-	return mysql.NewStorage(cfg.CounterDB.DSN(), mysql.WithTablePrefix(cfg.CounterDB.TablePrefix))
+	switch cfg.CounterDB.Type {
+	case config.MemoryDatabase:
+		return memory.NewStorage(), nil
+	case config.SQLiteDatabase:
+		return sqlite.NewStorage(cfg.CounterDB.DSN(), sqlite.WithTablePrefix(cfg.CounterDB.TablePrefix))
+	case config.FileDatabase:
+		return file.NewStorage(cfg.CounterDB.DSN())
+	case config.MySQLDatabase:
+		withOutbox := mysql.WithOutbox()
+		if cfg.CounterOutbox.Sink == "" || cfg.CounterOutbox.Sink == config.NoOutbox {
+			// changes are not recorded
+			withOutbox = nil
+		}
+		return mysql.NewStorage(cfg.CounterDB.DSN(), mysql.WithTablePrefix(cfg.CounterDB.TablePrefix), withOutbox)
+	case config.PostgresDatabase:
+		return postgres.NewStorage(cfg.CounterDB.DSN(), postgres.WithTablePrefix(cfg.CounterDB.TablePrefix))
+	default:
+		return nil, fmt.Errorf("unsupported database type %q", cfg.CounterDB.Type)
+	}
+}
+
+func auditFactory(cfg *config.Application, storage counter.Storage) (counter.AuditLog, error) {
+	switch cfg.CounterAudit {
+	case "", config.NoAudit:
+		return nil, nil
+	case config.MemoryAudit:
+		return memory.NewAuditLog(), nil
+	case config.DatabaseAudit:
+		auditStorage, ok := storage.(counter.AuditStorage)
+		if !ok {
+			return nil, fmt.Errorf("database type %q does not support audit", cfg.CounterDB.Type)
+		}
+		return auditStorage.AuditLog(), nil
+	default:
+		return nil, fmt.Errorf("unsupported audit type %q", cfg.CounterAudit)
+	}
+}
+
+// relayFactory - builds relay of outbox events to configured sink, returns nil relay when outbox is disabled.
+func relayFactory(cfg *config.Application, storage counter.Storage, logger logging.Facade) (*outbox.Relay, error) {
+	var sink outbox.Sink
+	switch cfg.CounterOutbox.Sink {
+	case "", config.NoOutbox:
+		return nil, nil
+	case config.WebhookOutbox:
+		s, err := outbox.NewWebhookSink(cfg.CounterOutbox.Target, 10*time.Second)
+		if err != nil {
+			return nil, err
+		}
+		sink = s
+	case config.FileOutbox:
+		s, err := outbox.NewFileSink(cfg.CounterOutbox.Target)
+		if err != nil {
+			return nil, err
+		}
+		sink = s
+	case config.StdoutOutbox:
+		sink = outbox.NewWriterSink(os.Stdout)
+	default:
+		return nil, fmt.Errorf("unsupported outbox sink %q", cfg.CounterOutbox.Sink)
+	}
+	outboxStorage, ok := storage.(counter.OutboxStorage)
+	if !ok {
+		return nil, fmt.Errorf("database type %q does not support outbox", cfg.CounterDB.Type)
+	}
+	return outbox.NewRelay(outboxStorage.Outbox(), sink, logger)
+}
+
+// launchServers - starts all enabled servers in background,
+// returns the function to shutdown all of them or startup error.
+func launchServers(
+	cfg *config.Application,
+	service api.CyclicCounterService,
+	events api.CounterEventSource,
+	logger logging.Facade,
+) (func(time.Duration) error, error) {
+	shutdowns := []func(time.Duration) error{}
+	shutdown := func(timeout time.Duration) error {
+		var failed error
+		for _, s := range shutdowns {
+			if err := s(timeout); err != nil {
+				failed = err
+			}
+		}
+		return failed
+	}
+	if cfg.CounterREST.Port != 0 {
+		s, err := httpcore.LaunchServer(newRESTServer(cfg, service, events, logger), 3*time.Second)
+		if err != nil {
+			shutdown(time.Second)
+			return nil, fmt.Errorf("REST server: %v", err)
+		}
+		shutdowns = append(shutdowns, s)
+		logger.Infof("REST server is listening %s:%d", cfg.CounterREST.Host, cfg.CounterREST.Port)
+	}
+	if cfg.CounterGRPC.Port != 0 {
+		address := fmt.Sprintf("%s:%d", cfg.CounterGRPC.Host, cfg.CounterGRPC.Port)
+		s, err := grpccore.LaunchServer(grpccore.NewCounterServer(service, logger), address, 3*time.Second)
+		if err != nil {
+			shutdown(time.Second)
+			return nil, fmt.Errorf("gRPC server: %v", err)
+		}
+		shutdowns = append(shutdowns, s)
+		logger.Infof("gRPC server is listening %s", address)
+	}
+	if cfg.CounterRESP.Port != 0 {
+		server := &tcpcore.Server{
+			Addr:    fmt.Sprintf("%s:%d", cfg.CounterRESP.Host, cfg.CounterRESP.Port),
+			Handler: respcore.NewCounterHandler(service, logger),
+		}
+		s, err := tcpcore.LaunchServer(server)
+		if err != nil {
+			shutdown(time.Second)
+			return nil, fmt.Errorf("RESP server: %v", err)
+		}
+		shutdowns = append(shutdowns, s)
+		logger.Infof("RESP server is listening %s", server.Addr)
+	}
+	if cfg.CounterMemcache.Port != 0 {
+		server := &tcpcore.Server{
+			Addr:    fmt.Sprintf("%s:%d", cfg.CounterMemcache.Host, cfg.CounterMemcache.Port),
+			Handler: memcore.NewCounterHandler(service, logger),
+		}
+		s, err := tcpcore.LaunchServer(server)
+		if err != nil {
+			shutdown(time.Second)
+			return nil, fmt.Errorf("memcached server: %v", err)
+		}
+		shutdowns = append(shutdowns, s)
+		logger.Infof("memcached server is listening %s", server.Addr)
+	}
+	if len(shutdowns) == 0 {
+		return nil, errors.New("all servers are disabled")
+	}
+	return shutdown, nil
 }
 
-func newRESTServer(cfg *config.Application, service api.CyclicCounterService, logger logging.Facade) *http.Server {
+// newRESTServer - builds HTTP server of REST API, JSON-RPC API is mounted under `rpc/`
+// and WebSocket API under `ws/` of the same base URI.
+// Write timeout is applied per request with httpcore.WriteDeadlines, so event streams extend it before every write
+// and are closed after their lifetime only, clients reconnect to continue.
+func newRESTServer(
+	cfg *config.Application,
+	service api.CyclicCounterService,
+	events api.CounterEventSource,
+	logger logging.Facade,
+) *http.Server {
+	deadlines := httpcore.NewWriteDeadlines(10 * time.Second)
+	handler := http.NewServeMux()
+	handler.Handle(cfg.CounterREST.BaseURI+"rpc/", jsonrpc.NewCounterHandler(service, logger))
+	handler.Handle(cfg.CounterREST.BaseURI+"ws/", ws.NewCounterHandler(service, events, logger))
+	handler.Handle("/", rest.NewCounterHandler(
+		cfg.CounterREST.BaseURI,
+		service,
+		logger,
+		rest.WithEventStream(events, 5*time.Minute),
+	))
 	return &http.Server{
 		Addr:    fmt.Sprintf("%s:%d", cfg.CounterREST.Host, cfg.CounterREST.Port),
-		Handler: rest.NewCounterHandler(cfg.CounterREST.BaseURI, service, logger),
+		Handler: deadlines.Handler(handler),
 		// ErrorLog:     l,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  15 * time.Second,
+		ReadTimeout: 5 * time.Second,
+		// WriteTimeout is zero, see deadlines
+		ConnState:   deadlines.ConnState,
+		IdleTimeout: 15 * time.Second,
 	}
 }
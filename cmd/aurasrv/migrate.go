@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/wtask-go/auracounter/internal/counter"
+	"github.com/wtask-go/auracounter/internal/counter/datastore/mysql"
+	"github.com/wtask-go/auracounter/pkg/logging"
+)
+
+// migrateCommand - name of subcommand to manage versioned schema of database
+const migrateCommand = "migrate"
+
+// runMigrate - executes `migrate` subcommand with given arguments and returns exit code.
+// Without arguments the schema is migrated up to the latest version.
+// Versioned schema is supported for mysql database only.
+func runMigrate(args []string, storage counter.Storage, logger logging.Facade) int {
+	commands := flag.NewFlagSet(migrateCommand, flag.ContinueOnError)
+	target := commands.Int("to", mysql.LatestSchemaVersion(), "Target schema version, 0 reverts all migrations.")
+	dryRun := commands.Bool("dry-run", false, "Prints planned SQL statements without changing the database.")
+	status := commands.Bool("status", false, "Prints current and the latest schema versions only.")
+	if err := commands.Parse(args); err != nil {
+		return 1
+	}
+
+	migrator, ok := storage.(mysql.Migrator)
+	if !ok {
+		logger.Errorf("Versioned schema is not supported by %q database", conf.CounterDB.Type)
+		return 1
+	}
+	current, err := migrator.SchemaVersion()
+	if err != nil {
+		logger.Errorf("Can't get schema version: %v", err)
+		return 1
+	}
+	logger.Infof("Schema version is %d, the latest version is %d", current, mysql.LatestSchemaVersion())
+	if *status {
+		return 0
+	}
+
+	steps, err := migrator.Migrate(*target, *dryRun)
+	for _, step := range steps {
+		direction := "up"
+		if !step.Up {
+			direction = "down"
+		}
+		logger.Infof("Version %d %s: %s", step.Version, direction, step.Description)
+		for _, statement := range step.Statements {
+			logger.Infof("  %s;", statement)
+		}
+	}
+	if err != nil {
+		logger.Errorf("Migration failed: %v", err)
+		return 1
+	}
+	switch {
+	case len(steps) == 0:
+		logger.Infof("Schema is already at version %d", *target)
+	case *dryRun:
+		logger.Infof("Dry run, %d step(s) are planned, the database was not changed", len(steps))
+	default:
+		logger.Infof("Schema was migrated to version %d", *target)
+	}
+	return 0
+}
@@ -1,21 +1,180 @@
 package api
 
-// CyclicCounterService - represents interface for manage cyclic incremental counter.
+import "time"
+
+// CyclicCounterService - represents interface for manage cyclic incremental counters.
+// Every counter is addressed by its own ID, so single service is able to maintain a lot of counters.
 type CyclicCounterService interface {
-	// GetCounterValue - get current counter value
-	GetCounterValue() (*IntValueResult, *Error)
+	// GetCounterValue - get current value of counter with given ID
+	GetCounterValue(counterID int) (*IntValueResult, *Error)
 	// IncreaseCounter - increase counter by increment, which set with settings and return new counter value.
-	IncreaseCounter() (*IntValueResult, *Error)
-	// SetCounterSettings - set the new settings for counter atomically
-	SetCounterSettings(increment, lower, upper int) (*OKResult, *Error)
+	IncreaseCounter(counterID int) (*IntValueResult, *Error)
+	// IncreaseCounterOnce - increase counter once per idempotency key and return new counter value.
+	// Retry with the same key returns the same result with `Replayed` flag until the key expires.
+	IncreaseCounterOnce(counterID int, idempotencyKey string) (*IntValueResult, *Error)
+	// DecreaseCounter - decrease counter by increment and return new counter value.
+	// Counter wraps from lower boundary to upper boundary (descending counter wraps from upper boundary to lower one).
+	DecreaseCounter(counterID int) (*IntValueResult, *Error)
+	// DecreaseCounterBy - decrease counter `size` times at once and return new counter value,
+	// so it reverts the block of the same size. The counter is not changed when any decrease fails.
+	DecreaseCounterBy(counterID, size int) (*IntValueResult, *Error)
+	// ResetCounter - return counter to its start value and return it.
+	ResetCounter(counterID int) (*IntValueResult, *Error)
+	// ReserveCounterBlock - increase counter `size` times at once and return block of passed values.
+	// Block consists of several ranges when counter wraps within it.
+	ReserveCounterBlock(counterID, size int) (*BlockResult, *Error)
+	// SetCounterSettings - set the new settings for counter atomically.
+	// When `version` is not zero, settings are changed only if it is equal to current version of settings,
+	// otherwise error with VersionMismatchCode is returned.
+	SetCounterSettings(counterID int, increment, lower, upper int64, version int) (*SettingsResult, *Error)
+	// GetCounterSettings - get current settings of counter and their version
+	GetCounterSettings(counterID int) (*CounterSettings, *Error)
+	// UpdateCounterSettings - set the new settings for counter atomically,
+	// current counter value is changed according to `valueMode` (see PreserveValue, ClampValue, ResetValue).
+	// Empty mode is the same as PreserveValue. Non-zero `version` is checked as for SetCounterSettings.
+	UpdateCounterSettings(counterID int, settings *CounterSettings, valueMode string, version int) (*SettingsResult, *Error)
+	// GetCounterAudit - get up to `limit` audit records of counter with ID greater than `after`.
+	GetCounterAudit(counterID int, after int64, limit int) (*AuditPage, *Error)
+	// WithCaller - return the service which acts on behalf of given caller,
+	// caller is written into audit records of counter changes.
+	WithCaller(caller Caller) CyclicCounterService
+}
+
+// Caller - identity of the client and its request
+type Caller struct {
+	RequestID string
+	Client    string
 }
 
-// IntValueResult - struct to return int value
+// Modes of changing counter value when new settings are applied
+const (
+	// PreserveValue - current value is kept as is
+	PreserveValue = "preserve"
+	// ClampValue - current value is moved to the nearest boundary of the new range when it is out of the range
+	ClampValue = "clamp"
+	// ResetValue - current value is replaced with the new start value
+	ResetValue = "reset"
+)
+
+// Overflow policies of counter
+const (
+	// WrapOverflow - counter wraps to the opposite boundary
+	WrapOverflow = "wrap"
+	// SaturateOverflow - counter stops at the exceeded boundary
+	SaturateOverflow = "saturate"
+	// FailOverflow - counter is not changed and error with CounterOverflowCode is returned
+	FailOverflow = "fail"
+)
+
+// CounterSettings - struct to pass and return counter settings.
+// Empty overflow policy is the same as WrapOverflow.
+// `Version` is returned only, it is increased by every change of settings.
+type CounterSettings struct {
+	Increment int64  `json:"increment"`
+	Lower     int64  `json:"lower"`
+	Upper     int64  `json:"upper"`
+	StartFrom int64  `json:"start_from"`
+	Overflow  string `json:"overflow"`
+	Version   int    `json:"version,omitempty"`
+}
+
+// IntValueResult - struct to return int64 value.
+// For counters `Cycle` is the number of wraps since the counter was created
+// and `Wrapped` is set when the counter wrapped during the call.
+// `Replayed` is set when the result of previous call with the same idempotency key is returned.
 type IntValueResult struct {
-	Value int `json:"value"`
+	Value    int64 `json:"value"`
+	Cycle    int   `json:"cycle,omitempty"`
+	Wrapped  bool  `json:"wrapped,omitempty"`
+	Replayed bool  `json:"replayed,omitempty"`
+}
+
+// BlockResult - struct to return block of reserved values,
+// `Cycle` and `Wrapped` describe the counter state after reservation (see IntValueResult).
+type BlockResult struct {
+	Ranges  []IntRange `json:"ranges"`
+	Cycle   int        `json:"cycle,omitempty"`
+	Wrapped bool       `json:"wrapped,omitempty"`
+}
+
+// IntRange - struct to return arithmetic progression of int64 values
+type IntRange struct {
+	First int64 `json:"first"`
+	Last  int64 `json:"last"`
+	Step  int64 `json:"step"`
+	Count int   `json:"count"`
+}
+
+// SettingsResult - struct to return flag of success and new version of settings
+type SettingsResult struct {
+	OK      bool `json:"ok"`
+	Version int  `json:"version"`
+}
+
+// AuditRecord - struct to return single change of counter.
+// Settings are returned for settings change only, `OldSettings` is omitted when the counter was created.
+type AuditRecord struct {
+	ID          int64            `json:"id"`
+	Time        time.Time        `json:"time"`
+	Operation   string           `json:"operation"`
+	OldValue    int64            `json:"old_value"`
+	NewValue    int64            `json:"new_value"`
+	OldSettings *CounterSettings `json:"old_settings,omitempty"`
+	NewSettings *CounterSettings `json:"new_settings,omitempty"`
+	RequestID   string           `json:"request_id,omitempty"`
+	Client      string           `json:"client,omitempty"`
+}
+
+// AuditPage - struct to return page of audit records,
+// `Next` is the value of `after` to request the next page, it is omitted for the last page.
+type AuditPage struct {
+	Records []AuditRecord `json:"records"`
+	Next    int64         `json:"next,omitempty"`
+}
+
+// Types of counter events
+const (
+	// ValueEvent - value of counter was changed
+	ValueEvent = "value"
+	// SettingsEvent - settings of counter were changed, value may be changed too
+	SettingsEvent = "settings"
+	// WrapEvent - counter wrapped during the change of its value, it follows ValueEvent of the same change
+	WrapEvent = "wrap"
+)
+
+// CounterEvent - struct to notify about change of counter, which is already persisted.
+// `Operation` is the name of the changing operation (increase, decrease, reset, reserve or settings),
+// it is empty when the event describes current state of the counter, not a change.
+// `Wraps` is the number of wraps during the change (negative for decrease), it is set for WrapEvent only.
+// `Settings` are set for SettingsEvent only.
+// `ID` is the sequence number of published event, it is set by sources which implement CounterEventHistory.
+type CounterEvent struct {
+	ID        int64            `json:"id,omitempty"`
+	Type      string           `json:"type"`
+	CounterID int              `json:"counter_id"`
+	Time      time.Time        `json:"time"`
+	Operation string           `json:"operation,omitempty"`
+	Value     int64            `json:"value"`
+	Cycle     int              `json:"cycle,omitempty"`
+	Wraps     int              `json:"wraps,omitempty"`
+	Settings  *CounterSettings `json:"settings,omitempty"`
+}
+
+// CounterEventSource - represents interface to subscribe to changes of counters.
+type CounterEventSource interface {
+	// SubscribeCounterEvents - subscribe to events of counter with given ID, zero ID subscribes to all counters.
+	// Up to `buffer` events are queued for subscriber, the subscriber which does not keep up is unsubscribed.
+	// Channel of events is closed when subscriber is unsubscribed, `cancel` is called or the source is closed.
+	SubscribeCounterEvents(counterID, buffer int) (events <-chan CounterEvent, cancel func())
 }
 
-// OKResult - struct to return bool value (flag of success)
-type OKResult struct {
-	OK bool `json:"ok"`
+// CounterEventHistory - optional extension of CounterEventSource, which keeps the latest events,
+// so subscriber is able to resume the subscription without missing events after reconnect.
+type CounterEventHistory interface {
+	// ResumeCounterEvents - subscribe to events of counter as SubscribeCounterEvents does,
+	// but kept events with ID greater than `after` are queued first.
+	// `last` is the ID of the latest published event at the moment of subscription.
+	// `resumed` is false when some events after `after` are not kept anymore (or `after` is unknown),
+	// so nothing is queued and subscriber has to reload current state of the counter.
+	ResumeCounterEvents(counterID, buffer int, after int64) (events <-chan CounterEvent, cancel func(), last int64, resumed bool)
 }
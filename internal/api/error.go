@@ -4,10 +4,18 @@ import (
 	"github.com/pkg/errors"
 )
 
+// Error codes, zero code means the error is not classified
+const (
+	// CounterOverflowCode - counter can not be changed, because it exceeds the boundary and must fail on overflow
+	CounterOverflowCode = 1
+	// VersionMismatchCode - counter settings can not be changed, because they were changed since expected version
+	VersionMismatchCode = 2
+)
+
 // Error - API error representation.
 type Error struct {
-	// Code - error code, reserved
-	Code int // reserved
+	// Code - error code, see CounterOverflowCode etc.
+	Code int
 	// Message - public error message (without infrastructure details)
 	Message string
 	// Internal - complete internal error if it was
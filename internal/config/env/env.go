@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/wtask-go/auracounter/internal/config"
 
@@ -36,20 +37,90 @@ func NewApplicationConfig(prefix string) (cfg *config.Application, err error) {
 			Port:    optionalInt(p("REST_PORT"), 33333),
 			BaseURI: optionalString(p("REST_BASE_URI"), "/counter/v1/"),
 		},
-		CounterDB: config.Database{
-			Type:        "mysql",
-			Host:        requiredString(p("DB_HOST")),
-			Port:        requiredInt(p("DB_PORT")),
-			Name:        requiredString(p("DB_NAME")),
-			User:        requiredString(p("DB_USER")),
-			Password:    requiredString(p("DB_PASSWORD")),
-			Options:     optionalString(p("DB_OPTIONS"), "parseTime=true"),
-			TablePrefix: optionalString(p("DB_TABLE_PREFIX"), ""),
+		CounterGRPC: config.TCPServer{
+			Host: optionalString(p("GRPC_HOST"), ""),
+			Port: optionalInt(p("GRPC_PORT"), 0),
 		},
-		CounterID: requiredInt(p("ID")),
+		CounterRESP: config.TCPServer{
+			Host: optionalString(p("RESP_HOST"), ""),
+			Port: optionalInt(p("RESP_PORT"), 0),
+		},
+		CounterMemcache: config.TCPServer{
+			Host: optionalString(p("MEMCACHE_HOST"), ""),
+			Port: optionalInt(p("MEMCACHE_PORT"), 0),
+		},
+		CounterDB:             databaseConfig(p),
+		CounterAudit:          auditType(p),
+		CounterIdempotencyTTL: optionalDuration(p("IDEMPOTENCY_TTL"), 24*time.Hour),
+		CounterOutbox:         outboxConfig(p),
 	}, nil
 }
 
+// auditType - loads type of counter audit.
+// Parameter `p` must return complete name of var.
+func auditType(p func(name string) string) string {
+	audit := optionalString(p("AUDIT"), config.NoAudit)
+	switch audit {
+	case config.NoAudit, config.MemoryAudit, config.DatabaseAudit:
+		return audit
+	default:
+		panic(fmt.Errorf("%q has unsupported value %q", p("AUDIT"), audit))
+	}
+}
+
+// outboxConfig - loads outbox configuration depending on sink.
+// Parameter `p` must return complete name of var.
+func outboxConfig(p func(name string) string) config.Outbox {
+	outbox := config.Outbox{
+		Sink: optionalString(p("OUTBOX"), config.NoOutbox),
+	}
+	switch outbox.Sink {
+	case config.NoOutbox, config.StdoutOutbox:
+		// target is not needed
+	case config.WebhookOutbox, config.FileOutbox:
+		outbox.Target = requiredString(p("OUTBOX_TARGET"))
+	default:
+		panic(fmt.Errorf("%q has unsupported value %q", p("OUTBOX"), outbox.Sink))
+	}
+	return outbox
+}
+
+// databaseConfig - loads database configuration depending on database type.
+// Parameter `p` must return complete name of var.
+func databaseConfig(p func(name string) string) config.Database {
+	db := config.Database{
+		Type:        optionalString(p("DB_TYPE"), config.MySQLDatabase),
+		TablePrefix: optionalString(p("DB_TABLE_PREFIX"), ""),
+	}
+	switch db.Type {
+	case config.MemoryDatabase:
+		// connection params are not needed
+	case config.SQLiteDatabase:
+		db.Name = requiredString(p("DB_NAME"))
+		db.Options = optionalString(p("DB_OPTIONS"), "")
+	case config.FileDatabase:
+		db.Name = requiredString(p("DB_NAME"))
+		db.Options = optionalString(p("DB_OPTIONS"), "sync=always")
+	case config.MySQLDatabase:
+		db.Host = requiredString(p("DB_HOST"))
+		db.Port = requiredInt(p("DB_PORT"))
+		db.Name = requiredString(p("DB_NAME"))
+		db.User = requiredString(p("DB_USER"))
+		db.Password = requiredString(p("DB_PASSWORD"))
+		db.Options = optionalString(p("DB_OPTIONS"), "parseTime=true")
+	case config.PostgresDatabase:
+		db.Host = requiredString(p("DB_HOST"))
+		db.Port = requiredInt(p("DB_PORT"))
+		db.Name = requiredString(p("DB_NAME"))
+		db.User = requiredString(p("DB_USER"))
+		db.Password = requiredString(p("DB_PASSWORD"))
+		db.Options = optionalString(p("DB_OPTIONS"), "sslmode=disable")
+	default:
+		panic(fmt.Errorf("%q has unsupported value %q", p("DB_TYPE"), db.Type))
+	}
+	return db
+}
+
 // optionalString - obtain string value from environment.
 // If var is not defined returns defaults.
 func optionalString(varname, defaults string) string {
@@ -84,6 +155,23 @@ func optionalInt(varname string, defaults int) int {
 	return val
 }
 
+// optionalDuration - obtain positive duration value from environment, e.g. "24h" or "90m".
+// Panics, if var defined, but can not be converted into positive duration.
+func optionalDuration(varname string, defaults time.Duration) time.Duration {
+	str, ok := os.LookupEnv(varname)
+	if !ok {
+		return defaults
+	}
+	val, err := time.ParseDuration(str)
+	if err != nil {
+		panic(errors.Wrapf(err, "optional %q is expected as duration", varname))
+	}
+	if val <= 0 {
+		panic(fmt.Errorf("optional %q is expected as positive duration", varname))
+	}
+	return val
+}
+
 // requiredInt - obtain integer value from environment.
 // Panics, if var is not defined or can not be converted into int.
 func requiredInt(varname string) int {
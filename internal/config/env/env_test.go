@@ -5,6 +5,7 @@ import (
 	"strings"
 	"github.com/wtask-go/auracounter/internal/config"
 	"testing"
+	"time"
 	"os"
 	"github.com/joho/godotenv"
 )
@@ -52,6 +53,9 @@ func TestNewApplicationConfig(t *testing.T) {
 			"",
 			"",
 			&config.Application{
+				CounterAudit: "none",
+				CounterOutbox: config.Outbox{Sink: "none"},
+				CounterIdempotencyTTL: 24 * time.Hour,
 				CounterREST: config.HTTPServer{
 					Host: "", 
 					Port: 33333,
@@ -67,7 +71,6 @@ func TestNewApplicationConfig(t *testing.T) {
 					Options:     "parseTime=true&timeout=3m",
 					TablePrefix: "",
 				},
-				CounterID: 1,
 			},
 		},
 		{
@@ -75,6 +78,9 @@ func TestNewApplicationConfig(t *testing.T) {
 			"ENVTEST_",
 			"",
 			&config.Application{
+				CounterAudit: "none",
+				CounterOutbox: config.Outbox{Sink: "webhook", Target: "http://127.0.0.1:8080/events"},
+				CounterIdempotencyTTL: 24 * time.Hour,
 				CounterREST: config.HTTPServer{
 					Host: "", 
 					Port: 33333,
@@ -90,15 +96,156 @@ func TestNewApplicationConfig(t *testing.T) {
 					Options:     "parseTime=true&timeout=3m",
 					TablePrefix: "",
 				},
-				CounterID: 1,
 			},
 		},
 		{
-			"incorrect-due-prefix.env", "ENVTEST_", "error: \"ENVTEST_COUNTER_ID\" is required (int)", nil,
+			"correct-memory.env",
+			"",
+			"",
+			&config.Application{
+				CounterAudit: "none",
+				CounterOutbox: config.Outbox{Sink: "none"},
+				CounterIdempotencyTTL: 24 * time.Hour,
+				CounterREST: config.HTTPServer{
+					Host: "",
+					Port: 33333,
+					BaseURI: "/counter/v1/",
+				},
+				CounterDB: config.Database{
+					Type:        "memory",
+					TablePrefix: "",
+				},
+			},
+		},
+		{
+			"correct-postgres.env",
+			"",
+			"",
+			&config.Application{
+				CounterAudit: "none",
+				CounterOutbox: config.Outbox{Sink: "none"},
+				CounterIdempotencyTTL: 24 * time.Hour,
+				CounterREST: config.HTTPServer{
+					Host: "",
+					Port: 33333,
+					BaseURI: "/counter/v1/",
+				},
+				CounterDB: config.Database{
+					Type:        "postgres",
+					Host:        "127.0.0.1",
+					Port:        5432,
+					Name:        "database",
+					User:        "user",
+					Password:    "password",
+					Options:     "sslmode=disable",
+					TablePrefix: "",
+				},
+			},
+		},
+		{
+			"correct-sqlite.env",
+			"",
+			"",
+			&config.Application{
+				CounterAudit: "none",
+				CounterOutbox: config.Outbox{Sink: "none"},
+				CounterIdempotencyTTL: 24 * time.Hour,
+				CounterREST: config.HTTPServer{
+					Host: "",
+					Port: 33333,
+					BaseURI: "/counter/v1/",
+				},
+				CounterDB: config.Database{
+					Type:        "sqlite",
+					Name:        "/var/lib/aura/aura.db",
+					Options:     "",
+					TablePrefix: "aura_",
+				},
+			},
+		},
+		{
+			"correct-file.env",
+			"",
+			"",
+			&config.Application{
+				CounterAudit: "none",
+				CounterOutbox: config.Outbox{Sink: "none"},
+				CounterIdempotencyTTL: 24 * time.Hour,
+				CounterREST: config.HTTPServer{
+					Host: "",
+					Port: 33333,
+					BaseURI: "/counter/v1/",
+				},
+				CounterDB: config.Database{
+					Type:        "file",
+					Name:        "/var/lib/aura/counters.log",
+					Options:     "sync=always",
+					TablePrefix: "",
+				},
+			},
+		},
+		{
+			"correct-audit.env",
+			"",
+			"",
+			&config.Application{
+				CounterREST: config.HTTPServer{
+					Host: "",
+					Port: 33333,
+					BaseURI: "/counter/v1/",
+				},
+				CounterDB: config.Database{
+					Type:        "memory",
+					TablePrefix: "",
+				},
+				CounterAudit: "memory",
+				CounterOutbox: config.Outbox{Sink: "none"},
+				CounterIdempotencyTTL: 90 * time.Minute,
+			},
+		},
+		{
+			"correct-grpc.env",
+			"",
+			"",
+			&config.Application{
+				CounterAudit: "none",
+				CounterOutbox: config.Outbox{Sink: "none"},
+				CounterIdempotencyTTL: 24 * time.Hour,
+				CounterREST: config.HTTPServer{
+					Host: "",
+					Port: 0,
+					BaseURI: "/counter/v1/",
+				},
+				CounterGRPC: config.TCPServer{
+					Host: "127.0.0.1",
+					Port: 33334,
+				},
+				CounterRESP: config.TCPServer{
+					Host: "127.0.0.1",
+					Port: 6379,
+				},
+				CounterMemcache: config.TCPServer{
+					Host: "127.0.0.1",
+					Port: 11211,
+				},
+				CounterDB: config.Database{
+					Type:        "memory",
+					TablePrefix: "",
+				},
+			},
+		},
+		{
+			"incorrect-due-prefix.env", "ENVTEST_", "error: \"ENVTEST_COUNTER_DB_PASSWORD\" is required (string)", nil,
 		},
 		{
 			"incorrect-due-rest-port.env", "", "error: optional \"COUNTER_REST_PORT\" is expected as int", nil,
 		},
+		{
+			"incorrect-due-grpc-port.env", "", "error: optional \"COUNTER_GRPC_PORT\" is expected as int", nil,
+		},
+		{
+			"incorrect-due-db-type.env", "", "error: \"COUNTER_DB_TYPE\" has unsupported value \"oracle\"", nil,
+		},
 		{
 			"incorrect-due-db-host.env", "", "error: \"COUNTER_DB_HOST\" is required (string)", nil,
 		},
@@ -115,10 +262,16 @@ func TestNewApplicationConfig(t *testing.T) {
 			"incorrect-due-db-password.env", "", "error: \"COUNTER_DB_PASSWORD\" is required (string)", nil,
 		},
 		{
-			"incorrect-due-counter-id-1.env", "", "error: \"COUNTER_ID\" is required (int)", nil,
+			"incorrect-due-idempotency-ttl.env", "", "error: optional \"COUNTER_IDEMPOTENCY_TTL\" is expected as positive duration", nil,
+		},
+		{
+			"incorrect-due-audit.env", "", "error: \"COUNTER_AUDIT\" has unsupported value \"syslog\"", nil,
+		},
+		{
+			"incorrect-due-outbox.env", "", "error: \"COUNTER_OUTBOX\" has unsupported value \"kafka\"", nil,
 		},
 		{
-			"incorrect-due-counter-id-2.env", "", "error: required \"COUNTER_ID\" is expected as int", nil,
+			"incorrect-due-outbox-target.env", "", "error: \"COUNTER_OUTBOX_TARGET\" is required (string)", nil,
 		},
 	}
 
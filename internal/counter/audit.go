@@ -0,0 +1,45 @@
+package counter
+
+import "time"
+
+// Audited operations
+const (
+	IncreaseOperation = "increase"
+	DecreaseOperation = "decrease"
+	ResetOperation    = "reset"
+	ReserveOperation  = "reserve"
+	SettingsOperation = "settings"
+)
+
+// AuditRecord - single mutation of the counter.
+// Settings are set for settings operation only, `OldSettings` is nil when the counter was created.
+type AuditRecord struct {
+	ID          int64
+	CounterID   int
+	Time        time.Time
+	Operation   string
+	OldValue    int64
+	NewValue    int64
+	OldSettings *Settings
+	NewSettings *Settings
+	RequestID   string
+	Client      string
+}
+
+// AuditLog - append-only sink of counter mutations.
+// Records are appended after the mutation was committed, not within its transaction,
+// so audit is best-effort: the mutation is kept when the record failed to be saved,
+// see AuditFailureHandler.
+type AuditLog interface {
+	// Append - saves the record and assigns its ID, IDs of saved records are ascending.
+	Append(record *AuditRecord) error
+	// List - returns up to `limit` records of the counter with ID greater than `after` in ascending order.
+	List(counterID int, after int64, limit int) ([]AuditRecord, error)
+}
+
+// AuditStorage - storage which is able to keep audit log along with counters (in the same database,
+// but not in the same transaction).
+type AuditStorage interface {
+	// AuditLog - exposes the storage as an audit log.
+	AuditLog() AuditLog
+}
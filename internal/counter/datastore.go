@@ -5,12 +5,30 @@ type Repository interface {
 	// EnsureSettings - make sure settings are persisted for the counter with given ID.
 	// If not, method will save default settings for counter.
 	EnsureSettings(counterID int, defaults *Settings) error
-	// Get - return current counter value.
-	GetValue(counterID int) (int, error)
+	// Get - return current counter value and cycle.
+	GetValue(counterID int) (State, error)
 	// Increase - increase counter with increment which defined by settings.
-	Increase(counterID int) (int, error)
-	// SetSettings - set new counter settings
-	SetSettings(counterID int, settings *Settings) error
+	Increase(counterID int) (State, error)
+	// Decrease - decrease counter with increment which defined by settings, reverts the increase.
+	Decrease(counterID int) (State, error)
+	// DecreaseBy - decrease counter `size` times at once, so it reverts the block of the same size,
+	// and return the state after the last decrease.
+	DecreaseBy(counterID int, size int) (State, error)
+	// Reset - return counter to start value which defined by settings.
+	Reset(counterID int) (State, error)
+	// Reserve - increase counter `size` times at once and return all passed values as ranges
+	// and the state of counter after the block was reserved.
+	Reserve(counterID int, size int) ([]Range, State, error)
+	// GetSettings - return current counter settings and their version,
+	// ErrNotFound is returned when the counter does not exist.
+	GetSettings(counterID int) (*Settings, int, error)
+	// SetSettings - set new counter settings, current value of existing counter is changed according to mode.
+	// New counter is created with start value and the first version of settings.
+	// When `version` is not zero, settings are changed only if it is equal to current version,
+	// otherwise ErrVersionMismatch is returned.
+	// Method returns previous settings (nil for new counter), the state after settings were applied
+	// and new version of settings.
+	SetSettings(counterID int, settings *Settings, mode ValueMode, version int) (*Settings, State, int, error)
 }
 
 // Storage - counter datastorage
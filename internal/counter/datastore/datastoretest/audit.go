@@ -0,0 +1,87 @@
+package datastoretest
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/wtask-go/auracounter/internal/counter"
+)
+
+// RunAuditLogSuite - runs behaviour tests of counter.AuditLog, `log` must be empty before the call.
+func RunAuditLogSuite(t *testing.T, log counter.AuditLog) {
+	settings := counter.DefaultSettings()
+	first := &counter.AuditRecord{
+		CounterID:   1,
+		Operation:   counter.SettingsOperation,
+		NewSettings: settings,
+		RequestID:   "r1",
+		Client:      "c1",
+	}
+	records := []*counter.AuditRecord{first}
+	for i := 1; i <= 5; i++ {
+		for _, counterID := range []int{1, 2} {
+			records = append(records, &counter.AuditRecord{
+				CounterID: counterID,
+				Operation: counter.IncreaseOperation,
+				OldValue:  int64(i - 1),
+				NewValue:  int64(i),
+			})
+		}
+	}
+	for _, r := range records {
+		r.Time = time.Now().UTC().Truncate(time.Second)
+		if err := log.Append(r); err != nil {
+			t.Fatalf("Append() failed: %v", err)
+		}
+		if r.ID == 0 {
+			t.Errorf("Append() did not assign ID to %+v", r)
+		}
+	}
+	if err := log.Append(nil); err == nil {
+		t.Error("Append(nil): expected error")
+	}
+
+	t.Log("Case: record with settings")
+	actual, err := log.List(1, 0, 1)
+	if err != nil || len(actual) != 1 || actual[0].ID != first.ID {
+		t.Fatalf("Unexpected first page: %+v (%v)", actual, err)
+	}
+	if actual[0].OldSettings != nil || actual[0].NewSettings == nil || *actual[0].NewSettings != *settings {
+		t.Errorf("Unexpected settings of record: %+v", actual[0])
+	}
+	if actual[0].RequestID != "r1" || actual[0].Client != "c1" || actual[0].Operation != counter.SettingsOperation {
+		t.Errorf("Unexpected record: %+v", actual[0])
+	}
+
+	cases := []struct {
+		after    int64
+		limit    int
+		expected []int64 // values of the counter #1
+	}{
+		{first.ID, 10, []int64{1, 2, 3, 4, 5}},
+		{first.ID, 2, []int64{1, 2}},
+		// IDs of both counters are interleaved
+		{records[4].ID, 2, []int64{3, 4}},
+		{records[8].ID, 10, []int64{5}},
+		{records[10].ID, 10, []int64{}},
+	}
+	for _, c := range cases {
+		t.Logf("Case: list up to %d records after #%d", c.limit, c.after)
+		actual, err := log.List(1, c.after, c.limit)
+		if err != nil {
+			t.Errorf("List(1, %d, %d) failed: %v", c.after, c.limit, err)
+			continue
+		}
+		values := []int64{}
+		for _, r := range actual {
+			if r.CounterID != 1 || r.OldValue != r.NewValue-1 {
+				t.Errorf("List(1, %d, %d): unexpected record %+v", c.after, c.limit, r)
+			}
+			values = append(values, r.NewValue)
+		}
+		if !reflect.DeepEqual(values, c.expected) {
+			t.Errorf("List(1, %d, %d): expected values %v, got %v", c.after, c.limit, c.expected, values)
+		}
+	}
+}
@@ -0,0 +1,494 @@
+/*
+Package datastoretest contains behaviour tests, which are shared by all implementations of counter.Repository
+and counter.AuditLog. Tests use only the interfaces of counter package, so every datastore is checked
+with the same cases, and datastore packages test their own specifics (e.g. schema migrations) only.
+*/
+package datastoretest
+
+import (
+	"math"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/wtask-go/auracounter/internal/counter"
+)
+
+// Factory - returns empty repository for single test and the func to release it after the test.
+// Factory must fail the test when repository can not be prepared.
+type Factory func(t *testing.T) (counter.Repository, func())
+
+// RunRepositorySuite - runs behaviour tests of counter.Repository as subtests of `t`,
+// every subtest gets its own empty repository from factory.
+// Idempotency test is skipped when repository does not implement counter.IdempotentRepository.
+func RunRepositorySuite(t *testing.T, factory Factory) {
+	suite := []struct {
+		name string
+		test func(*testing.T, counter.Repository)
+	}{
+		{"EnsureSettings", repositoryEnsureSettings},
+		{"GetValue", repositoryGetValue},
+		{"Increase", repositoryIncrease},
+		{"IncreaseOnce", repositoryIncreaseOnce},
+		{"ConcurrentIncrease", repositoryConcurrentIncrease},
+		{"Decrease", repositoryDecrease},
+		{"DecreaseBy", repositoryDecreaseBy},
+		{"Reset", repositoryReset},
+		{"Reserve", repositoryReserve},
+		{"GetSettings", repositoryGetSettings},
+		{"SetSettings", repositorySetSettings},
+	}
+	for _, s := range suite {
+		test := s.test
+		t.Run(s.name, func(t *testing.T) {
+			repository, release := factory(t)
+			defer release()
+			test(t, repository)
+		})
+	}
+}
+
+// create - creates counter with given settings, its value is equal to `StartFrom`.
+func create(t *testing.T, repository counter.Repository, counterID int, settings *counter.Settings) {
+	if _, _, _, err := repository.SetSettings(counterID, settings, counter.ResetValue, 0); err != nil {
+		t.Fatalf("Unable to create counter #%d with %+v: %v", counterID, *settings, err)
+	}
+}
+
+// expectValue - checks committed value of the counter.
+func expectValue(t *testing.T, repository counter.Repository, counterID int, expected int64) {
+	state, err := repository.GetValue(counterID)
+	if err != nil {
+		t.Errorf("Failed to get value of counter #%d: %v", counterID, err)
+		return
+	}
+	if state.Value != expected {
+		t.Errorf("Expected committed value %d of counter #%d, got %d", expected, counterID, state.Value)
+	}
+}
+
+func repositoryEnsureSettings(t *testing.T, repository counter.Repository) {
+	settings := counter.DefaultSettings()
+	t.Logf("Case: empty repository and default counter.Settings %+v", settings)
+	if err := repository.EnsureSettings(1, settings); err != nil {
+		t.Errorf("Method failed (empty repository): %v", err)
+	}
+	loaded, version, err := repository.GetSettings(1)
+	if err != nil {
+		t.Fatalf("Failed to load counter.Settings: %v", err)
+	}
+	if *loaded != *settings || version != 1 {
+		t.Errorf("Loaded unexpected counter.Settings: %+v of version %d", loaded, version)
+	}
+	expectValue(t, repository, 1, settings.StartFrom)
+
+	existing := &counter.Settings{StartFrom: 100, Increment: 10, Lower: 0, Upper: 100}
+	create(t, repository, 2, existing)
+	custom := &counter.Settings{StartFrom: 1000, Increment: 100, Lower: 33, Upper: 10000}
+	t.Logf("Case: existing counter and custom counter.Settings %+v", custom)
+	if err := repository.EnsureSettings(2, custom); err != nil {
+		t.Errorf("Method failed (existing counter): %v", err)
+	}
+	loaded, _, err = repository.GetSettings(2)
+	if err != nil {
+		t.Fatalf("Failed to load counter.Settings: %v", err)
+	}
+	if *loaded != *existing {
+		t.Errorf("Method violates counter.Settings integrity: %+v", loaded)
+	}
+	expectValue(t, repository, 2, existing.StartFrom)
+}
+
+func repositoryGetValue(t *testing.T, repository counter.Repository) {
+	t.Log("Case: empty repository")
+	if _, err := repository.GetValue(1); err == nil {
+		t.Error("Expected error for non-existed counter, got nothing")
+	}
+
+	t.Log("Case: existing counter")
+	create(t, repository, 1, &counter.Settings{StartFrom: 100, Increment: 10, Lower: 0, Upper: 1000})
+	v, err := repository.GetValue(1)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if v.Value != 100 || v.Cycle != 0 {
+		t.Errorf("Expected value 100 of the first cycle, got %+v", v)
+	}
+}
+
+func repositoryIncrease(t *testing.T, repository counter.Repository) {
+	t.Log("Case: empty repository")
+	if _, err := repository.Increase(1); err == nil {
+		t.Error("Expected error for non-existed counter, got nothing")
+	}
+
+	t.Log("Case: existing counter")
+	create(t, repository, 1, &counter.Settings{StartFrom: 990, Increment: 10, Lower: 0, Upper: 1000})
+	v, err := repository.Increase(1)
+	if err != nil || v.Value != 1000 || v.Previous != 990 {
+		t.Errorf("Expected 1000 after 990, got %+v (%v)", v, err)
+	}
+
+	t.Log("Case: reaching the upper limit")
+	v, err = repository.Increase(1)
+	if err != nil || v.Value != 0 {
+		t.Errorf("Expected %d, got %+v (%v)", 0, v, err)
+	}
+	if v.Cycle != 1 || v.Wraps != 1 {
+		t.Errorf("Expected the first wrap, got %+v", v)
+	}
+	if v, _ := repository.GetValue(1); v.Cycle != 1 {
+		t.Errorf("Unexpected committed cycle (%d) after wrap", v.Cycle)
+	}
+
+	cases := []struct {
+		name     string
+		settings *counter.Settings
+		expected []int64
+	}{
+		{"descending counter", &counter.Settings{StartFrom: 5, Increment: -5, Lower: 0, Upper: 1000}, []int64{0, 1000, 995}},
+		{
+			"saturated counter",
+			&counter.Settings{StartFrom: 995, Increment: 10, Lower: 0, Upper: 1000, Overflow: counter.SaturateOnOverflow},
+			[]int64{1000, 1000},
+		},
+		{
+			"int64 range",
+			&counter.Settings{StartFrom: math.MaxInt64 - 1, Increment: 1, Lower: math.MinInt64, Upper: math.MaxInt64},
+			[]int64{math.MaxInt64, math.MinInt64},
+		},
+	}
+	for i, c := range cases {
+		t.Logf("Case: %s", c.name)
+		counterID := i + 2
+		create(t, repository, counterID, c.settings)
+		for _, expected := range c.expected {
+			v, err := repository.Increase(counterID)
+			if err != nil || v.Value != expected {
+				t.Errorf("Expected %d, got %+v (%v)", expected, v, err)
+			}
+		}
+	}
+
+	t.Log("Case: counter fails on overflow")
+	create(t, repository, 10, &counter.Settings{StartFrom: 995, Increment: 10, Lower: 0, Upper: 1000, Overflow: counter.FailOnOverflow})
+	if _, err := repository.Increase(10); errors.Cause(err) != counter.ErrOverflow {
+		t.Errorf("Expected %v, got %v", counter.ErrOverflow, err)
+	}
+	expectValue(t, repository, 10, 995)
+}
+
+func repositoryConcurrentIncrease(t *testing.T, repository counter.Repository) {
+	settings := &counter.Settings{StartFrom: 90, Increment: 1, Lower: 0, Upper: 199}
+	create(t, repository, 1, settings)
+
+	// number of calls does not exceed counter range, but counter wraps
+	// so all of returned values must be distinct
+	workers, calls := 25, 6
+	expected := map[int64]bool{}
+	last := settings.StartFrom
+	for i := 0; i < workers*calls; i++ {
+		last, _ = settings.Next(last)
+		expected[last] = true
+	}
+
+	t.Logf("Case: %d workers increase counter %d times each", workers, calls)
+	var (
+		wg      sync.WaitGroup
+		mx      sync.Mutex
+		results = map[int64]int{}
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < calls; i++ {
+				v, err := repository.Increase(1)
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+					continue
+				}
+				mx.Lock()
+				results[v.Value]++
+				mx.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	for v, n := range results {
+		if n > 1 {
+			t.Errorf("Value %d was returned %d times", v, n)
+		}
+		if !expected[v] {
+			t.Errorf("Unexpected value %d was returned", v)
+		}
+	}
+	if len(results) != len(expected) {
+		t.Errorf("Expected %d distinct values, got %d", len(expected), len(results))
+	}
+	expectValue(t, repository, 1, last)
+}
+
+func repositoryDecrease(t *testing.T, repository counter.Repository) {
+	t.Log("Case: empty repository")
+	if _, err := repository.Decrease(1); err == nil {
+		t.Error("Expected error for non-existed counter, got nothing")
+	}
+
+	t.Log("Case: existing counter")
+	create(t, repository, 1, &counter.Settings{StartFrom: 10, Increment: 10, Lower: 0, Upper: 1005})
+	v, err := repository.Decrease(1)
+	if err != nil || v.Value != 0 {
+		t.Errorf("Expected %d, got %+v (%v)", 0, v, err)
+	}
+
+	t.Log("Case: reaching the lower limit")
+	v, err = repository.Decrease(1)
+	if err != nil || v.Value != 1005 {
+		t.Errorf("Expected %d, got %+v (%v)", 1005, v, err)
+	}
+	if v.Cycle != -1 || v.Wraps != -1 {
+		t.Errorf("Expected return to the previous cycle, got %+v", v)
+	}
+	expectValue(t, repository, 1, 1005)
+}
+
+func repositoryDecreaseBy(t *testing.T, repository counter.Repository) {
+	t.Log("Case: empty repository")
+	if _, err := repository.DecreaseBy(1, 10); err == nil {
+		t.Error("Expected error for non-existed counter, got nothing")
+	}
+
+	t.Log("Case: decrease reverts the block of the same size")
+	create(t, repository, 1, &counter.Settings{StartFrom: 950, Increment: 10, Lower: 0, Upper: 1000})
+	if _, _, err := repository.Reserve(1, 7); err != nil {
+		t.Fatalf("Unable to reserve block: %v", err)
+	}
+	v, err := repository.DecreaseBy(1, 7)
+	if err != nil || v.Value != 950 || v.Previous != 10 {
+		t.Errorf("Expected %d after %d, got %+v (%v)", 950, 10, v, err)
+	}
+	if v.Cycle != 0 || v.Wraps != -1 {
+		t.Errorf("Expected return to the first cycle, got %+v", v)
+	}
+	expectValue(t, repository, 1, 950)
+
+	t.Log("Case: counter fails on overflow")
+	create(t, repository, 2, &counter.Settings{StartFrom: 10, Increment: 10, Lower: 0, Upper: 1000, Overflow: counter.FailOnOverflow})
+	if _, err := repository.DecreaseBy(2, 2); errors.Cause(err) != counter.ErrOverflow {
+		t.Errorf("Expected %v, got %v", counter.ErrOverflow, err)
+	}
+	// all decreases are committed together or nothing is changed
+	expectValue(t, repository, 2, 10)
+}
+
+func repositoryReset(t *testing.T, repository counter.Repository) {
+	t.Log("Case: empty repository")
+	if _, err := repository.Reset(1); err == nil {
+		t.Error("Expected error for non-existed counter, got nothing")
+	}
+
+	t.Log("Case: existing counter")
+	create(t, repository, 1, &counter.Settings{StartFrom: 500, Increment: 10, Lower: 0, Upper: 1000})
+	settings := &counter.Settings{StartFrom: 100, Increment: 10, Lower: 0, Upper: 1000}
+	if _, _, _, err := repository.SetSettings(1, settings, counter.PreserveValue, 0); err != nil {
+		t.Fatalf("Unable to change settings: %v", err)
+	}
+	v, err := repository.Reset(1)
+	if err != nil || v.Value != 100 || v.Previous != 500 {
+		t.Errorf("Expected %d after %d, got %+v (%v)", 100, 500, v, err)
+	}
+	expectValue(t, repository, 1, 100)
+}
+
+func repositoryReserve(t *testing.T, repository counter.Repository) {
+	t.Log("Case: empty repository")
+	if _, _, err := repository.Reserve(1, 10); err == nil {
+		t.Error("Expected error for non-existed counter, got nothing")
+	}
+
+	create(t, repository, 1, &counter.Settings{StartFrom: 950, Increment: 10, Lower: 0, Upper: 1000})
+
+	t.Log("Case: block within counter range")
+	ranges, _, err := repository.Reserve(1, 3)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	expected := []counter.Range{{First: 960, Last: 980, Step: 10, Count: 3}}
+	if !reflect.DeepEqual(ranges, expected) {
+		t.Errorf("Expected %v, got %v", expected, ranges)
+	}
+
+	t.Log("Case: block wraps to the lower limit")
+	ranges, state, err := repository.Reserve(1, 5)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	expected = []counter.Range{
+		{First: 990, Last: 1000, Step: 10, Count: 2},
+		{First: 0, Last: 20, Step: 10, Count: 3},
+	}
+	if !reflect.DeepEqual(ranges, expected) {
+		t.Errorf("Expected %v, got %v", expected, ranges)
+	}
+	if state.Value != 20 || state.Cycle != 1 || state.Wraps != 1 {
+		t.Errorf("Unexpected state after block: %+v", state)
+	}
+	expectValue(t, repository, 1, 20)
+
+	t.Log("Case: block fails on overflow")
+	create(t, repository, 2, &counter.Settings{StartFrom: 990, Increment: 10, Lower: 0, Upper: 1000, Overflow: counter.FailOnOverflow})
+	if _, _, err := repository.Reserve(2, 2); errors.Cause(err) != counter.ErrOverflow {
+		t.Errorf("Expected %v, got %v", counter.ErrOverflow, err)
+	}
+	expectValue(t, repository, 2, 990)
+}
+
+func repositoryGetSettings(t *testing.T, repository counter.Repository) {
+	t.Log("Case: empty repository")
+	if _, _, err := repository.GetSettings(1); errors.Cause(err) != counter.ErrNotFound {
+		t.Errorf("Expected counter.ErrNotFound for non-existed counter, got %v", err)
+	}
+
+	t.Log("Case: existing counter")
+	create(t, repository, 1, counter.DefaultSettings())
+	expected := counter.Settings{StartFrom: 100, Increment: 10, Lower: -100, Upper: 1000, Overflow: counter.SaturateOnOverflow}
+	if _, _, _, err := repository.SetSettings(1, &expected, counter.PreserveValue, 0); err != nil {
+		t.Fatalf("Unable to change settings: %v", err)
+	}
+	settings, version, err := repository.GetSettings(1)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if settings == nil || *settings != expected || version != 2 {
+		t.Errorf("Expected %+v of version 2, got %+v of version %d", expected, settings, version)
+	}
+}
+
+func repositorySetSettings(t *testing.T, repository counter.Repository) {
+	t.Log("Case: empty repository")
+	initial := &counter.Settings{StartFrom: 100, Increment: 10, Lower: 0, Upper: 1000}
+	if _, _, _, err := repository.SetSettings(2, initial, counter.ResetValue, 1); errors.Cause(err) != counter.ErrVersionMismatch {
+		t.Errorf("Expected version mismatch for non-existed counter, got %v", err)
+	}
+	previous, state, version, err := repository.SetSettings(1, initial, counter.ResetValue, 0)
+	if err != nil || previous != nil || version != 1 || state.Value != initial.StartFrom {
+		t.Errorf("Unexpected result for new counter: %+v, %+v, version %d (%v)", previous, state, version, err)
+	}
+	loaded, _, err := repository.GetSettings(1)
+	if err != nil || *loaded != *initial {
+		t.Errorf("Loaded unexpected counter.Settings: %+v (%v)", loaded, err)
+	}
+	expectValue(t, repository, 1, initial.StartFrom)
+
+	cases := []struct {
+		settings *counter.Settings
+		mode     counter.ValueMode
+		expected int64
+	}{
+		// StartFrom must not affect existing counter
+		{&counter.Settings{StartFrom: 500, Increment: 100, Lower: 0, Upper: 10000}, counter.PreserveValue, 100},
+		{&counter.Settings{StartFrom: 500, Increment: 100, Lower: 200, Upper: 10000}, counter.PreserveValue, 100},
+		{&counter.Settings{StartFrom: 500, Increment: 100, Lower: 200, Upper: 10000}, counter.ClampValue, 200},
+		{&counter.Settings{StartFrom: 500, Increment: 100, Lower: 0, Upper: 10000}, counter.ResetValue, 500},
+		{&counter.Settings{StartFrom: 500, Increment: 100, Lower: 0, Upper: 10000, Overflow: counter.FailOnOverflow}, counter.PreserveValue, 500},
+		// zero values must be saved too
+		{&counter.Settings{StartFrom: 0, Increment: 0, Lower: 0, Upper: 0}, counter.ClampValue, 0},
+	}
+	for i, c := range cases {
+		t.Logf("Case: set %+v with mode %d", *c.settings, c.mode)
+		previous, state, version, err := repository.SetSettings(1, c.settings, c.mode, i+1)
+		if err != nil || version != i+2 {
+			t.Errorf("Unexpected error (%v) or version (%d)", err, version)
+		}
+		if previous == nil || state.Value != c.expected {
+			t.Errorf("Unexpected previous settings %+v or state %+v", previous, state)
+		}
+		expectValue(t, repository, 1, c.expected)
+		loaded, _, err := repository.GetSettings(1)
+		if err != nil || *loaded != *c.settings {
+			t.Errorf("Loaded unexpected counter.Settings: %+v (%v)", loaded, err)
+		}
+	}
+
+	t.Log("Case: version mismatch")
+	if _, _, _, err := repository.SetSettings(1, initial, counter.ResetValue, 1); errors.Cause(err) != counter.ErrVersionMismatch {
+		t.Errorf("Expected version mismatch, got %v", err)
+	}
+	if _, version, err := repository.GetSettings(1); err != nil || version != len(cases)+1 {
+		t.Errorf("Settings were changed despite version mismatch, version %d (%v)", version, err)
+	}
+}
+
+func repositoryIncreaseOnce(t *testing.T, repository counter.Repository) {
+	idempotent, ok := repository.(counter.IdempotentRepository)
+	if !ok {
+		t.Skip("Repository does not implement counter.IdempotentRepository")
+	}
+
+	t.Log("Case: empty repository")
+	if _, _, err := idempotent.IncreaseOnce(1, "key", time.Minute); err == nil {
+		t.Error("Expected error for non-existed counter, got nothing")
+	}
+
+	create(t, repository, 1, &counter.Settings{StartFrom: 990, Increment: 10, Lower: 0, Upper: 1000})
+
+	t.Log("Case: the first call")
+	first, replayed, err := idempotent.IncreaseOnce(1, "key", time.Minute)
+	if err != nil || replayed || first.Value != 1000 || first.Previous != 990 {
+		t.Errorf("Unexpected result: %+v, replayed %t (%v)", first, replayed, err)
+	}
+
+	t.Log("Case: retry after the counter was changed")
+	if _, err := repository.Increase(1); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	retry, replayed, err := idempotent.IncreaseOnce(1, "key", time.Minute)
+	if err != nil || !replayed || retry != first {
+		t.Errorf("Expected replayed %+v, got %+v, replayed %t (%v)", first, retry, replayed, err)
+	}
+	expectValue(t, repository, 1, 0)
+
+	t.Log("Case: another key")
+	other, replayed, err := idempotent.IncreaseOnce(1, "other", time.Minute)
+	if err != nil || replayed || other.Value != 10 {
+		t.Errorf("Unexpected result: %+v, replayed %t (%v)", other, replayed, err)
+	}
+
+	t.Log("Case: expired key")
+	// negative TTL remembers the key as already expired
+	if _, _, err := idempotent.IncreaseOnce(1, "expired", -time.Minute); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	expired, replayed, err := idempotent.IncreaseOnce(1, "expired", time.Minute)
+	if err != nil || replayed || expired.Value != 30 {
+		t.Errorf("Unexpected result: %+v, replayed %t (%v)", expired, replayed, err)
+	}
+
+	t.Log("Case: concurrent retries")
+	wg := sync.WaitGroup{}
+	results := make(chan counter.State, 10)
+	for i := 0; i < cap(results); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, _, err := idempotent.IncreaseOnce(1, "concurrent", time.Minute)
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+				return
+			}
+			results <- v
+		}()
+	}
+	wg.Wait()
+	close(results)
+	for v := range results {
+		if v.Value != 40 {
+			t.Errorf("Expected 40 for every retry, got %+v", v)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package file
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/wtask-go/auracounter/internal/counter"
+	"github.com/wtask-go/auracounter/internal/counter/datastore/datastoretest"
+)
+
+// tempLog - returns path of log file within new temporary directory and cleanup func.
+func tempLog(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "auracounter")
+	if err != nil {
+		t.Fatalf("Unable to create temporary directory: %v", err)
+	}
+	return filepath.Join(dir, "counters.log"), func() { os.RemoveAll(dir) }
+}
+
+// openStorage - opens storage or fails the test.
+func openStorage(t *testing.T, dsn string, options ...storageOption) *storage {
+	iface, err := NewStorage(dsn, options...)
+	if err != nil {
+		t.Fatalf("Unable to create file storage: %v", err)
+	}
+	return iface.(*storage)
+}
+
+func TestNewStorage(t *testing.T) {
+	path, cleanup := tempLog(t)
+	defer cleanup()
+
+	cases := []struct {
+		dsn      string
+		options  []storageOption
+		errMsg   string
+		policy   SyncPolicy
+		interval time.Duration
+		snapshot int
+	}{
+		{"", nil, "required DSN is missed", "", 0, 0},
+		{"file://", nil, "required DSN is missed", "", 0, 0},
+		{"file://" + path + "?sync=sometimes", nil, "unknown policy \"sometimes\"", "", 0, 0},
+		{"file://" + path + "?interval=often", nil, "invalid \"interval\" param", "", 0, 0},
+		{"file://" + path + "?snapshot=-1", nil, "negative number of entries (-1)", "", 0, 0},
+		{"file://" + path + "?compress=true", nil, "unknown param \"compress\"", "", 0, 0},
+		{path, nil, "", SyncAlways, time.Second, 1000},
+		{"file://" + path, nil, "", SyncAlways, time.Second, 1000},
+		{"file://" + path + "?sync=none&snapshot=0", nil, "", SyncNone, time.Second, 0},
+		{"file://" + path + "?sync=interval&interval=5ms", nil, "", SyncInterval, 5 * time.Millisecond, 1000},
+		{
+			"file://" + path + "?sync=none&snapshot=10",
+			[]storageOption{WithSyncPolicy(SyncAlways), WithSnapshotEvery(100)},
+			"",
+			SyncAlways,
+			time.Second,
+			100,
+		},
+	}
+
+	for _, c := range cases {
+		iface, err := NewStorage(c.dsn, c.options...)
+		switch {
+		case err != nil && c.errMsg == "":
+			t.Errorf("NewStorage(%q): unexpected error: %v", c.dsn, err)
+		case err != nil && !strings.Contains(err.Error(), c.errMsg):
+			t.Errorf("NewStorage(%q): expected error will contain %q, but got: %v", c.dsn, c.errMsg, err)
+		case err == nil && c.errMsg != "":
+			t.Errorf("NewStorage(%q): expected error will contain %q, but got nothing", c.dsn, c.errMsg)
+		case err == nil:
+			s := iface.(*storage)
+			if s.policy != c.policy || s.interval != c.interval || s.snapshotEvery != c.snapshot {
+				t.Errorf(
+					"NewStorage(%q): unexpected preferences %q, %s, %d",
+					c.dsn,
+					s.policy,
+					s.interval,
+					s.snapshotEvery,
+				)
+			}
+			s.Close()
+		}
+	}
+}
+
+func TestRepository(t *testing.T) {
+	datastoretest.RunRepositorySuite(t, func(t *testing.T) (counter.Repository, func()) {
+		path, cleanup := tempLog(t)
+		s := openStorage(t, path)
+		if err := s.EnsureLatest(); err != nil {
+			t.Fatalf("EnsureLatest() for file failed: %v", err)
+		}
+		return s, func() {
+			s.Close()
+			cleanup()
+		}
+	})
+}
+
+func TestRecovery(t *testing.T) {
+	path, cleanup := tempLog(t)
+	defer cleanup()
+
+	for _, dsn := range []string{path + "?snapshot=0", path + "?snapshot=3", path + "?sync=interval&interval=1ms"} {
+		os.Remove(path)
+		os.Remove(path + ".snapshot")
+
+		s := openStorage(t, dsn)
+		s.EnsureSettings(1, &counter.Settings{StartFrom: 0, Increment: 1, Lower: 0, Upper: 7})
+		s.EnsureSettings(2, &counter.Settings{StartFrom: 100, Increment: 10, Lower: 100, Upper: 200})
+		// descending counter
+		s.EnsureSettings(3, &counter.Settings{StartFrom: 7, Increment: -1, Lower: 0, Upper: 7})
+		s.EnsureSettings(4, &counter.Settings{StartFrom: 7, Increment: 1, Lower: 0, Upper: 7, Overflow: counter.FailOnOverflow})
+		for i := 0; i < 10; i++ {
+			s.Increase(1)
+			s.Increase(2)
+			s.Increase(3)
+		}
+		if err := s.Close(); err != nil {
+			t.Errorf("%s: Close(): unexpected error: %v", dsn, err)
+		}
+		if _, err := s.Increase(1); err == nil {
+			t.Errorf("%s: Increase(): expected error for closed storage, got nothing", dsn)
+		}
+
+		s = openStorage(t, dsn)
+		if v, _ := s.GetValue(1); v.Value != 2 || v.Cycle != 1 {
+			t.Errorf("%s: expected restored state {Value:2 Cycle:1}, got %+v", dsn, v)
+		}
+		if v, _ := s.GetValue(2); v.Value != 200 {
+			t.Errorf("%s: expected restored value %d, got %d", dsn, 200, v.Value)
+		}
+		if v, _ := s.Increase(2); v.Value != 100 {
+			t.Errorf("%s: expected restored settings, got %d after increase", dsn, v.Value)
+		}
+		if v, _ := s.GetValue(3); v.Value != 5 || v.Cycle != 1 {
+			t.Errorf("%s: expected restored state {Value:5 Cycle:1}, got %+v", dsn, v)
+		}
+		if v, _ := s.Increase(3); v.Value != 4 {
+			t.Errorf("%s: expected restored settings, got %d after increase", dsn, v.Value)
+		}
+		if _, err := s.Increase(4); errors.Cause(err) != counter.ErrOverflow {
+			t.Errorf("%s: expected restored overflow policy, got %v", dsn, err)
+		}
+		s.Close()
+	}
+}
+
+func TestIdempotencyRecovery(t *testing.T) {
+	path, cleanup := tempLog(t)
+	defer cleanup()
+
+	// keys are restored from the log and from the snapshot taken after the 2nd entry
+	for _, dsn := range []string{path + "?snapshot=0", path + "?snapshot=2"} {
+		os.Remove(path)
+		os.Remove(path + ".snapshot")
+
+		s := openStorage(t, dsn)
+		s.EnsureSettings(1, &counter.Settings{StartFrom: 0, Increment: 1, Lower: 0, Upper: 7})
+		first, _, err := s.IncreaseOnce(1, "key", time.Hour)
+		if err != nil {
+			t.Errorf("%s: IncreaseOnce(): unexpected error: %v", dsn, err)
+		}
+		s.IncreaseOnce(1, "expired", -time.Hour)
+		s.Increase(1)
+		s.Close()
+
+		s = openStorage(t, dsn)
+		if v, replayed, err := s.IncreaseOnce(1, "key", time.Hour); err != nil || !replayed || v != first {
+			t.Errorf("%s: expected replayed %+v, got %+v, replayed %t (%v)", dsn, first, v, replayed, err)
+		}
+		if v, replayed, err := s.IncreaseOnce(1, "expired", time.Hour); err != nil || replayed || v.Value != 4 {
+			t.Errorf("%s: expected increase to %d for expired key, got %+v, replayed %t (%v)", dsn, 4, v, replayed, err)
+		}
+		s.Close()
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	path, cleanup := tempLog(t)
+	defer cleanup()
+
+	s := openStorage(t, path, WithSnapshotEvery(4))
+	s.EnsureSettings(1, &counter.Settings{StartFrom: 0, Increment: 1, Lower: 0, Upper: 100})
+	for i := 0; i < 9; i++ {
+		s.Increase(1)
+	}
+	// 10 entries were appended, the latest snapshot was taken after 8th
+	if _, err := os.Stat(path + ".snapshot"); err != nil {
+		t.Errorf("Snapshot was not taken: %v", err)
+	}
+	if data, _ := ioutil.ReadFile(path); strings.Count(string(data), "\n") != 2 {
+		t.Errorf("Expected 2 entries in the log after snapshot, got:\n%s", data)
+	}
+	if err := s.EnsureLatest(); err != nil {
+		t.Errorf("EnsureLatest(): unexpected error: %v", err)
+	}
+	if data, _ := ioutil.ReadFile(path); len(data) != 0 {
+		t.Errorf("Expected empty log after EnsureLatest(), got:\n%s", data)
+	}
+	s.Close()
+
+	s = openStorage(t, path)
+	defer s.Close()
+	if v, _ := s.GetValue(1); v.Value != 9 {
+		t.Errorf("Expected restored value %d, got %d", 9, v.Value)
+	}
+}
+
+func TestCrashRecovery(t *testing.T) {
+	path, cleanup := tempLog(t)
+	defer cleanup()
+
+	s := openStorage(t, path, WithSnapshotEvery(0))
+	s.EnsureSettings(1, &counter.Settings{StartFrom: 0, Increment: 1, Lower: 0, Upper: 100})
+	s.Increase(1)
+	s.Increase(1)
+	s.Close()
+	committed, _ := ioutil.ReadFile(path)
+
+	t.Log("Case: incomplete entry at the end of log")
+	line, _ := entry{ID: 1, Value: 3, Increment: 1, Upper: 100}.encode()
+	ioutil.WriteFile(path, append(append([]byte{}, committed...), line[:len(line)/2]...), 0644)
+
+	s = openStorage(t, path)
+	if v, _ := s.GetValue(1); v.Value != 2 {
+		t.Errorf("Expected the latest committed value %d, got %d", 2, v.Value)
+	}
+	if v, _ := s.Increase(1); v.Value != 3 {
+		t.Errorf("Expected %d after increase, got %d", 3, v.Value)
+	}
+	s.Close()
+	s = openStorage(t, path)
+	if v, _ := s.GetValue(1); v.Value != 3 {
+		t.Errorf("Expected the latest committed value %d, got %d", 3, v.Value)
+	}
+	s.Close()
+
+	t.Log("Case: broken checksum of the latest entry")
+	broken := append([]byte{}, committed...)
+	broken[len(broken)-3] = '9'
+	ioutil.WriteFile(path, broken, 0644)
+	s = openStorage(t, path)
+	if v, _ := s.GetValue(1); v.Value != 1 {
+		t.Errorf("Expected the latest valid value %d, got %d", 1, v.Value)
+	}
+	s.Close()
+
+	t.Log("Case: corrupted entry in the middle of log")
+	corrupted := append([]byte{}, committed...)
+	corrupted[1] = 'x'
+	ioutil.WriteFile(path, corrupted, 0644)
+	if _, err := NewStorage(path); err == nil {
+		t.Error("Expected error for corrupted log, got nothing")
+	}
+}
@@ -0,0 +1,39 @@
+package file
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/wtask-go/auracounter/internal/counter"
+)
+
+// IncreaseOnce - implements counter.IdempotentRepository interface.
+// The key is committed with the same log entry as the new counter state and it is saved with snapshots,
+// so retries are replayed after restart too. Expired keys are forgotten when snapshot is taken.
+// If counter/counter settings were not prepared before calling `file.IncreaseOnce`, method will fail.
+func (s *storage) IncreaseOnce(counterID int, key string, ttl time.Duration) (counter.State, bool, error) {
+	if key == "" {
+		return counter.State{}, false, errors.Errorf("file.IncreaseOnce(#%d): empty key", counterID)
+	}
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	c, ok := s.counters[counterID]
+	if !ok {
+		return counter.State{}, false, errors.Errorf("file.IncreaseOnce(#%d): counter not found", counterID)
+	}
+	now := time.Now()
+	if r, ok := s.keys[counterID][key]; ok && r.expires.After(now) {
+		return r.state, true, nil
+	}
+	state, err := c.state().Increased(&c.settings)
+	if err != nil {
+		return counter.State{}, false, errors.Wrapf(err, "file.IncreaseOnce(#%d): failed", counterID)
+	}
+	e := newEntry(counterID, &record{value: state.Value, cycle: state.Cycle, settings: c.settings, version: c.version})
+	k := newKeyEntry(counterID, key, remembered{state: state, expires: now.Add(ttl)})
+	e.Key = &k
+	if err := s.commitEntry(&e); err != nil {
+		return counter.State{}, false, errors.Wrapf(err, "file.IncreaseOnce(#%d): failed", counterID)
+	}
+	return state, false, nil
+}
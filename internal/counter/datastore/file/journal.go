@@ -0,0 +1,349 @@
+package file
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/wtask-go/auracounter/internal/counter"
+)
+
+// snapshotVersion - current version of snapshot format
+const snapshotVersion = 1
+
+type (
+	// entry - single log entry, it always contains complete counter state,
+	// so replaying the log over newer snapshot is harmless.
+	entry struct {
+		ID        int   `json:"id"`
+		Value     int64 `json:"value"`
+		StartFrom int64 `json:"start_from"`
+		Increment int64 `json:"increment"`
+		Lower     int64 `json:"lower"`
+		Upper     int64 `json:"upper"`
+		Overflow  int   `json:"overflow,omitempty"`
+		Cycle     int   `json:"cycle,omitempty"`
+		// Version - version of settings, it is missed in entries written before settings were versioned
+		Version int `json:"version,omitempty"`
+		// Key - idempotency key remembered with the change, see `file.IncreaseOnce`
+		Key *keyEntry `json:"key,omitempty"`
+	}
+
+	// keyEntry - idempotency key with the state of counter remembered with it
+	keyEntry struct {
+		ID       int       `json:"id"`
+		Key      string    `json:"key"`
+		Value    int64     `json:"value"`
+		Cycle    int       `json:"cycle,omitempty"`
+		Wraps    int       `json:"wraps,omitempty"`
+		Previous int64     `json:"previous"`
+		Expires  time.Time `json:"expires"`
+	}
+
+	// snapshot - all counters saved at once, along with unexpired idempotency keys
+	snapshot struct {
+		Version  int        `json:"version"`
+		Counters []entry    `json:"counters"`
+		Keys     []keyEntry `json:"keys,omitempty"`
+	}
+)
+
+func newEntry(counterID int, r *record) entry {
+	return entry{
+		ID:        counterID,
+		Value:     r.value,
+		StartFrom: r.settings.StartFrom,
+		Increment: r.settings.Increment,
+		Lower:     r.settings.Lower,
+		Upper:     r.settings.Upper,
+		Overflow:  int(r.settings.Overflow),
+		Cycle:     r.cycle,
+		Version:   r.version,
+	}
+}
+
+func (e entry) record() *record {
+	version := e.Version
+	if version == 0 {
+		// entry was written before settings were versioned
+		version = 1
+	}
+	return &record{
+		value:   e.Value,
+		cycle:   e.Cycle,
+		version: version,
+		settings: counter.Settings{
+			StartFrom: e.StartFrom,
+			Increment: e.Increment,
+			Lower:     e.Lower,
+			Upper:     e.Upper,
+			Overflow:  counter.OverflowPolicy(e.Overflow),
+		},
+	}
+}
+
+func newKeyEntry(counterID int, key string, r remembered) keyEntry {
+	return keyEntry{
+		ID:       counterID,
+		Key:      key,
+		Value:    r.state.Value,
+		Cycle:    r.state.Cycle,
+		Wraps:    r.state.Wraps,
+		Previous: r.state.Previous,
+		Expires:  r.expires,
+	}
+}
+
+func (k keyEntry) remembered() remembered {
+	return remembered{
+		state:   counter.State{Value: k.Value, Cycle: k.Cycle, Wraps: k.Wraps, Previous: k.Previous},
+		expires: k.Expires,
+	}
+}
+
+// encode - formats entry as the log line: checksum of the payload, space, JSON payload and line feed.
+func (e entry) encode() ([]byte, error) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(fmt.Sprintf("%08x %s\n", crc32.ChecksumIEEE(payload), payload)), nil
+}
+
+// decodeEntry - parses the log line without trailing line feed.
+func decodeEntry(line []byte) (*entry, error) {
+	if len(line) < 10 || line[8] != ' ' {
+		return nil, errors.New("malformed entry")
+	}
+	var checksum uint32
+	if _, err := fmt.Sscanf(string(line[:8]), "%08x", &checksum); err != nil {
+		return nil, errors.Wrap(err, "malformed checksum")
+	}
+	payload := line[9:]
+	if crc32.ChecksumIEEE(payload) != checksum {
+		return nil, errors.New("checksum mismatch")
+	}
+	e := &entry{}
+	if err := json.Unmarshal(payload, e); err != nil {
+		return nil, errors.Wrap(err, "malformed payload")
+	}
+	return e, nil
+}
+
+// snapshotPath - returns path of snapshot file.
+func (s *storage) snapshotPath() string {
+	return s.path + ".snapshot"
+}
+
+// restore - loads counters from snapshot, replays the log over them and opens the log for appending.
+// Incomplete entry at the end of log is the result of interrupted write and it is cut off,
+// but any other broken entry is treated as corruption.
+func (s *storage) restore() error {
+	s.counters = map[int]*record{}
+	s.keys = map[int]map[string]remembered{}
+	data, err := ioutil.ReadFile(s.snapshotPath())
+	switch {
+	case os.IsNotExist(err):
+		// there were no snapshots yet
+	case err != nil:
+		return errors.Wrap(err, "failed to read snapshot")
+	default:
+		snap := &snapshot{}
+		if err := json.Unmarshal(data, snap); err != nil {
+			return errors.Wrap(err, "malformed snapshot")
+		}
+		if snap.Version != snapshotVersion {
+			return errors.Errorf("unsupported snapshot version (%d)", snap.Version)
+		}
+		for _, e := range snap.Counters {
+			s.counters[e.ID] = e.record()
+		}
+		for _, k := range snap.Keys {
+			s.remember(k)
+		}
+	}
+
+	data, err = ioutil.ReadFile(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to read log")
+	}
+	valid := 0
+	for valid < len(data) {
+		end := bytes.IndexByte(data[valid:], '\n')
+		if end < 0 {
+			// the latest entry was not written completely
+			break
+		}
+		e, err := decodeEntry(data[valid : valid+end])
+		if err != nil {
+			if valid+end+1 < len(data) {
+				return errors.Wrapf(err, "log is corrupted at offset %d", valid)
+			}
+			break
+		}
+		s.apply(e)
+		valid += end + 1
+	}
+	s.appended = bytes.Count(data[:valid], []byte{'\n'})
+
+	log, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return errors.Wrap(err, "failed to open log")
+	}
+	if valid < len(data) {
+		// cut off incomplete entry
+		if err := log.Truncate(int64(valid)); err != nil {
+			log.Close()
+			return errors.Wrap(err, "failed to discard incomplete log entry")
+		}
+		if err := log.Sync(); err != nil {
+			log.Close()
+			return errors.Wrap(err, "failed to discard incomplete log entry")
+		}
+	}
+	s.log = log
+	s.size = int64(valid)
+	return nil
+}
+
+// commit - appends counter state to the log and applies it to the counters after success.
+// Must be called under storage lock.
+func (s *storage) commit(counterID int, r *record) error {
+	e := newEntry(counterID, r)
+	return s.commitEntry(&e)
+}
+
+// commitEntry - appends the entry to the log and applies it after success.
+// Must be called under storage lock.
+func (s *storage) commitEntry(e *entry) error {
+	if s.log == nil {
+		return errors.New("storage is closed")
+	}
+	line, err := e.encode()
+	if err != nil {
+		return err
+	}
+	if _, err := s.log.Write(line); err != nil {
+		// discard partially written entry, so next entries will not follow garbage
+		s.log.Truncate(s.size)
+		return errors.Wrap(err, "failed to append log entry")
+	}
+	if s.policy == SyncAlways {
+		if err := s.log.Sync(); err != nil {
+			s.log.Truncate(s.size)
+			return errors.Wrap(err, "failed to flush log entry")
+		}
+	} else {
+		s.dirty = true
+	}
+	s.size += int64(len(line))
+	s.apply(e)
+	s.appended++
+	if s.snapshotEvery > 0 && s.appended >= s.snapshotEvery {
+		// the change is already committed with the log,
+		// so failed snapshot will be retried after next change
+		s.snapshot()
+	}
+	return nil
+}
+
+// apply - applies committed entry to the counters and remembers its idempotency key.
+// Must be called under storage lock.
+func (s *storage) apply(e *entry) {
+	s.counters[e.ID] = e.record()
+	if e.Key != nil {
+		s.remember(*e.Key)
+	}
+}
+
+// remember - keeps idempotency key unless it is expired.
+// Must be called under storage lock.
+func (s *storage) remember(k keyEntry) {
+	if !k.Expires.After(time.Now()) {
+		return
+	}
+	keys, ok := s.keys[k.ID]
+	if !ok {
+		keys = map[string]remembered{}
+		s.keys[k.ID] = keys
+	}
+	keys[k.Key] = k.remembered()
+}
+
+// snapshot - saves all counters and unexpired idempotency keys into snapshot file atomically and truncates the log.
+// Expired keys are forgotten. Must be called under storage lock.
+func (s *storage) snapshot() error {
+	snap := &snapshot{Version: snapshotVersion, Counters: make([]entry, 0, len(s.counters))}
+	for id, r := range s.counters {
+		snap.Counters = append(snap.Counters, newEntry(id, r))
+	}
+	now := time.Now()
+	for id, keys := range s.keys {
+		for key, r := range keys {
+			if !r.expires.After(now) {
+				delete(keys, key)
+				continue
+			}
+			snap.Keys = append(snap.Keys, newKeyEntry(id, key, r))
+		}
+	}
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	tmp := s.snapshotPath() + ".tmp"
+	if err := writeFileSync(tmp, data); err != nil {
+		return errors.Wrap(err, "failed to write snapshot")
+	}
+	if err := os.Rename(tmp, s.snapshotPath()); err != nil {
+		return errors.Wrap(err, "failed to replace snapshot")
+	}
+	if err := syncDir(filepath.Dir(s.path)); err != nil {
+		return errors.Wrap(err, "failed to flush snapshot")
+	}
+	// log entries are covered by snapshot now
+	if err := s.log.Truncate(0); err != nil {
+		return errors.Wrap(err, "failed to truncate log")
+	}
+	if err := s.log.Sync(); err != nil {
+		return errors.Wrap(err, "failed to truncate log")
+	}
+	s.size = 0
+	s.appended = 0
+	s.dirty = false
+	return nil
+}
+
+// writeFileSync - writes data into the file and flushes it to the disk.
+func writeFileSync(filename string, data []byte) error {
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// syncDir - flushes directory entries to the disk, so renamed or created files are persisted.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if e := d.Close(); err == nil {
+		err = e
+	}
+	return err
+}
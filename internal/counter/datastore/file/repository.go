@@ -0,0 +1,165 @@
+package file
+
+import (
+	"github.com/pkg/errors"
+	"github.com/wtask-go/auracounter/internal/counter"
+)
+
+// EnsureSettings - saves given settings for counter if it does not exist.
+func (s *storage) EnsureSettings(counterID int, defaults *counter.Settings) error {
+	if defaults == nil {
+		return errors.Errorf("file.EnsureSettings(#%d): unable to use nil settings", counterID)
+	}
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if _, ok := s.counters[counterID]; ok {
+		return nil
+	}
+	err := s.commit(counterID, &record{value: defaults.StartFrom, settings: *defaults, version: 1})
+	return errors.Wrapf(err, "file.EnsureSettings(#%d): failed", counterID)
+}
+
+// GetValue - return current counter value and cycle
+func (s *storage) GetValue(counterID int) (counter.State, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	c, ok := s.counters[counterID]
+	if !ok {
+		return counter.State{}, errors.Errorf("file.GetValue(#%d): counter not found", counterID)
+	}
+	return c.state(), nil
+}
+
+// GetSettings - return current counter settings and their version.
+func (s *storage) GetSettings(counterID int) (*counter.Settings, int, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	c, ok := s.counters[counterID]
+	if !ok {
+		return nil, 0, errors.Wrapf(counter.ErrNotFound, "file.GetSettings(#%d): failed", counterID)
+	}
+	settings := c.settings
+	return &settings, c.version, nil
+}
+
+// Increase - increase counter using previously stored settings without validating its consistency.
+// Method returns the value only after it was committed with the log according to sync policy.
+// If counter/counter settings were not prepared before calling `file.Increase`, method will fail.
+// See `file.EnsureSettings`.
+func (s *storage) Increase(counterID int) (counter.State, error) {
+	return s.changeState("Increase", counterID, func(c *record) (counter.State, error) {
+		return c.state().Increased(&c.settings)
+	})
+}
+
+// Decrease - decrease counter using previously stored settings, so it reverts the last increase.
+// If counter/counter settings were not prepared before calling `file.Decrease`, method will fail.
+func (s *storage) Decrease(counterID int) (counter.State, error) {
+	return s.changeState("Decrease", counterID, func(c *record) (counter.State, error) {
+		return c.state().Decreased(&c.settings)
+	})
+}
+
+// DecreaseBy - decrease counter `size` times at once using previously stored settings.
+// All decreases are committed with the single log entry.
+// If counter/counter settings were not prepared before calling `file.DecreaseBy`, method will fail.
+func (s *storage) DecreaseBy(counterID int, size int) (counter.State, error) {
+	return s.changeState("DecreaseBy", counterID, func(c *record) (counter.State, error) {
+		return c.state().DecreasedBy(&c.settings, size)
+	})
+}
+
+// Reset - return counter to its start value.
+// If counter/counter settings were not prepared before calling `file.Reset`, method will fail.
+func (s *storage) Reset(counterID int) (counter.State, error) {
+	return s.changeState("Reset", counterID, func(c *record) (counter.State, error) {
+		return c.state().Reset(&c.settings), nil
+	})
+}
+
+// Reserve - increase counter `size` times at once using previously stored settings.
+// Reserved block is committed with the single log entry.
+// If counter/counter settings were not prepared before calling `file.Reserve`, method will fail.
+func (s *storage) Reserve(counterID int, size int) ([]counter.Range, counter.State, error) {
+	var ranges []counter.Range
+	state, err := s.changeState("Reserve", counterID, func(c *record) (counter.State, error) {
+		var (
+			state counter.State
+			err   error
+		)
+		ranges, state, err = c.state().Reserved(&c.settings, size)
+		return state, err
+	})
+	if err != nil {
+		return nil, counter.State{}, err
+	}
+	return ranges, state, nil
+}
+
+// changeState - commits counter state calculated with `change` func under the lock.
+// `method` is used to describe errors only.
+func (s *storage) changeState(
+	method string,
+	counterID int,
+	change func(c *record) (counter.State, error),
+) (counter.State, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	c, ok := s.counters[counterID]
+	if !ok {
+		return counter.State{}, errors.Errorf("file.%s(#%d): counter not found", method, counterID)
+	}
+	state, err := change(c)
+	if err != nil {
+		return counter.State{}, errors.Wrapf(err, "file.%s(#%d): failed", method, counterID)
+	}
+	if state.Value == c.value && state.Cycle == c.cycle {
+		// nothing to commit
+		return state, nil
+	}
+	next := &record{value: state.Value, cycle: state.Cycle, settings: c.settings, version: c.version}
+	if err := s.commit(counterID, next); err != nil {
+		return counter.State{}, errors.Wrapf(err, "file.%s(#%d): failed", method, counterID)
+	}
+	return state, nil
+}
+
+// SetSettings - set new counter settings, current value of existing counter is changed according to mode.
+// New counter is created with start value.
+// When `version` is not zero, settings are changed only if it is equal to current version of settings.
+// Method returns previous settings (nil for new counter), the state after settings were applied
+// and new version of settings.
+func (s *storage) SetSettings(
+	counterID int,
+	settings *counter.Settings,
+	mode counter.ValueMode,
+	version int,
+) (*counter.Settings, counter.State, int, error) {
+	if settings == nil {
+		return nil, counter.State{}, 0, errors.Errorf("file.SetSettings(#%d): unable to use nil settings", counterID)
+	}
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	c, ok := s.counters[counterID]
+	if version != 0 && (!ok || c.version != version) {
+		return nil, counter.State{}, 0, errors.Wrapf(counter.ErrVersionMismatch, "file.SetSettings(#%d): failed", counterID)
+	}
+	var previous *counter.Settings
+	next := &record{value: settings.StartFrom, settings: *settings, version: 1}
+	state := counter.State{Value: next.value, Previous: next.value}
+	if ok {
+		original := c.settings
+		previous = &original
+		next.value, next.cycle, next.version = settings.Adjust(c.value, mode), c.cycle, c.version+1
+		state = counter.State{Value: next.value, Cycle: next.cycle, Previous: c.value}
+	}
+	if err := s.commit(counterID, next); err != nil {
+		return nil, counter.State{}, 0, errors.Wrapf(err, "file.SetSettings(#%d): failed to set %v", counterID, *settings)
+	}
+	return previous, state, next.version, nil
+}
+
+// state - returns current state of the counter.
+func (r *record) state() counter.State {
+	return counter.State{Value: r.value, Cycle: r.cycle}
+}
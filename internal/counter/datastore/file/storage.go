@@ -0,0 +1,262 @@
+package file
+
+import (
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/wtask-go/auracounter/internal/counter"
+)
+
+// SyncPolicy - defines when appended log entries are flushed to the disk.
+type SyncPolicy string
+
+const (
+	// SyncAlways - every change is flushed to the disk before it is reported as committed.
+	SyncAlways SyncPolicy = "always"
+	// SyncInterval - changes are flushed to the disk periodically,
+	// the latest changes made within sync interval may be lost after crash.
+	SyncInterval SyncPolicy = "interval"
+	// SyncNone - flushing is up to operating system.
+	SyncNone SyncPolicy = "none"
+)
+
+type (
+	storage struct {
+		mx       sync.Mutex
+		path     string
+		log      *os.File
+		size     int64 // size of valid log content
+		counters map[int]*record
+		// keys - remembered idempotency keys of counters
+		keys map[int]map[string]remembered
+		// durability and compaction preferences
+		policy        SyncPolicy
+		interval      time.Duration
+		snapshotEvery int
+		// appended - number of entries appended to the log since the latest snapshot
+		appended int
+		// dirty - log has entries which are not flushed to the disk yet
+		dirty bool
+		stop  chan struct{}
+		done  chan struct{}
+	}
+
+	// record - stored counter state
+	record struct {
+		value    int64
+		cycle    int
+		settings counter.Settings
+		// version - version of settings, it is increased by every change of settings
+		version int
+	}
+
+	// remembered - state of counter which is remembered with idempotency key
+	remembered struct {
+		state   counter.State
+		expires time.Time
+	}
+
+	storageOption func() (func(*storage), error)
+)
+
+// failedOption - helper to expose error from option builder
+func failedOption(err error) storageOption {
+	return func() (func(*storage), error) {
+		return nil, err
+	}
+}
+
+// properOption - helper to expose setter from option builder
+func properOption(setter func(*storage)) storageOption {
+	return func() (func(*storage), error) {
+		return setter, nil
+	}
+}
+
+// setup - set storage options
+func (s *storage) setup(options ...storageOption) error {
+	if s == nil {
+		return nil
+	}
+	for _, option := range options {
+		if option == nil {
+			continue
+		}
+		setter, err := option()
+		if err != nil {
+			return err
+		}
+		if setter != nil {
+			setter(s)
+		}
+	}
+	return nil
+}
+
+// WithSyncPolicy - defines durability of committed changes, SyncAlways is used by default.
+func WithSyncPolicy(policy SyncPolicy) storageOption {
+	switch policy {
+	case SyncAlways, SyncInterval, SyncNone:
+		return properOption(func(s *storage) {
+			s.policy = policy
+		})
+	default:
+		return failedOption(errors.Errorf("file.WithSyncPolicy: unknown policy %q", policy))
+	}
+}
+
+// WithSyncInterval - period of flushing changes to the disk for SyncInterval policy, 1 second by default.
+func WithSyncInterval(interval time.Duration) storageOption {
+	if interval <= 0 {
+		return failedOption(errors.Errorf("file.WithSyncInterval: invalid interval (%s)", interval))
+	}
+	return properOption(func(s *storage) {
+		s.interval = interval
+	})
+}
+
+// WithSnapshotEvery - storage takes snapshot of all counters and truncates the log
+// after given number of entries were appended to the log, 1000 by default.
+// Zero value disables periodic snapshots.
+func WithSnapshotEvery(entries int) storageOption {
+	if entries < 0 {
+		return failedOption(errors.Errorf("file.WithSnapshotEvery: negative number of entries (%d)", entries))
+	}
+	return properOption(func(s *storage) {
+		s.snapshotEvery = entries
+	})
+}
+
+// dsnOptions - converts DSN query params into storage options.
+// Supported params are `sync` (policy), `interval` (sync interval) and `snapshot` (entries between snapshots).
+func dsnOptions(query string) ([]storageOption, error) {
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	options := []storageOption{}
+	for name := range params {
+		value := params.Get(name)
+		switch name {
+		case "sync":
+			options = append(options, WithSyncPolicy(SyncPolicy(value)))
+		case "interval":
+			interval, err := time.ParseDuration(value)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid %q param", name)
+			}
+			options = append(options, WithSyncInterval(interval))
+		case "snapshot":
+			entries, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid %q param", name)
+			}
+			options = append(options, WithSnapshotEvery(entries))
+		default:
+			return nil, errors.Errorf("unknown param %q", name)
+		}
+	}
+	return options, nil
+}
+
+// NewStorage - implements counter.Storage interface to store cyclic incremental counters with local files.
+// DSN has format `file://path/to/counters.log?sync=always&interval=1s&snapshot=1000`,
+// where query params are optional and could be overridden by the given options.
+// Every change is appended to the log file, periodically all counters are saved into snapshot file
+// (the same path with `.snapshot` suffix) and the log is truncated.
+// Storage restores the latest committed state from snapshot and log when it is created,
+// incompletely written log entry (after crash) is discarded.
+func NewStorage(dsn string, options ...storageOption) (counter.Storage, error) {
+	s := &storage{
+		policy:        SyncAlways,
+		interval:      time.Second,
+		snapshotEvery: 1000,
+	}
+	parts := strings.SplitN(strings.TrimPrefix(dsn, "file://"), "?", 2)
+	if s.path = parts[0]; s.path == "" {
+		return nil, errors.New("file.NewStorage: required DSN is missed")
+	}
+	if len(parts) > 1 {
+		dsnOpts, err := dsnOptions(parts[1])
+		if err != nil {
+			return nil, errors.Wrap(err, "file.NewStorage: DSN error")
+		}
+		options = append(dsnOpts, options...)
+	}
+	if err := s.setup(options...); err != nil {
+		return nil, errors.Wrap(err, "file.NewStorage: option error")
+	}
+	if err := s.restore(); err != nil {
+		return nil, errors.Wrap(err, "file.NewStorage: failed to restore counters")
+	}
+	if s.policy == SyncInterval {
+		s.stop = make(chan struct{})
+		s.done = make(chan struct{})
+		go s.syncPeriodically()
+	}
+	return s, nil
+}
+
+// syncPeriodically - flushes log to the disk according to SyncInterval policy until storage is closed.
+func (s *storage) syncPeriodically() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.mx.Lock()
+			if s.dirty && s.log != nil && s.log.Sync() == nil {
+				s.dirty = false
+			}
+			s.mx.Unlock()
+		}
+	}
+}
+
+// EnsureLatest - takes snapshot of all counters with the latest format version and truncates the log.
+func (s *storage) EnsureLatest() error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.log == nil {
+		return errors.New("file.EnsureLatest: storage is closed")
+	}
+	return errors.Wrap(s.snapshot(), "file.EnsureLatest: failed")
+}
+
+// Close - flushes all changes to the disk and closes the log.
+func (s *storage) Close() error {
+	if s == nil {
+		return nil
+	}
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+		s.stop = nil
+	}
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.log == nil {
+		return nil
+	}
+	err := s.log.Sync()
+	if e := s.log.Close(); err == nil {
+		err = e
+	}
+	s.log = nil
+	return errors.Wrap(err, "file.Close: failed")
+}
+
+func (s *storage) Repository() counter.Repository {
+	if s == nil {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,128 @@
+package gormcore
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+	"github.com/wtask-go/auracounter/internal/counter"
+)
+
+// CounterAudit - audit record of counter change,
+// settings are JSON-encoded and are set for settings change only
+type CounterAudit struct {
+	ID          int64          `gorm:"primary_key;column:id"`
+	CounterID   int            `gorm:"not null;index;column:counter_id"`
+	Time        time.Time      `gorm:"not null;column:time"`
+	Operation   string         `gorm:"not null;size:16;column:operation"`
+	OldValue    int64          `gorm:"not null;column:old_value"`
+	NewValue    int64          `gorm:"not null;column:new_value"`
+	OldSettings sql.NullString `gorm:"type:text;column:old_settings"`
+	NewSettings sql.NullString `gorm:"type:text;column:new_settings"`
+	RequestID   string         `gorm:"not null;size:255;column:request_id"`
+	Client      string         `gorm:"not null;size:255;column:client"`
+}
+
+// auditLog - implements counter.AuditLog with `counter_audit` table (with table prefix)
+type auditLog struct {
+	db *gorm.DB
+	// after - condition to select records of the counter after given ID
+	after string
+	// order - column to sort records in ascending order
+	order string
+}
+
+// NewAuditLog - builds counter.AuditLog stored in CounterAudit table of given database.
+// Condition `after` selects records of the counter with ID greater than given one and `order` is ID column,
+// both are quoted according to database dialect, e.g. "`counter_id` = ? AND `id` > ?" and "`id`" for mysql.
+func NewAuditLog(db *gorm.DB, after, order string) counter.AuditLog {
+	return &auditLog{db: db, after: after, order: order}
+}
+
+// Append - inserts the record and assigns its ID.
+func (l *auditLog) Append(record *counter.AuditRecord) error {
+	if record == nil {
+		return errors.New("gormcore.Append: unable to use nil record")
+	}
+	a := &CounterAudit{
+		CounterID: record.CounterID,
+		Time:      record.Time,
+		Operation: record.Operation,
+		OldValue:  record.OldValue,
+		NewValue:  record.NewValue,
+		RequestID: record.RequestID,
+		Client:    record.Client,
+	}
+	var err error
+	if a.OldSettings, err = encodeSettings(record.OldSettings); err != nil {
+		return errors.Wrapf(err, "gormcore.Append(#%d): failed", record.CounterID)
+	}
+	if a.NewSettings, err = encodeSettings(record.NewSettings); err != nil {
+		return errors.Wrapf(err, "gormcore.Append(#%d): failed", record.CounterID)
+	}
+	if err := l.db.Create(a).Error; err != nil {
+		return errors.Wrapf(err, "gormcore.Append(#%d): failed", record.CounterID)
+	}
+	record.ID = a.ID
+	return nil
+}
+
+// List - returns up to `limit` records of the counter with ID greater than `after` in ascending order.
+func (l *auditLog) List(counterID int, after int64, limit int) ([]counter.AuditRecord, error) {
+	rows := []CounterAudit{}
+	err := l.db.
+		Where(l.after, counterID, after).
+		Order(l.order).
+		Limit(limit).
+		Find(&rows).
+		Error
+	if err != nil {
+		return nil, errors.Wrapf(err, "gormcore.List(#%d): failed", counterID)
+	}
+	records := make([]counter.AuditRecord, len(rows))
+	for i, a := range rows {
+		records[i] = counter.AuditRecord{
+			ID:        a.ID,
+			CounterID: a.CounterID,
+			Time:      a.Time,
+			Operation: a.Operation,
+			OldValue:  a.OldValue,
+			NewValue:  a.NewValue,
+			RequestID: a.RequestID,
+			Client:    a.Client,
+		}
+		if records[i].OldSettings, err = decodeSettings(a.OldSettings); err != nil {
+			return nil, errors.Wrapf(err, "gormcore.List(#%d): failed", counterID)
+		}
+		if records[i].NewSettings, err = decodeSettings(a.NewSettings); err != nil {
+			return nil, errors.Wrapf(err, "gormcore.List(#%d): failed", counterID)
+		}
+	}
+	return records, nil
+}
+
+// encodeSettings - encodes settings as JSON, nil settings are encoded as NULL.
+func encodeSettings(settings *counter.Settings) (sql.NullString, error) {
+	if settings == nil {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return sql.NullString{}, errors.Wrap(err, "unable to encode settings")
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+// decodeSettings - decodes settings from JSON, NULL is decoded as nil settings.
+func decodeSettings(data sql.NullString) (*counter.Settings, error) {
+	if !data.Valid {
+		return nil, nil
+	}
+	settings := &counter.Settings{}
+	if err := json.Unmarshal([]byte(data.String), settings); err != nil {
+		return nil, errors.Wrap(err, "unable to decode settings")
+	}
+	return settings, nil
+}
@@ -0,0 +1,5 @@
+/*
+Package gormcore contains common logic and models of gorm-based counter datastores (mysql, postgres and sqlite).
+Statements which depend on database dialect (e.g. quoting of identifiers) are passed by datastores.
+*/
+package gormcore
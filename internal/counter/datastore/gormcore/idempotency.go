@@ -0,0 +1,94 @@
+package gormcore
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+	"github.com/wtask-go/auracounter/internal/counter"
+)
+
+// CounterIdempotency - state of counter which is remembered with idempotency key until it expires
+type CounterIdempotency struct {
+	CounterID int       `gorm:"primary_key;auto_increment:false;column:counter_id"`
+	Key       string    `gorm:"primary_key;size:128;column:key"`
+	Value     int64     `gorm:"not null;column:value"`
+	Cycle     int       `gorm:"not null;column:cycle"`
+	Wraps     int       `gorm:"not null;column:wraps"`
+	Previous  int64     `gorm:"not null;column:previous"`
+	ExpiresAt time.Time `gorm:"not null;index;column:expires_at"`
+}
+
+// Idempotency - dialect-specific statements of idempotent increase, see IncreaseOnce.
+type Idempotency struct {
+	// Lock - query option to lock counter row until the transaction ends, e.g. "FOR UPDATE",
+	// it is empty when the transaction locks the whole database
+	Lock string
+	// Expired - condition to select expired keys of the counter with columns quoted according to database dialect,
+	// e.g. "`counter_id` = ? AND `expires_at` <= ?" for mysql
+	Expired string
+}
+
+// IncreaseOnce - common transaction of counter.IdempotentRepository implementations.
+// Counter row is loaded into `c` with Lock option, so concurrent calls with the same key are serialized.
+// State remembered with unexpired key is returned as is, otherwise `increase` changes loaded counter
+// within the transaction, expired keys of the counter are deleted and the new state is remembered with the key.
+func (i Idempotency) IncreaseOnce(
+	db *gorm.DB,
+	c interface{},
+	counterID int,
+	key string,
+	ttl time.Duration,
+	increase func(tx *gorm.DB) (counter.State, error),
+) (counter.State, bool, error) {
+	if key == "" {
+		return counter.State{}, false, errors.New("empty key")
+	}
+	now := time.Now().UTC()
+	tx := db.Begin()
+	if tx.Error != nil {
+		return counter.State{}, false, errors.Wrap(tx.Error, "failed to begin transaction")
+	}
+	query := tx
+	if i.Lock != "" {
+		query = tx.Set("gorm:query_option", i.Lock)
+	}
+	if err := query.First(c, counterID).Error; err != nil {
+		tx.Rollback()
+		// same here if record not found
+		return counter.State{}, false, errors.Wrap(err, "failed to get counter")
+	}
+	r := &CounterIdempotency{}
+	err := tx.Where(&CounterIdempotency{CounterID: counterID, Key: key}).First(r).Error
+	switch {
+	case err == nil && r.ExpiresAt.After(now):
+		tx.Rollback()
+		return counter.State{Value: r.Value, Cycle: r.Cycle, Wraps: r.Wraps, Previous: r.Previous}, true, nil
+	case err != nil && !gorm.IsRecordNotFoundError(err):
+		tx.Rollback()
+		return counter.State{}, false, errors.Wrap(err, "failed to get key")
+	}
+	state, err := increase(tx)
+	if err == nil {
+		err = tx.Where(i.Expired, counterID, now).Delete(&CounterIdempotency{}).Error
+	}
+	if err == nil {
+		err = tx.Create(&CounterIdempotency{
+			CounterID: counterID,
+			Key:       key,
+			Value:     state.Value,
+			Cycle:     state.Cycle,
+			Wraps:     state.Wraps,
+			Previous:  state.Previous,
+			ExpiresAt: now.Add(ttl),
+		}).Error
+	}
+	if err != nil {
+		tx.Rollback()
+		return counter.State{}, false, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return counter.State{}, false, errors.Wrap(err, "commit failed")
+	}
+	return state, false, nil
+}
@@ -0,0 +1,54 @@
+package memory
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/wtask-go/auracounter/internal/counter"
+)
+
+// auditLog - in-memory implementation of counter.AuditLog
+type auditLog struct {
+	mx      sync.RWMutex
+	lastID  int64
+	records map[int][]counter.AuditRecord
+}
+
+// NewAuditLog - implements counter.AuditLog interface to keep counter changes in process memory.
+// All records are lost when the process exits.
+func NewAuditLog() counter.AuditLog {
+	return &auditLog{
+		records: map[int][]counter.AuditRecord{},
+	}
+}
+
+// Append - saves copy of the record and assigns its ID.
+func (l *auditLog) Append(record *counter.AuditRecord) error {
+	if record == nil {
+		return errors.New("memory.Append: unable to use nil record")
+	}
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	l.lastID++
+	record.ID = l.lastID
+	l.records[record.CounterID] = append(l.records[record.CounterID], *record)
+	return nil
+}
+
+// List - returns up to `limit` records of the counter with ID greater than `after` in ascending order.
+func (l *auditLog) List(counterID int, after int64, limit int) ([]counter.AuditRecord, error) {
+	if limit < 1 {
+		return nil, errors.Errorf("memory.List(#%d): invalid limit (%d)", counterID, limit)
+	}
+	l.mx.RLock()
+	defer l.mx.RUnlock()
+	records := l.records[counterID]
+	// records are appended with ascending IDs
+	first := sort.Search(len(records), func(i int) bool { return records[i].ID > after })
+	last := len(records)
+	if last-first > limit {
+		last = first + limit
+	}
+	return append([]counter.AuditRecord{}, records[first:last]...), nil
+}
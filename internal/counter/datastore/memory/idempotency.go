@@ -0,0 +1,69 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/wtask-go/auracounter/internal/counter"
+)
+
+// minSweep - the least number of remembered keys of counter to look for expired ones
+const minSweep = 64
+
+type (
+	// remembered - state of counter which is remembered with idempotency key
+	remembered struct {
+		state   counter.State
+		expires time.Time
+	}
+
+	// rememberedKeys - idempotency keys of counter
+	rememberedKeys struct {
+		states map[string]remembered
+		// sweepAt - number of keys to drop expired ones, so the cost of sweep is amortized
+		sweepAt int
+	}
+)
+
+// IncreaseOnce - implements counter.IdempotentRepository interface.
+// Expired keys are dropped when the number of keys of the counter is doubled since the last sweep.
+func (s *storage) IncreaseOnce(counterID int, key string, ttl time.Duration) (counter.State, bool, error) {
+	if key == "" {
+		return counter.State{}, false, errors.Errorf("memory.IncreaseOnce(#%d): empty key", counterID)
+	}
+	var replay *counter.State
+	state, err := s.changeState("IncreaseOnce", counterID, func(c *record) (counter.State, error) {
+		now := time.Now()
+		keys, ok := s.remembered[counterID]
+		if !ok {
+			keys = &rememberedKeys{states: map[string]remembered{}, sweepAt: minSweep}
+			s.remembered[counterID] = keys
+		}
+		if r, ok := keys.states[key]; ok && r.expires.After(now) {
+			replay = &r.state
+			// counter is kept unchanged
+			return c.state(), nil
+		}
+		state, err := c.state().Increased(&c.settings)
+		if err != nil {
+			return state, err
+		}
+		if len(keys.states) >= keys.sweepAt {
+			for k, r := range keys.states {
+				if !r.expires.After(now) {
+					delete(keys.states, k)
+				}
+			}
+			keys.sweepAt = 2*len(keys.states) + minSweep
+		}
+		keys.states[key] = remembered{state: state, expires: now.Add(ttl)}
+		return state, nil
+	})
+	if err != nil {
+		return counter.State{}, false, err
+	}
+	if replay != nil {
+		return *replay, true, nil
+	}
+	return state, false, nil
+}
@@ -0,0 +1,26 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/wtask-go/auracounter/internal/counter"
+	"github.com/wtask-go/auracounter/internal/counter/datastore/datastoretest"
+)
+
+func TestDatastore(t *testing.T) {
+	datastoretest.RunRepositorySuite(t, func(t *testing.T) (counter.Repository, func()) {
+		s := NewStorage()
+		if err := s.EnsureLatest(); err != nil {
+			t.Fatalf("EnsureLatest() for memory failed: %v", err)
+		}
+		return s.Repository(), func() { s.Close() }
+	})
+}
+
+func TestAuditLog(t *testing.T) {
+	l := NewAuditLog()
+	datastoretest.RunAuditLogSuite(t, l)
+	if _, err := l.List(1, 0, 0); err == nil {
+		t.Error("List(1, 0, 0): expected error")
+	}
+}
@@ -0,0 +1,151 @@
+package memory
+
+import (
+	"github.com/pkg/errors"
+	"github.com/wtask-go/auracounter/internal/counter"
+)
+
+// EnsureSettings - saves given settings for counter if it does not exist.
+func (s *storage) EnsureSettings(counterID int, defaults *counter.Settings) error {
+	if defaults == nil {
+		return errors.Errorf("memory.EnsureSettings(#%d): unable to use nil settings", counterID)
+	}
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if _, ok := s.counters[counterID]; !ok {
+		s.counters[counterID] = &record{value: defaults.StartFrom, settings: *defaults, version: 1}
+	}
+	return nil
+}
+
+// GetValue - return current counter value and cycle
+func (s *storage) GetValue(counterID int) (counter.State, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	c, ok := s.counters[counterID]
+	if !ok {
+		return counter.State{}, errors.Errorf("memory.GetValue(#%d): counter not found", counterID)
+	}
+	return c.state(), nil
+}
+
+// GetSettings - return current counter settings and their version.
+func (s *storage) GetSettings(counterID int) (*counter.Settings, int, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	c, ok := s.counters[counterID]
+	if !ok {
+		return nil, 0, errors.Wrapf(counter.ErrNotFound, "memory.GetSettings(#%d): failed", counterID)
+	}
+	settings := c.settings
+	return &settings, c.version, nil
+}
+
+// Increase - increase counter using previously stored settings without validating its consistency.
+// If counter/counter settings were not prepared before calling `memory.Increase`, method will fail.
+// See `memory.EnsureSettings`.
+func (s *storage) Increase(counterID int) (counter.State, error) {
+	return s.changeState("Increase", counterID, func(c *record) (counter.State, error) {
+		return c.state().Increased(&c.settings)
+	})
+}
+
+// Decrease - decrease counter using previously stored settings, so it reverts the last increase.
+// If counter/counter settings were not prepared before calling `memory.Decrease`, method will fail.
+func (s *storage) Decrease(counterID int) (counter.State, error) {
+	return s.changeState("Decrease", counterID, func(c *record) (counter.State, error) {
+		return c.state().Decreased(&c.settings)
+	})
+}
+
+// DecreaseBy - decrease counter `size` times at once using previously stored settings.
+// If counter/counter settings were not prepared before calling `memory.DecreaseBy`, method will fail.
+func (s *storage) DecreaseBy(counterID int, size int) (counter.State, error) {
+	return s.changeState("DecreaseBy", counterID, func(c *record) (counter.State, error) {
+		return c.state().DecreasedBy(&c.settings, size)
+	})
+}
+
+// Reset - return counter to its start value.
+// If counter/counter settings were not prepared before calling `memory.Reset`, method will fail.
+func (s *storage) Reset(counterID int) (counter.State, error) {
+	return s.changeState("Reset", counterID, func(c *record) (counter.State, error) {
+		return c.state().Reset(&c.settings), nil
+	})
+}
+
+// Reserve - increase counter `size` times at once using previously stored settings.
+// If counter/counter settings were not prepared before calling `memory.Reserve`, method will fail.
+func (s *storage) Reserve(counterID int, size int) ([]counter.Range, counter.State, error) {
+	var ranges []counter.Range
+	state, err := s.changeState("Reserve", counterID, func(c *record) (counter.State, error) {
+		var (
+			state counter.State
+			err   error
+		)
+		ranges, state, err = c.state().Reserved(&c.settings, size)
+		return state, err
+	})
+	if err != nil {
+		return nil, counter.State{}, err
+	}
+	return ranges, state, nil
+}
+
+// changeState - replaces counter state with the result of `change` func under the lock.
+// `method` is used to describe errors only.
+func (s *storage) changeState(
+	method string,
+	counterID int,
+	change func(c *record) (counter.State, error),
+) (counter.State, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	c, ok := s.counters[counterID]
+	if !ok {
+		return counter.State{}, errors.Errorf("memory.%s(#%d): counter not found", method, counterID)
+	}
+	state, err := change(c)
+	if err != nil {
+		return counter.State{}, errors.Wrapf(err, "memory.%s(#%d): failed", method, counterID)
+	}
+	c.value, c.cycle = state.Value, state.Cycle
+	return state, nil
+}
+
+// SetSettings - set new counter settings, current value of existing counter is changed according to mode.
+// New counter is created with start value.
+// When `version` is not zero, settings are changed only if it is equal to current version of settings.
+// Method returns previous settings (nil for new counter), the state after settings were applied
+// and new version of settings.
+func (s *storage) SetSettings(
+	counterID int,
+	settings *counter.Settings,
+	mode counter.ValueMode,
+	version int,
+) (*counter.Settings, counter.State, int, error) {
+	if settings == nil {
+		return nil, counter.State{}, 0, errors.Errorf("memory.SetSettings(#%d): unable to use nil settings", counterID)
+	}
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	c, ok := s.counters[counterID]
+	if version != 0 && (!ok || c.version != version) {
+		return nil, counter.State{}, 0, errors.Wrapf(counter.ErrVersionMismatch, "memory.SetSettings(#%d): failed", counterID)
+	}
+	if !ok {
+		s.counters[counterID] = &record{value: settings.StartFrom, settings: *settings, version: 1}
+		return nil, counter.State{Value: settings.StartFrom, Previous: settings.StartFrom}, 1, nil
+	}
+	previous, state := c.settings, c.state()
+	state.Previous, state.Value = c.value, settings.Adjust(c.value, mode)
+	c.value = state.Value
+	c.settings = *settings
+	c.version++
+	return &previous, state, c.version, nil
+}
+
+// state - returns current state of the counter.
+func (r *record) state() counter.State {
+	return counter.State{Value: r.value, Cycle: r.cycle}
+}
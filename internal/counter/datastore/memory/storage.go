@@ -0,0 +1,59 @@
+package memory
+
+import (
+	"sync"
+
+	"github.com/wtask-go/auracounter/internal/counter"
+)
+
+type (
+	storage struct {
+		mx       sync.Mutex
+		counters map[int]*record
+		// remembered - idempotency keys of counters, see `memory.IncreaseOnce`
+		remembered map[int]*rememberedKeys
+	}
+
+	// record - stored counter state
+	record struct {
+		value    int64
+		cycle    int
+		settings counter.Settings
+		// version - version of settings, it is increased by every change of settings
+		version int
+	}
+)
+
+// NewStorage - implements counter.Storage interface to store cyclic incremental counters in process memory.
+// All counters are lost when the storage is closed or the process exits,
+// so it is suitable for local development and testing.
+func NewStorage() counter.Storage {
+	return &storage{
+		counters:   map[int]*record{},
+		remembered: map[int]*rememberedKeys{},
+	}
+}
+
+// EnsureLatest - in-memory storage is always up-to-date, method does nothing.
+func (s *storage) EnsureLatest() error {
+	return nil
+}
+
+// Close - drops all stored counters and idempotency keys.
+func (s *storage) Close() error {
+	if s == nil {
+		return nil
+	}
+	s.mx.Lock()
+	s.counters = map[int]*record{}
+	s.remembered = map[int]*rememberedKeys{}
+	s.mx.Unlock()
+	return nil
+}
+
+func (s *storage) Repository() counter.Repository {
+	if s == nil {
+		return nil
+	}
+	return s
+}
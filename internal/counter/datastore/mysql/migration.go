@@ -0,0 +1,345 @@
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/wtask-go/auracounter/internal/counter/datastore/gormcore"
+	"github.com/wtask-go/auracounter/internal/counter/datastore/mysql/model"
+)
+
+type (
+	// Migrator - manages versioned schema of mysql datastore.
+	// Applied versions are stored in `schema_version` table (with table prefix).
+	Migrator interface {
+		// SchemaVersion - returns current version of database schema, zero version means empty database.
+		SchemaVersion() (int, error)
+		// Migrate - migrates schema up or down to target version step by step and returns applied steps.
+		// In dry-run mode planned steps are returned, but database is not changed.
+		Migrate(target int, dryRun bool) ([]MigrationStep, error)
+	}
+
+	// MigrationStep - single change of database schema.
+	MigrationStep struct {
+		// Version - version of migration
+		Version int
+		// Description - short description of migration
+		Description string
+		// Up - direction of the step, migration is reverted when it is false
+		Up bool
+		// Statements - SQL statements of the step
+		Statements []string
+	}
+
+	// migration - versioned change of database schema with statements to apply and to revert it.
+	// `{{counter}}`, `{{counter_audit}}`, `{{counter_idempotency}}` and `{{counter_outbox}}` placeholders
+	// within statements are replaced with quoted names of counter, audit, idempotency and outbox tables.
+	migration struct {
+		version     int
+		description string
+		up          []string
+		down        []string
+		// column - name of column added by migration, it is used to detect version of unversioned schema
+		column string
+	}
+)
+
+// migrations - ordered changes of database schema, new migration must be appended with the next version.
+var migrations = []migration{
+	{
+		version:     1,
+		description: "create counter table",
+		up: []string{
+			"CREATE TABLE {{counter}} (" +
+				"`counter_id` INT NOT NULL, " +
+				"`created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, " +
+				"`updated_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP, " +
+				"`value` INT NOT NULL DEFAULT '0', " +
+				"`increment` INT NOT NULL DEFAULT '1', " +
+				"`lower` INT NOT NULL DEFAULT '0', " +
+				"`upper` INT NOT NULL DEFAULT '1', " +
+				"PRIMARY KEY (`counter_id`)" +
+				") COLLATE='utf8_general_ci' ENGINE=InnoDB",
+		},
+		down: []string{"DROP TABLE {{counter}}"},
+	},
+	{
+		version:     2,
+		description: "add counter start value",
+		up:          []string{"ALTER TABLE {{counter}} ADD COLUMN `start_from` INT NOT NULL DEFAULT '0'"},
+		down:        []string{"ALTER TABLE {{counter}} DROP COLUMN `start_from`"},
+		column:      "start_from",
+	},
+	{
+		version:     3,
+		description: "add counter overflow policy",
+		up:          []string{"ALTER TABLE {{counter}} ADD COLUMN `overflow` INT NOT NULL DEFAULT '0'"},
+		down:        []string{"ALTER TABLE {{counter}} DROP COLUMN `overflow`"},
+		column:      "overflow",
+	},
+	{
+		version:     4,
+		description: "add counter cycle",
+		up:          []string{"ALTER TABLE {{counter}} ADD COLUMN `cycle` INT NOT NULL DEFAULT '0'"},
+		down:        []string{"ALTER TABLE {{counter}} DROP COLUMN `cycle`"},
+		column:      "cycle",
+	},
+	{
+		version:     5,
+		description: "convert counter values to BIGINT",
+		up: []string{
+			"ALTER TABLE {{counter}} " +
+				"MODIFY COLUMN `value` BIGINT NOT NULL DEFAULT '0', " +
+				"MODIFY COLUMN `increment` BIGINT NOT NULL DEFAULT '1', " +
+				"MODIFY COLUMN `lower` BIGINT NOT NULL DEFAULT '0', " +
+				"MODIFY COLUMN `upper` BIGINT NOT NULL DEFAULT '1', " +
+				"MODIFY COLUMN `start_from` BIGINT NOT NULL DEFAULT '0'",
+		},
+		// values out of INT range make the statement fail
+		down: []string{
+			"ALTER TABLE {{counter}} " +
+				"MODIFY COLUMN `value` INT NOT NULL DEFAULT '0', " +
+				"MODIFY COLUMN `increment` INT NOT NULL DEFAULT '1', " +
+				"MODIFY COLUMN `lower` INT NOT NULL DEFAULT '0', " +
+				"MODIFY COLUMN `upper` INT NOT NULL DEFAULT '1', " +
+				"MODIFY COLUMN `start_from` INT NOT NULL DEFAULT '0'",
+		},
+	},
+	{
+		version:     6,
+		description: "create counter audit table",
+		up: []string{
+			"CREATE TABLE {{counter_audit}} (" +
+				"`id` BIGINT NOT NULL AUTO_INCREMENT, " +
+				"`counter_id` INT NOT NULL, " +
+				"`time` DATETIME(6) NOT NULL, " +
+				"`operation` VARCHAR(16) NOT NULL, " +
+				"`old_value` BIGINT NOT NULL, " +
+				"`new_value` BIGINT NOT NULL, " +
+				"`old_settings` TEXT NULL, " +
+				"`new_settings` TEXT NULL, " +
+				"`request_id` VARCHAR(255) NOT NULL DEFAULT '', " +
+				"`client` VARCHAR(255) NOT NULL DEFAULT '', " +
+				"PRIMARY KEY (`id`), " +
+				"KEY `counter_id` (`counter_id`, `id`)" +
+				") COLLATE='utf8_general_ci' ENGINE=InnoDB",
+		},
+		down: []string{"DROP TABLE {{counter_audit}}"},
+	},
+	{
+		version:     7,
+		description: "create counter idempotency table",
+		up: []string{
+			"CREATE TABLE {{counter_idempotency}} (" +
+				"`counter_id` INT NOT NULL, " +
+				"`key` VARCHAR(128) COLLATE 'utf8_bin' NOT NULL, " +
+				"`value` BIGINT NOT NULL, " +
+				"`cycle` INT NOT NULL, " +
+				"`wraps` INT NOT NULL, " +
+				"`previous` BIGINT NOT NULL, " +
+				"`expires_at` DATETIME(6) NOT NULL, " +
+				"PRIMARY KEY (`counter_id`, `key`), " +
+				"KEY `expires_at` (`expires_at`)" +
+				") COLLATE='utf8_general_ci' ENGINE=InnoDB",
+		},
+		down: []string{"DROP TABLE {{counter_idempotency}}"},
+	},
+	{
+		version:     8,
+		description: "add counter settings version",
+		up:          []string{"ALTER TABLE {{counter}} ADD COLUMN `version` INT NOT NULL DEFAULT '1'"},
+		down:        []string{"ALTER TABLE {{counter}} DROP COLUMN `version`"},
+	},
+	{
+		version:     9,
+		description: "create counter outbox table",
+		up: []string{
+			"CREATE TABLE {{counter_outbox}} (" +
+				"`id` BIGINT NOT NULL AUTO_INCREMENT, " +
+				"`counter_id` INT NOT NULL, " +
+				"`time` DATETIME(6) NOT NULL, " +
+				"`payload` TEXT NOT NULL, " +
+				"PRIMARY KEY (`id`)" +
+				") COLLATE='utf8_general_ci' ENGINE=InnoDB",
+		},
+		down: []string{"DROP TABLE {{counter_outbox}}"},
+	},
+	{
+		version:     10,
+		description: "add counter outbox dead-letter flag",
+		up: []string{
+			"ALTER TABLE {{counter_outbox}} " +
+				"ADD COLUMN `dead` TINYINT(1) NOT NULL DEFAULT '0', " +
+				"ADD KEY `dead` (`dead`, `id`)",
+		},
+		down: []string{"ALTER TABLE {{counter_outbox}} DROP KEY `dead`, DROP COLUMN `dead`"},
+	},
+}
+
+// unversionedMigrations - number of migrations which were applied by previous versions without versioning,
+// schema version is detected within this range only.
+const unversionedMigrations = 5
+
+// LatestSchemaVersion - returns the latest known version of mysql datastore schema.
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// plan - returns ordered steps to migrate schema from current to target version.
+// Statements are returned as is, without placeholders replacement.
+func plan(current, target int) ([]MigrationStep, error) {
+	latest := LatestSchemaVersion()
+	if current < 0 || current > latest {
+		return nil, fmt.Errorf("unknown current schema version (%d), the latest known is %d", current, latest)
+	}
+	if target < 0 || target > latest {
+		return nil, fmt.Errorf("target schema version (%d) is out of the range [0:%d]", target, latest)
+	}
+	steps := []MigrationStep{}
+	if current <= target {
+		for _, m := range migrations {
+			if m.version > current && m.version <= target {
+				steps = append(steps, MigrationStep{Version: m.version, Description: m.description, Up: true, Statements: m.up})
+			}
+		}
+		return steps, nil
+	}
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if m.version <= current && m.version > target {
+			steps = append(steps, MigrationStep{Version: m.version, Description: m.description, Up: false, Statements: m.down})
+		}
+	}
+	return steps, nil
+}
+
+// SchemaVersion - implements Migrator interface.
+func (s *storage) SchemaVersion() (int, error) {
+	version, err := s.schemaVersion()
+	return version, errors.Wrap(err, "mysql.SchemaVersion: failed")
+}
+
+// Migrate - implements Migrator interface.
+func (s *storage) Migrate(target int, dryRun bool) ([]MigrationStep, error) {
+	steps, err := s.migrate(target, dryRun)
+	return steps, errors.Wrap(err, "mysql.Migrate: failed")
+}
+
+// migrate - plans and applies migration steps, returns applied steps also on failure.
+func (s *storage) migrate(target int, dryRun bool) ([]MigrationStep, error) {
+	current, err := s.schemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	steps, err := plan(current, target)
+	if err != nil {
+		return nil, err
+	}
+	tables := strings.NewReplacer(
+		"{{counter}}", s.db.NewScope(&model.Counter{}).QuotedTableName(),
+		"{{counter_audit}}", s.db.NewScope(&gormcore.CounterAudit{}).QuotedTableName(),
+		"{{counter_idempotency}}", s.db.NewScope(&gormcore.CounterIdempotency{}).QuotedTableName(),
+		"{{counter_outbox}}", s.db.NewScope(&model.CounterOutbox{}).QuotedTableName(),
+	)
+	for i := range steps {
+		statements := make([]string, len(steps[i].Statements))
+		for j, statement := range steps[i].Statements {
+			statements[j] = tables.Replace(statement)
+		}
+		steps[i].Statements = statements
+	}
+	if dryRun {
+		return steps, nil
+	}
+	if err := s.ensureVersionTable(current); err != nil {
+		return nil, err
+	}
+	for i, step := range steps {
+		for _, statement := range step.Statements {
+			if err := s.db.Exec(statement).Error; err != nil {
+				return steps[:i], errors.Wrapf(err, "unable to apply step of version %d", step.Version)
+			}
+		}
+		if step.Up {
+			err = s.db.Create(&model.SchemaVersion{Version: step.Version, Description: step.Description}).Error
+		} else {
+			err = s.db.Where("`version` = ?", step.Version).Delete(&model.SchemaVersion{}).Error
+		}
+		if err != nil {
+			return steps[:i], errors.Wrapf(err, "unable to record schema version %d", step.Version)
+		}
+	}
+	return steps, nil
+}
+
+// schemaVersion - returns the latest applied version of schema.
+// Version of schema created before migrations were introduced is detected by existing columns.
+func (s *storage) schemaVersion() (int, error) {
+	if !s.db.HasTable(&model.SchemaVersion{}) {
+		return s.detectVersion()
+	}
+	version := sql.NullInt64{}
+	err := s.db.Model(&model.SchemaVersion{}).Select("MAX(`version`)").Row().Scan(&version)
+	return int(version.Int64), err
+}
+
+// detectVersion - returns version of unversioned schema.
+func (s *storage) detectVersion() (int, error) {
+	if !s.db.HasTable(&model.Counter{}) {
+		return 0, nil
+	}
+	table := s.db.NewScope(&model.Counter{}).TableName()
+	version := 1
+	for _, m := range migrations[1:unversionedMigrations] {
+		if m.column != "" {
+			if !s.db.Dialect().HasColumn(table, m.column) {
+				break
+			}
+			version = m.version
+			continue
+		}
+		// the only migration without column changes types of counter values
+		dataType := ""
+		err := s.db.Raw(
+			"SELECT `DATA_TYPE` FROM `information_schema`.`COLUMNS` "+
+				"WHERE `TABLE_SCHEMA` = DATABASE() AND `TABLE_NAME` = ? AND `COLUMN_NAME` = ?",
+			table,
+			"value",
+		).Row().Scan(&dataType)
+		if err != nil {
+			return 0, errors.Wrap(err, "unable to check type of counter values")
+		}
+		if !strings.EqualFold(dataType, "bigint") {
+			break
+		}
+		version = m.version
+	}
+	return version, nil
+}
+
+// ensureVersionTable - creates table of schema versions if it does not exist
+// and records all versions up to current one as applied.
+func (s *storage) ensureVersionTable(current int) error {
+	if s.db.HasTable(&model.SchemaVersion{}) {
+		return nil
+	}
+	err := s.db.
+		Set("gorm:table_options", "COLLATE='utf8_general_ci' ENGINE=InnoDB").
+		CreateTable(&model.SchemaVersion{}).
+		Error
+	if err != nil {
+		return errors.Wrap(err, "unable to create schema version table")
+	}
+	for _, m := range migrations {
+		if m.version > current {
+			break
+		}
+		err := s.db.Create(&model.SchemaVersion{Version: m.version, Description: m.description + " (detected)"}).Error
+		if err != nil {
+			return errors.Wrapf(err, "unable to record detected schema version %d", m.version)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package mysql
+
+import (
+	"testing"
+)
+
+func TestMigrations(t *testing.T) {
+	for i, m := range migrations {
+		if m.version != i+1 {
+			t.Errorf("Migration #%d: expected version %d, got %d", i, i+1, m.version)
+		}
+		if m.description == "" || len(m.up) == 0 || len(m.down) == 0 {
+			t.Errorf("Migration #%d: description, up and down statements are required", i)
+		}
+	}
+}
+
+func TestPlan(t *testing.T) {
+	latest := LatestSchemaVersion()
+	cases := []struct {
+		current, target int
+		expected        []int
+		up              bool
+	}{
+		{0, latest, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, true},
+		{2, 4, []int{3, 4}, true},
+		{latest, 0, []int{10, 9, 8, 7, 6, 5, 4, 3, 2, 1}, false},
+		{4, 2, []int{4, 3}, false},
+		{3, 3, []int{}, true},
+	}
+
+	for _, c := range cases {
+		steps, err := plan(c.current, c.target)
+		if err != nil {
+			t.Errorf("plan(%d, %d): unexpected error %v", c.current, c.target, err)
+			continue
+		}
+		if len(steps) != len(c.expected) {
+			t.Errorf("plan(%d, %d): expected %d steps, got %+v", c.current, c.target, len(c.expected), steps)
+			continue
+		}
+		for i, step := range steps {
+			if step.Version != c.expected[i] || step.Up != c.up {
+				t.Errorf("plan(%d, %d): unexpected step #%d %+v", c.current, c.target, i, step)
+			}
+		}
+	}
+
+	for _, c := range []struct{ current, target int }{
+		{0, -1},
+		{0, latest + 1},
+		{latest + 1, latest},
+		{-1, 0},
+	} {
+		if steps, err := plan(c.current, c.target); err == nil {
+			t.Errorf("plan(%d, %d): expected error, got %+v", c.current, c.target, steps)
+		}
+	}
+}
@@ -7,8 +7,12 @@ type Counter struct {
 	CounterID int       `gorm:"primary_key;auto_increment:false;column:counter_id"`
 	CreatedAt time.Time `gorm:"not null;default:current_timestamp"`
 	UpdatedAt time.Time `gorm:"not null;default:current_timestamp on update current_timestamp"`
-	Value     int       `gorm:"not null;default:'0';column:value"`
-	Increment int       `gorm:"not null;default:'1';column:increment"`
-	Lower     int       `gorm:"not null;default:'0';column:lower"`
-	Upper     int       `gorm:"not null;default:'1';column:upper"`
+	Value     int64     `gorm:"not null;default:'0';column:value"`
+	Increment int64     `gorm:"not null;default:'1';column:increment"`
+	Lower     int64     `gorm:"not null;default:'0';column:lower"`
+	Upper     int64     `gorm:"not null;default:'1';column:upper"`
+	StartFrom int64     `gorm:"not null;default:'0';column:start_from"`
+	Overflow  int       `gorm:"not null;default:'0';column:overflow"`
+	Cycle     int       `gorm:"not null;default:'0';column:cycle"`
+	Version   int       `gorm:"not null;default:'1';column:version"`
 }
@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// CounterOutbox - change of counter waiting for delivery, payload is JSON-encoded counter.OutboxEvent,
+// event which can not be decoded is marked as dead and is never delivered
+type CounterOutbox struct {
+	ID        int64     `gorm:"primary_key;column:id"`
+	CounterID int       `gorm:"not null;column:counter_id"`
+	Time      time.Time `gorm:"not null;type:datetime(6);column:time"`
+	Payload   string    `gorm:"not null;type:text;column:payload"`
+	Dead      bool      `gorm:"not null;default:false;column:dead"`
+}
@@ -0,0 +1,10 @@
+package model
+
+import "time"
+
+// SchemaVersion - applied version of database schema
+type SchemaVersion struct {
+	Version     int       `gorm:"primary_key;auto_increment:false;column:version"`
+	Description string    `gorm:"not null;size:255;column:description"`
+	AppliedAt   time.Time `gorm:"not null;default:current_timestamp;column:applied_at"`
+}
@@ -1,3 +1,4 @@
+//go:build integration
 // +build integration
 
 // This integration test uses connection with MySQL test database.
@@ -6,13 +7,16 @@
 package mysql
 
 import (
-	"fmt"
+	"math"
 	"testing"
+	"time"
 
 	"github.com/jinzhu/gorm"
 	"github.com/pkg/errors"
 	"github.com/wtask-go/auracounter/internal/config/env"
 	"github.com/wtask-go/auracounter/internal/counter"
+	"github.com/wtask-go/auracounter/internal/counter/datastore/datastoretest"
+	"github.com/wtask-go/auracounter/internal/counter/datastore/gormcore"
 	"github.com/wtask-go/auracounter/internal/counter/datastore/mysql/model"
 )
 
@@ -33,11 +37,30 @@ func connectDB(dsn, tablePrefix string) *gorm.DB {
 
 // clearDB - drops all known tables in the database
 func clearDB(checker *gorm.DB) error {
-	return checker.DropTable(
+	return checker.DropTableIfExists(
 		&model.Counter{},
+		&model.SchemaVersion{},
+		&gormcore.CounterAudit{},
+		&gormcore.CounterIdempotency{},
+		&model.CounterOutbox{},
 	).Error
 }
 
+// clearRows - deletes all records from known tables, schema version is kept
+func clearRows(checker *gorm.DB) error {
+	for _, m := range []interface{}{
+		&model.Counter{},
+		&gormcore.CounterAudit{},
+		&gormcore.CounterIdempotency{},
+		&model.CounterOutbox{},
+	} {
+		if err := checker.Delete(m).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func TestDatastore(t *testing.T) {
 	// package-level setup
 	cfg, err := env.NewApplicationConfig("TEST_")
@@ -53,25 +76,32 @@ func TestDatastore(t *testing.T) {
 
 	clearDB(checker)
 
-	storage, err := NewStorage(cfg.CounterDB.DSN(), WithTablePrefix(cfg.CounterDB.TablePrefix))
+	storage, err := NewStorage(cfg.CounterDB.DSN(), WithTablePrefix(cfg.CounterDB.TablePrefix), WithOutbox())
 	if err != nil {
 		t.Errorf("Unable to create mysql storage: %v", err)
 	}
-	for i, test := range DatastoreSuite(checker, storage) {
-		t.Run(fmt.Sprintf("test #%d", i+1), test)
-	}
-}
-
-func DatastoreSuite(checker *gorm.DB, storage counter.Storage) []test {
-	return []test{
-		// run StorageEnsureLatest first,
-		// when the test was successful it should guarantee appropriate db structure
-		StorageEnsureLatest(checker, storage),
-		RepositoryEnsureSettings(checker, storage.Repository()),
-		RepositoryGetValue(checker, storage.Repository()),
-		RepositoryIncrease(checker, storage.Repository()),
-		RepositorySetSettings(checker, storage.Repository()),
+	// StorageEnsureLatest runs first,
+	// when the test was successful it should guarantee appropriate db structure
+	if !t.Run("StorageEnsureLatest", StorageEnsureLatest(checker, storage)) {
+		return
 	}
+	t.Run("StorageMigrate", StorageMigrate(checker, storage))
+	t.Run("Repository", func(t *testing.T) {
+		datastoretest.RunRepositorySuite(t, func(t *testing.T) (counter.Repository, func()) {
+			// every test starts with empty tables
+			if err := clearRows(checker); err != nil {
+				t.Fatalf("Unable to clear database: %v", err)
+			}
+			return storage.Repository(), func() {}
+		})
+	})
+	t.Run("StorageAuditLog", func(t *testing.T) {
+		if err := clearRows(checker); err != nil {
+			t.Fatalf("Unable to clear database: %v", err)
+		}
+		datastoretest.RunAuditLogSuite(t, storage.(counter.AuditStorage).AuditLog())
+	})
+	t.Run("StorageOutbox", StorageOutbox(checker, storage))
 }
 
 func StorageEnsureLatest(checker *gorm.DB, storage counter.Storage) test {
@@ -87,212 +117,177 @@ func StorageEnsureLatest(checker *gorm.DB, storage counter.Storage) test {
 			// expected previous err == nil, so table must exits
 			t.Error("Unexpected EnsureLatest() behaviour, model.Counter does not exist in the database")
 		}
-	}
-}
 
-func RepositoryEnsureSettings(checker *gorm.DB, repository counter.Repository) test {
-	return func(t *testing.T) {
-		t.Log("TEST: Repository.(mysql).EnsureSettings()")
-
-		checker.Delete(&model.Counter{}) // should delete all records
-		settings := counter.DefaultSettings()
-		t.Logf("Case: empty database and default counter.Settings %+v", settings)
-
-		if err := repository.EnsureSettings(1, settings); err != nil {
-			t.Errorf("Method failed (empty database): %v", err)
+		t.Log("Case: unversioned schema with INT values was created by previous version")
+		checker.DropTable(&model.SchemaVersion{}, &gormcore.CounterAudit{}, &gormcore.CounterIdempotency{}, &model.CounterOutbox{})
+		if err := checker.Model(&model.Counter{}).ModifyColumn("value", "INT NOT NULL DEFAULT '0'").Error; err != nil {
+			t.Fatalf("Unable to change column type: %v", err)
 		}
-
-		c := &model.Counter{}
-		if err := checker.First(c, 1).Error; err != nil {
-			t.Errorf("Failed to load counter.Settings: %v", err)
+		if version, err := storage.(Migrator).SchemaVersion(); err != nil || version != 4 {
+			t.Errorf("Expected detected version %d, got %d (%v)", 4, version, err)
 		}
-		loaded := &counter.Settings{
-			StartFrom: c.Lower,
-			Increment: c.Increment,
-			Lower:     c.Lower,
-			Upper:     c.Upper,
+		if err := storage.EnsureLatest(); err != nil {
+			t.Errorf("EnsureLatest() for mysql failed: %v", err)
 		}
-		t.Logf("Loaded saved counter.Settings: %+v", loaded)
-
-		if *settings != *loaded {
-			t.Errorf("Loaded unexpected counter.Settings: %v", loaded)
+		if version, err := storage.(Migrator).SchemaVersion(); err != nil || version != LatestSchemaVersion() {
+			t.Errorf("Expected version %d, got %d (%v)", LatestSchemaVersion(), version, err)
 		}
+		c := &model.Counter{CounterID: 1, Value: math.MaxInt64, Upper: math.MaxInt64}
+		if err := checker.Save(c).Error; err != nil {
+			t.Errorf("Unable to save int64 value: %v", err)
+		}
+		loaded := &model.Counter{}
+		if checker.First(loaded, 1); loaded.Value != math.MaxInt64 {
+			t.Errorf("Expected %d, got %d", int64(math.MaxInt64), loaded.Value)
+		}
+		checker.Delete(&model.Counter{})
+	}
+}
 
-		// again with non empty database
-		c.Value = 100
-		c.Increment = 10
-		c.Upper = 100
-		checker.Save(c)
-
-		settings = &counter.Settings{
-			StartFrom: 1000,
-			Increment: 100,
-			Lower:     33,
-			Upper:     10000,
+func StorageMigrate(checker *gorm.DB, storage counter.Storage) test {
+	return func(t *testing.T) {
+		t.Log("TEST: Storage.(mysql).Migrate()")
+		migrator, ok := storage.(Migrator)
+		if !ok {
+			t.Fatal("mysql storage does not implement Migrator")
 		}
-		t.Logf("Case: non-empty database and custom counter.Settings %+v", settings)
+		latest := LatestSchemaVersion()
 
-		if err := repository.EnsureSettings(1, settings); err != nil {
-			t.Errorf("Method failed (non-empty database): %v", err)
+		t.Log("Case: dry run")
+		steps, err := migrator.Migrate(0, true)
+		if err != nil || len(steps) != latest || steps[0].Up || steps[latest-1].Version != 1 {
+			t.Errorf("Unexpected planned steps %+v (%v)", steps, err)
+		}
+		if version, _ := migrator.SchemaVersion(); version != latest {
+			t.Errorf("Dry run must not change schema, got version %d", version)
 		}
 
-		if err := checker.First(c, 1).Error; err != nil {
-			t.Errorf("Failed to load counter.Settings: %v", err)
+		t.Log("Case: down")
+		if _, err := migrator.Migrate(3, false); err != nil {
+			t.Errorf("Migrate(3) failed: %v", err)
 		}
-		loaded = &counter.Settings{
-			StartFrom: c.Lower,
-			Increment: c.Increment,
-			Lower:     c.Lower,
-			Upper:     c.Upper,
+		table := checker.NewScope(&model.Counter{}).TableName()
+		if version, _ := migrator.SchemaVersion(); version != 3 || checker.Dialect().HasColumn(table, "cycle") {
+			t.Errorf("Expected schema of version 3, got version %d", version)
 		}
-		t.Logf("Loaded saved counter.Settings: %+v", loaded)
 
-		if *settings == *loaded {
-			t.Errorf("Loaded unexpected counter.Settings: %v", loaded)
+		t.Log("Case: up")
+		if steps, err := migrator.Migrate(latest, false); err != nil || len(steps) != latest-3 {
+			t.Errorf("Migrate(%d): unexpected steps %+v (%v)", latest, steps, err)
+		}
+		if version, _ := migrator.SchemaVersion(); version != latest || !checker.Dialect().HasColumn(table, "cycle") {
+			t.Errorf("Expected schema of version %d, got version %d", latest, version)
 		}
 
-		if c.Value != 100 || loaded.Increment != 10 || loaded.Upper != 100 {
-			t.Errorf("Method violates counter.Settings integrity: %v", loaded)
+		t.Log("Case: unknown version")
+		if _, err := migrator.Migrate(latest+1, false); err == nil {
+			t.Error("Expected error for unknown version, got nothing")
 		}
 	}
 }
 
-func RepositoryGetValue(checker *gorm.DB, repository counter.Repository) test {
+func StorageOutbox(checker *gorm.DB, storage counter.Storage) test {
 	return func(t *testing.T) {
-		t.Log("TEST: Repository.(mysql).GetValue()")
-
-		checker.Delete(&model.Counter{}) // should delete all records
-		t.Logf("Case: empty database")
-
-		_, err := repository.GetValue(1)
-		if err == nil {
-			t.Error("Expected error for non-existed counter, got nothing")
+		t.Log("TEST: Storage.(mysql).Outbox()")
+		outboxStorage, ok := storage.(counter.OutboxStorage)
+		if !ok {
+			t.Fatal("mysql storage does not implement counter.OutboxStorage")
 		}
-		t.Logf("Got expected error: %v", err)
-
-		c := &model.Counter{
-			CounterID: 1,
-			Value:     100,
-			Increment: 10,
-			Lower:     0,
-			Upper:     1000,
-		}
-		checker.Save(c)
-		t.Logf("Case: non-empty database")
+		if err := clearRows(checker); err != nil {
+			t.Fatalf("Unable to clear database: %v", err)
+		}
+		defer checker.Delete(&model.CounterOutbox{})
 
-		v, err := repository.GetValue(1)
-		if err != nil {
-			t.Errorf("Unexpected error: %v", err)
+		repository := storage.Repository()
+		settings := &counter.Settings{Increment: 1, Lower: 0, Upper: 2, Overflow: counter.FailOnOverflow}
+		if _, _, _, err := repository.SetSettings(1, settings, counter.PreserveValue, 0); err != nil {
+			t.Fatalf("SetSettings() failed: %v", err)
 		}
-		if v != c.Value {
-			t.Errorf("Expected %d, got %d", c.Value, v)
+		if _, err := repository.Increase(1); err != nil {
+			t.Fatalf("Increase() failed: %v", err)
 		}
-	}
-}
-
-func RepositoryIncrease(checker *gorm.DB, repository counter.Repository) test {
-	return func(t *testing.T) {
-		t.Log("TEST: Repository.(mysql).Increase()")
-
-		checker.Delete(&model.Counter{}) // should delete all records
-		t.Logf("Case: empty database")
-
-		_, err := repository.Increase(1)
-		if err == nil {
-			t.Error("Expected error for non-existed counter, got nothing")
+		if _, _, err := repository.(counter.IdempotentRepository).IncreaseOnce(1, "key", time.Hour); err != nil {
+			t.Fatalf("IncreaseOnce() failed: %v", err)
 		}
-		t.Logf("Got expected error: %v", err)
-
-		c := &model.Counter{
-			CounterID: 1,
-			Value:     990,
-			Increment: 10,
-			Lower:     0,
-			Upper:     1000,
-		}
-		checker.Save(c)
-
-		t.Logf("Case: non-empty database")
-		v, err := repository.Increase(1)
-		if err != nil {
-			t.Errorf("Unexpected error: %v", err)
+		// replayed and failed changes are not recorded
+		if _, _, err := repository.(counter.IdempotentRepository).IncreaseOnce(1, "key", time.Hour); err != nil {
+			t.Fatalf("IncreaseOnce() failed: %v", err)
 		}
-		if v != c.Value+c.Increment {
-			t.Errorf("Expected %d, got %d", c.Value+c.Increment, v)
+		if _, err := repository.Increase(1); errors.Cause(err) != counter.ErrOverflow {
+			t.Fatalf("Increase(): expected overflow, got %v", err)
 		}
-
-		t.Logf("Case: reaching the upper limit")
-		v, err = repository.Increase(1)
-		if err != nil {
-			t.Errorf("Unexpected error: %v", err)
+		if _, _, err := repository.Reserve(1, 1); errors.Cause(err) != counter.ErrOverflow {
+			t.Fatalf("Reserve(): expected overflow, got %v", err)
 		}
-		if v != c.Lower {
-			t.Errorf("Expected %d, got %d", c.Lower, v)
+		if _, err := repository.Reset(1); err != nil {
+			t.Fatalf("Reset() failed: %v", err)
+		}
+		if _, _, err := repository.Reserve(1, 2); err != nil {
+			t.Fatalf("Reserve() failed: %v", err)
 		}
-	}
-}
-
-func RepositorySetSettings(checker *gorm.DB, repository counter.Repository) test {
-	return func(t *testing.T) {
-		t.Log("TEST: Repository.(mysql).SetSettings()")
-
-		checker.Delete(&model.Counter{}) // should delete all records
-		t.Logf("Case: empty database")
 
-		initial := &counter.Settings{
-			StartFrom: 100,
-			Increment: 10,
-			Lower:     0,
-			Upper:     1000,
+		outbox := outboxStorage.Outbox()
+		events, dead, err := outbox.Pending(10)
+		if err != nil || dead != nil {
+			t.Fatalf("Pending() failed: %v (dead %v)", err, dead)
 		}
-		if err := repository.SetSettings(1, initial); err != nil {
-			t.Errorf("Unexpected error: %v", err)
+		expected := []struct {
+			operation       string
+			value, previous int64
+		}{
+			{counter.SettingsOperation, 0, 0},
+			{counter.IncreaseOperation, 1, 0},
+			{counter.IncreaseOperation, 2, 1},
+			{counter.ResetOperation, 0, 2},
+			{counter.ReserveOperation, 2, 0},
 		}
-
-		c := &model.Counter{}
-		if err := checker.First(c, 1).Error; err != nil {
-			t.Errorf("Failed to load counter.Settings: %v", err)
+		if len(events) != len(expected) {
+			t.Fatalf("Expected %d events, got %+v", len(expected), events)
 		}
-		loaded := &counter.Settings{
-			StartFrom: c.Value,
-			Increment: c.Increment,
-			Lower:     c.Lower,
-			Upper:     c.Upper,
+		for i, e := range expected {
+			event := events[i]
+			if event.ID == 0 || event.CounterID != 1 || event.Time.IsZero() ||
+				event.Operation != e.operation || event.Value != e.value || event.Previous != e.previous {
+				t.Errorf("Expected event %+v, got %+v", e, event)
+			}
 		}
-		t.Logf("Loaded saved counter.Settings: %+v", loaded)
-
-		if *initial != *loaded {
-			t.Errorf("Loaded unexpected counter.Settings: %v", loaded)
+		if events[0].Settings == nil || events[0].Settings.Upper != 2 || events[0].Settings.Version != 1 {
+			t.Errorf("Unexpected settings of event: %+v", events[0].Settings)
 		}
-
-		t.Logf("Case: empty database")
-
-		final := &counter.Settings{
-			// StartFrom must not affect existing counter
-			StartFrom: 500,
-			Increment: 100,
-			Lower:     100,
-			Upper:     10000,
+		if len(events[4].Ranges) != 1 || events[4].Ranges[0].First != 1 || events[4].Ranges[0].Last != 2 {
+			t.Errorf("Unexpected ranges of event: %+v", events[4].Ranges)
 		}
-		if err := repository.SetSettings(1, final); err != nil {
-			t.Errorf("Unexpected error: %v", err)
+		if page, _, err := outbox.Pending(2); err != nil || len(page) != 2 || page[1].ID != events[1].ID {
+			t.Errorf("Pending(2): unexpected events %+v (%v)", page, err)
 		}
 
-		if err := checker.First(c, 1).Error; err != nil {
-			t.Errorf("Failed to load counter.Settings: %v", err)
+		if err := outbox.Delivered([]int64{events[0].ID, events[2].ID}); err != nil {
+			t.Fatalf("Delivered() failed: %v", err)
+		}
+		if page, _, err := outbox.Pending(10); err != nil || len(page) != 3 || page[0].ID != events[1].ID || page[1].ID != events[3].ID {
+			t.Errorf("Unexpected events after delivery %+v (%v)", page, err)
 		}
-		if c.Value != initial.StartFrom {
-			t.Errorf("Unexpected model.Counter.Value (%d) after settings were set", c.Value)
+		if err := outbox.Delivered(nil); err != nil {
+			t.Errorf("Delivered() failed for empty IDs: %v", err)
 		}
 
-		loaded = &counter.Settings{
-			StartFrom: final.StartFrom,
-			Increment: c.Increment,
-			Lower:     c.Lower,
-			Upper:     c.Upper,
-		}
-		if *final != *loaded {
-			t.Errorf("Loaded unexpected counter.Settings: %v", loaded)
+		t.Log("Case: undecodable event does not block the next ones")
+		corrupt := &model.CounterOutbox{CounterID: 1, Time: time.Now().UTC(), Payload: "{"}
+		if err := checker.Create(corrupt).Error; err != nil {
+			t.Fatalf("Unable to insert corrupt event: %v", err)
+		}
+		if _, err := repository.Reset(1); err != nil {
+			t.Fatalf("Reset() failed: %v", err)
+		}
+		page, dead, err := outbox.Pending(10)
+		if err != nil || len(dead) != 1 || dead[0] != corrupt.ID {
+			t.Fatalf("Pending(): expected dead event #%d, got %v (%v)", corrupt.ID, dead, err)
+		}
+		if len(page) != 4 || page[3].ID <= corrupt.ID || page[3].Operation != counter.ResetOperation {
+			t.Errorf("Pending(): unexpected events around dead one %+v", page)
+		}
+		if again, dead, err := outbox.Pending(10); err != nil || dead != nil || len(again) != 4 {
+			t.Errorf("Pending(): dead event must not be returned again, got %+v, dead %v (%v)", again, dead, err)
 		}
 	}
 }
@@ -0,0 +1,80 @@
+package mysql
+
+import (
+	"encoding/json"
+
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+	"github.com/wtask-go/auracounter/internal/counter"
+	"github.com/wtask-go/auracounter/internal/counter/datastore/mysql/model"
+)
+
+// outbox - implements counter.Outbox with `counter_outbox` table (with table prefix)
+type outbox struct {
+	db *gorm.DB
+}
+
+// Outbox - exposes the storage as counter.Outbox, so it implements counter.OutboxStorage.
+// Events are recorded only when storage is built with `WithOutbox` option.
+// Outbox table is created by migration, see `mysql.EnsureLatest`.
+func (s *storage) Outbox() counter.Outbox {
+	if s == nil {
+		return nil
+	}
+	return &outbox{db: s.db}
+}
+
+// recordEvent - inserts the event into outbox within given transaction if outbox is enabled.
+func (s *storage) recordEvent(tx *gorm.DB, event *counter.OutboxEvent) error {
+	if !s.outbox {
+		return nil
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "unable to encode outbox event")
+	}
+	err = tx.Create(&model.CounterOutbox{
+		CounterID: event.CounterID,
+		Time:      event.Time,
+		Payload:   string(payload),
+	}).Error
+	return errors.Wrap(err, "unable to record outbox event")
+}
+
+// Pending - returns up to `limit` recorded events in ascending order of IDs.
+// IDs of concurrent transactions may be committed out of order,
+// so event with lower ID may become pending after events with greater IDs.
+// Rows with undecodable payload are marked as dead, their IDs are returned separately.
+func (o *outbox) Pending(limit int) ([]counter.OutboxEvent, []int64, error) {
+	rows := []model.CounterOutbox{}
+	if err := o.db.Where("`dead` = ?", false).Order("`id`").Limit(limit).Find(&rows).Error; err != nil {
+		return nil, nil, errors.Wrap(err, "mysql.Pending: failed")
+	}
+	events := make([]counter.OutboxEvent, 0, len(rows))
+	var dead []int64
+	for _, row := range rows {
+		event := counter.OutboxEvent{}
+		if err := json.Unmarshal([]byte(row.Payload), &event); err != nil {
+			dead = append(dead, row.ID)
+			continue
+		}
+		event.ID = row.ID
+		events = append(events, event)
+	}
+	if len(dead) > 0 {
+		err := o.db.Model(&model.CounterOutbox{}).Where("`id` IN (?)", dead).Update("dead", true).Error
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "mysql.Pending: unable to mark undecodable events as dead")
+		}
+	}
+	return events, dead, nil
+}
+
+// Delivered - deletes delivered events.
+func (o *outbox) Delivered(ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	err := o.db.Where("`id` IN (?)", ids).Delete(&model.CounterOutbox{}).Error
+	return errors.Wrap(err, "mysql.Delivered: failed")
+}
@@ -18,6 +18,9 @@ func (s *storage) EnsureSettings(counterID int, defaults *counter.Settings) erro
 			Increment: defaults.Increment,
 			Lower:     defaults.Lower,
 			Upper:     defaults.Upper,
+			StartFrom: defaults.StartFrom,
+			Overflow:  int(defaults.Overflow),
+			Version:   1,
 		}).FirstOrCreate(&model.Counter{}). // ignore result, but will check error
 		Error
 	if err != nil {
@@ -28,88 +31,220 @@ func (s *storage) EnsureSettings(counterID int, defaults *counter.Settings) erro
 	return errors.Wrapf(tx.Commit().Error, "mysql.EnsureSettings(#%d): commit failed", counterID)
 }
 
-// Get - return current counter value
-func (s *storage) GetValue(counterID int) (int, error) {
+// Get - return current counter value and cycle
+func (s *storage) GetValue(counterID int) (counter.State, error) {
 	c := &model.Counter{}
 	if err := s.db.First(c, counterID).Error; err != nil {
 		// same here if record not found
-		return 0, errors.Wrapf(err, "mysql.GetValue(#%d): failed", counterID)
+		return counter.State{}, errors.Wrapf(err, "mysql.GetValue(#%d): failed", counterID)
 	}
-	return c.Value, nil
+	return stateOf(c), nil
+}
+
+// GetSettings - return current counter settings and their version.
+func (s *storage) GetSettings(counterID int) (*counter.Settings, int, error) {
+	c := &model.Counter{}
+	if err := s.db.First(c, counterID).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			err = counter.ErrNotFound
+		}
+		return nil, 0, errors.Wrapf(err, "mysql.GetSettings(#%d): failed", counterID)
+	}
+	return settingsOf(c), c.Version, nil
 }
 
 // Increase - increase counter using previously stored settings without validating its consistency.
-// Method returns highly likely calculated counter value.
+// Counter row is locked (SELECT ... FOR UPDATE) until the transaction ends,
+// so concurrent calls are serialized and method returns exactly the committed counter value.
 // If counter/counter settings were not prepared before calling `mysql.Increase`, method will fail.
 // See `mysql.EnsureSettings`.
-func (s *storage) Increase(counterID int) (int, error) {
-	// table := s.db.NewScope(c).TableName()
+func (s *storage) Increase(counterID int) (counter.State, error) {
+	_, state, err := s.changeState("Increase", counter.IncreaseOperation, counterID,
+		func(c *model.Counter) ([]counter.Range, counter.State, error) {
+			state, err := stateOf(c).Increased(settingsOf(c))
+			return nil, state, err
+		},
+	)
+	return state, err
+}
+
+// Decrease - decrease counter using previously stored settings, so it reverts the last increase.
+// Counter wraps from lower boundary to upper boundary, see `counter.Settings.Previous`.
+// If counter/counter settings were not prepared before calling `mysql.Decrease`, method will fail.
+func (s *storage) Decrease(counterID int) (counter.State, error) {
+	_, state, err := s.changeState("Decrease", counter.DecreaseOperation, counterID,
+		func(c *model.Counter) ([]counter.Range, counter.State, error) {
+			state, err := stateOf(c).Decreased(settingsOf(c))
+			return nil, state, err
+		},
+	)
+	return state, err
+}
+
+// DecreaseBy - decrease counter `size` times at once using previously stored settings.
+// Counter row is locked until the transaction ends, so all decreases are committed together.
+// If counter/counter settings were not prepared before calling `mysql.DecreaseBy`, method will fail.
+func (s *storage) DecreaseBy(counterID int, size int) (counter.State, error) {
+	_, state, err := s.changeState("DecreaseBy", counter.DecreaseOperation, counterID,
+		func(c *model.Counter) ([]counter.Range, counter.State, error) {
+			state, err := stateOf(c).DecreasedBy(settingsOf(c), size)
+			return nil, state, err
+		},
+	)
+	return state, err
+}
+
+// Reset - return counter to its start value, see `counter.Settings.Start`.
+// If counter/counter settings were not prepared before calling `mysql.Reset`, method will fail.
+func (s *storage) Reset(counterID int) (counter.State, error) {
+	_, state, err := s.changeState("Reset", counter.ResetOperation, counterID,
+		func(c *model.Counter) ([]counter.Range, counter.State, error) {
+			return nil, stateOf(c).Reset(settingsOf(c)), nil
+		},
+	)
+	return state, err
+}
+
+// changeState - replaces counter state with the result of `change` func within single transaction
+// and returns passed ranges (if any) and committed state. Transaction is rolled back when `change` fails.
+// The change is recorded into outbox as `operation` within the same transaction if outbox is enabled.
+// `method` is used to describe errors only.
+func (s *storage) changeState(
+	method, operation string,
+	counterID int,
+	change func(c *model.Counter) ([]counter.Range, counter.State, error),
+) ([]counter.Range, counter.State, error) {
 	tx := s.db.Begin()
 	if tx.Error != nil {
-		return 0, errors.Wrapf(tx.Error, "mysql.Increase(#%d): failed to begin transaction", counterID)
+		return nil, counter.State{}, errors.Wrapf(tx.Error, "mysql.%s(#%d): failed to begin transaction", method, counterID)
 	}
 	c := &model.Counter{}
-	if err := tx.First(c, counterID).Error; err != nil {
+	if err := tx.Set("gorm:query_option", "FOR UPDATE").First(c, counterID).Error; err != nil {
 		tx.Rollback()
 		// same here if record not found
-		return 0, errors.Wrapf(err, "mysql.Increase(#%d): failed to get counter", counterID)
+		return nil, counter.State{}, errors.Wrapf(err, "mysql.%s(#%d): failed to get counter", method, counterID)
 	}
-	result := c.Value + c.Increment
-	if result > c.Upper {
-		result = c.Lower
-	}
-	// TODO test if also model updated
-	err := tx.Model(c).
-		Update("value", gorm.Expr("IF(value+increment>upper,lower,value+increment)")).
-		Error
+	ranges, state, err := change(c)
 	if err != nil {
 		tx.Rollback()
-		return 0, errors.Wrapf(err, "mysql.Increase(#%d): failed", counterID)
+		return nil, counter.State{}, errors.Wrapf(err, "mysql.%s(#%d): failed", method, counterID)
+	}
+	err = tx.Model(c).Updates(map[string]interface{}{"value": state.Value, "cycle": state.Cycle}).Error
+	if err == nil {
+		err = s.recordEvent(tx, counter.NewChangeEvent(counterID, operation, state, ranges))
 	}
-	err = errors.Wrapf(tx.Commit().Error, "mysql.Increase(#%d): commit failed", counterID)
 	if err != nil {
-		return 0, err
+		tx.Rollback()
+		return nil, counter.State{}, errors.Wrapf(err, "mysql.%s(#%d): failed", method, counterID)
 	}
-	return result, nil
+	if err := tx.Commit().Error; err != nil {
+		return nil, counter.State{}, errors.Wrapf(err, "mysql.%s(#%d): commit failed", method, counterID)
+	}
+	return ranges, state, nil
+}
+
+// Reserve - increase counter `size` times at once using previously stored settings.
+// Counter row is locked until the transaction ends, so the block does not overlap with others.
+// If counter/counter settings were not prepared before calling `mysql.Reserve`, method will fail.
+func (s *storage) Reserve(counterID int, size int) ([]counter.Range, counter.State, error) {
+	return s.changeState("Reserve", counter.ReserveOperation, counterID,
+		func(c *model.Counter) ([]counter.Range, counter.State, error) {
+			return stateOf(c).Reserved(settingsOf(c), size)
+		},
+	)
 }
 
-func (s *storage) SetSettings(counterID int, settings *counter.Settings) error {
+// SetSettings - set new counter settings, current value of existing counter is changed according to mode.
+// New counter is created with start value. The change is recorded into outbox if it is enabled.
+// When `version` is not zero, settings are changed only if it is equal to current version of settings,
+// otherwise `counter.ErrVersionMismatch` is returned. Every change increases version of settings.
+// Method returns previous settings (nil for new counter), the state after settings were applied
+// (the state keeps previous counter value) and new version of settings.
+func (s *storage) SetSettings(
+	counterID int,
+	settings *counter.Settings,
+	mode counter.ValueMode,
+	version int,
+) (*counter.Settings, counter.State, int, error) {
 	// we need transaction due to sequential select, insert/update queries
 	tx := s.db.Begin()
 	if tx.Error != nil {
-		return errors.Wrapf(tx.Error, "mysql.SetSettings(#%d): failed to begin transaction", counterID)
+		return nil, counter.State{}, 0, errors.Wrapf(tx.Error, "mysql.SetSettings(#%d): failed to begin transaction", counterID)
 	}
 	var (
 		original = &model.Counter{}
+		previous *counter.Settings
+		state    counter.State
+		next     = 1
 		err      error
 	)
-	switch err = tx.First(original, counterID).Error; {
+	switch err = tx.Set("gorm:query_option", "FOR UPDATE").First(original, counterID).Error; {
 	default:
 		tx.Rollback()
-		return errors.Wrapf(err, "mysql.SetSettings(#%d): failed to get counter", counterID)
+		return nil, counter.State{}, 0, errors.Wrapf(err, "mysql.SetSettings(#%d): failed to get counter", counterID)
+	case version != 0 && (err == gorm.ErrRecordNotFound || (err == nil && original.Version != version)):
+		tx.Rollback()
+		return nil, counter.State{}, 0, errors.Wrapf(counter.ErrVersionMismatch, "mysql.SetSettings(#%d): failed", counterID)
 	case err == nil:
 		// update
+		previous = settingsOf(original)
+		next = original.Version + 1
+		state = counter.State{
+			Value:    settings.Adjust(original.Value, mode),
+			Cycle:    original.Cycle,
+			Previous: original.Value,
+		}
 		err = tx.Model(original).
-			Updates(&model.Counter{
-				Increment: settings.Increment,
-				Lower:     settings.Lower,
-				Upper:     settings.Upper,
+			Updates(map[string]interface{}{
+				"value":      state.Value,
+				"increment":  settings.Increment,
+				"lower":      settings.Lower,
+				"upper":      settings.Upper,
+				"start_from": settings.StartFrom,
+				"overflow":   int(settings.Overflow),
+				"version":    next,
 			}).Error
 	case err == gorm.ErrRecordNotFound:
 		// insert
+		state = counter.State{Value: settings.StartFrom, Previous: settings.StartFrom}
 		err = tx.Create(&model.Counter{
 			CounterID: counterID,
-			Value:     settings.StartFrom,
+			Value:     state.Value,
 			Increment: settings.Increment,
 			Lower:     settings.Lower,
 			Upper:     settings.Upper,
+			StartFrom: settings.StartFrom,
+			Overflow:  int(settings.Overflow),
+			Version:   next,
 		}).Error
 	}
+	if err == nil {
+		err = s.recordEvent(tx, counter.NewSettingsEvent(counterID, settings, next, state))
+	}
 
 	if err != nil {
 		tx.Rollback()
-		return errors.Wrapf(err, "mysql.SetSettings(#%d): failed to set %v", counterID, *settings)
+		return nil, counter.State{}, 0, errors.Wrapf(err, "mysql.SetSettings(#%d): failed to set %v", counterID, *settings)
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, counter.State{}, 0, errors.Wrapf(err, "mysql.SetSettings(#%d): failed to commit changes", counterID)
 	}
+	return previous, state, next, nil
+}
+
+// stateOf - extracts counter state from the model.
+func stateOf(c *model.Counter) counter.State {
+	return counter.State{Value: c.Value, Cycle: c.Cycle}
+}
 
-	return errors.Wrapf(tx.Commit().Error, "mysql.SetSettings(#%d): failed to commit changes", counterID)
+// settingsOf - extracts counter settings from the model.
+func settingsOf(c *model.Counter) *counter.Settings {
+	return &counter.Settings{
+		StartFrom: c.StartFrom,
+		Increment: c.Increment,
+		Lower:     c.Lower,
+		Upper:     c.Upper,
+		Overflow:  counter.OverflowPolicy(c.Overflow),
+	}
 }
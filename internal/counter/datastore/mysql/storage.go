@@ -3,8 +3,6 @@ package mysql
 import (
 	"strings"
 
-	"github.com/wtask-go/auracounter/internal/counter/datastore/mysql/model"
-
 	"github.com/pkg/errors"
 
 	"github.com/wtask-go/auracounter/internal/counter"
@@ -20,6 +18,8 @@ type (
 		db     *gorm.DB
 		dsn    string
 		prefix string
+		// outbox - changes of counters are recorded into outbox
+		outbox bool
 	}
 
 	storageOption func() (func(*storage), error)
@@ -67,6 +67,14 @@ func WithTablePrefix(prefix string) storageOption {
 	})
 }
 
+// WithOutbox - enables recording of counter changes into outbox table within the same transaction as changes,
+// recorded events must be delivered and forgotten with `Outbox()`, otherwise the table grows infinitely.
+func WithOutbox() storageOption {
+	return properOption(func(s *storage) {
+		s.outbox = true
+	})
+}
+
 // NewStorage - implements counter.Storage interface to store cyclic incremental counter with mysql.
 // If storage was created without errors, you may use it after has ensured it has latest version
 // and is up-to-date, see `EnsureLatest()` method.
@@ -102,11 +110,9 @@ func NewStorage(dsn string, options ...storageOption) (counter.Storage, error) {
 }
 
 // EnsureLatest - make sure underlying database has latest version and is up-to-date to store counter.
+// Schema is migrated up to the latest version, see `mysql.Migrator`.
 func (s *storage) EnsureLatest() error {
-	err := s.db.
-		Set("gorm:table_options", "COLLATE='utf8_general_ci' ENGINE=InnoDB").
-		AutoMigrate(&model.Counter{}).
-		Error
+	_, err := s.migrate(LatestSchemaVersion(), false)
 	return errors.Wrap(err, "mysql.EnsureLatest: failed")
 }
 
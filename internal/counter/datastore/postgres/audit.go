@@ -0,0 +1,15 @@
+package postgres
+
+import (
+	"github.com/wtask-go/auracounter/internal/counter"
+	"github.com/wtask-go/auracounter/internal/counter/datastore/gormcore"
+)
+
+// AuditLog - exposes the storage as counter.AuditLog, so it implements counter.AuditStorage.
+// Audit table is created along with counter table, see `EnsureLatest()` method.
+func (s *storage) AuditLog() counter.AuditLog {
+	if s == nil {
+		return nil
+	}
+	return gormcore.NewAuditLog(s.db, `"counter_id" = ? AND "id" > ?`, `"id"`)
+}
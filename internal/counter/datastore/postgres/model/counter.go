@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// Counter - counter model
+type Counter struct {
+	CounterID int       `gorm:"primary_key;auto_increment:false;column:counter_id"`
+	CreatedAt time.Time `gorm:"not null;default:current_timestamp"`
+	UpdatedAt time.Time `gorm:"not null;default:current_timestamp"`
+	Value     int64     `gorm:"not null;default:'0';column:value"`
+	Increment int64     `gorm:"not null;default:'1';column:increment"`
+	Lower     int64     `gorm:"not null;default:'0';column:lower"`
+	Upper     int64     `gorm:"not null;default:'1';column:upper"`
+	StartFrom int64     `gorm:"not null;default:'0';column:start_from"`
+	Overflow  int       `gorm:"not null;default:'0';column:overflow"`
+	Cycle     int       `gorm:"not null;default:'0';column:cycle"`
+	Version   int       `gorm:"not null;default:'1';column:version"`
+}
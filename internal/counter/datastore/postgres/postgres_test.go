@@ -0,0 +1,129 @@
+//go:build integration
+// +build integration
+
+// This integration test uses connection with PostgreSQL test database.
+// As expected, all connection params are stored in environment variables and must exist before test will run.
+// Check `deployments/config.test.env`
+package postgres
+
+import (
+	"math"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+	"github.com/wtask-go/auracounter/internal/config/env"
+	"github.com/wtask-go/auracounter/internal/counter"
+	"github.com/wtask-go/auracounter/internal/counter/datastore/datastoretest"
+	"github.com/wtask-go/auracounter/internal/counter/datastore/gormcore"
+	"github.com/wtask-go/auracounter/internal/counter/datastore/postgres/model"
+)
+
+type test func(*testing.T)
+
+// connectDB - creates independent connection to database used for tests.
+func connectDB(dsn, tablePrefix string) *gorm.DB {
+	iface, err := NewStorage(dsn, WithTablePrefix(tablePrefix))
+	if err != nil {
+		panic(errors.Wrap(err, "Failed to build storage interface"))
+	}
+	impl, ok := iface.(*storage)
+	if !ok {
+		panic(errors.New("Typecast failed, expected postgres.storage"))
+	}
+	return impl.db
+}
+
+// clearDB - drops all known tables in the database
+func clearDB(checker *gorm.DB) error {
+	return checker.DropTable(
+		&model.Counter{},
+		&gormcore.CounterIdempotency{},
+		&gormcore.CounterAudit{},
+	).Error
+}
+
+// clearRows - deletes all records from known tables
+func clearRows(checker *gorm.DB) error {
+	if err := checker.Delete(&model.Counter{}).Error; err != nil {
+		return err
+	}
+	if err := checker.Delete(&gormcore.CounterIdempotency{}).Error; err != nil {
+		return err
+	}
+	return checker.Delete(&gormcore.CounterAudit{}).Error
+}
+
+func TestDatastore(t *testing.T) {
+	// package-level setup
+	cfg, err := env.NewApplicationConfig("TESTPG_")
+	if err != nil {
+		panic(errors.Wrap(err, "Poor testing environment"))
+	}
+
+	checker := connectDB(cfg.CounterDB.DSN(), cfg.CounterDB.TablePrefix)
+	defer func() {
+		clearDB(checker)
+		checker.Close()
+	}()
+
+	clearDB(checker)
+
+	storage, err := NewStorage(cfg.CounterDB.DSN(), WithTablePrefix(cfg.CounterDB.TablePrefix))
+	if err != nil {
+		t.Errorf("Unable to create postgres storage: %v", err)
+	}
+	// StorageEnsureLatest runs first,
+	// when the test was successful it should guarantee appropriate db structure
+	if !t.Run("StorageEnsureLatest", StorageEnsureLatest(checker, storage)) {
+		return
+	}
+	t.Run("Repository", func(t *testing.T) {
+		datastoretest.RunRepositorySuite(t, func(t *testing.T) (counter.Repository, func()) {
+			// every test starts with empty tables
+			if err := clearRows(checker); err != nil {
+				t.Fatalf("Unable to clear database: %v", err)
+			}
+			return storage.Repository(), func() {}
+		})
+	})
+	t.Run("StorageAuditLog", func(t *testing.T) {
+		if err := clearRows(checker); err != nil {
+			t.Fatalf("Unable to clear database: %v", err)
+		}
+		datastoretest.RunAuditLogSuite(t, storage.(counter.AuditStorage).AuditLog())
+	})
+}
+
+func StorageEnsureLatest(checker *gorm.DB, storage counter.Storage) test {
+	return func(t *testing.T) {
+		t.Log("TEST: Storage.(postgres).EnsureLatest()")
+		if checker.HasTable(&model.Counter{}) {
+			t.Error("Unable to start test, model.Counter table exists in the database")
+		}
+		if err := storage.EnsureLatest(); err != nil {
+			t.Errorf("EnsureLatest() for postgres failed: %v", err)
+		}
+		if !checker.HasTable(&model.Counter{}) {
+			// expected previous err == nil, so table must exits
+			t.Error("Unexpected EnsureLatest() behaviour, model.Counter does not exist in the database")
+		}
+
+		t.Log("Case: value column was created by previous version")
+		if err := checker.Model(&model.Counter{}).ModifyColumn("value", "INTEGER").Error; err != nil {
+			t.Fatalf("Unable to change column type: %v", err)
+		}
+		if err := storage.EnsureLatest(); err != nil {
+			t.Errorf("EnsureLatest() for postgres failed: %v", err)
+		}
+		c := &model.Counter{CounterID: 1, Value: math.MaxInt64, Upper: math.MaxInt64}
+		if err := checker.Save(c).Error; err != nil {
+			t.Errorf("Unable to save int64 value: %v", err)
+		}
+		loaded := &model.Counter{}
+		if checker.First(loaded, 1); loaded.Value != math.MaxInt64 {
+			t.Errorf("Expected %d, got %d", int64(math.MaxInt64), loaded.Value)
+		}
+		checker.Delete(&model.Counter{})
+	}
+}
@@ -0,0 +1,283 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+	"github.com/wtask-go/auracounter/internal/counter"
+	"github.com/wtask-go/auracounter/internal/counter/datastore/postgres/model"
+)
+
+func (s *storage) EnsureSettings(counterID int, defaults *counter.Settings) error {
+	tx := s.db.Begin()
+	if tx.Error != nil {
+		return errors.Wrapf(tx.Error, "postgres.EnsureSettings(#%d): failed to begin transaction.", counterID)
+	}
+	err := tx.Where(&model.Counter{CounterID: counterID}).
+		Attrs(&model.Counter{
+			Value:     defaults.StartFrom,
+			Increment: defaults.Increment,
+			Lower:     defaults.Lower,
+			Upper:     defaults.Upper,
+			StartFrom: defaults.StartFrom,
+			Overflow:  int(defaults.Overflow),
+			Version:   1,
+		}).FirstOrCreate(&model.Counter{}). // ignore result, but will check error
+		Error
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "postgres.EnsureSettings(#%d): failed", counterID)
+	}
+
+	return errors.Wrapf(tx.Commit().Error, "postgres.EnsureSettings(#%d): commit failed", counterID)
+}
+
+// Get - return current counter value and cycle
+func (s *storage) GetValue(counterID int) (counter.State, error) {
+	c := &model.Counter{}
+	if err := s.db.First(c, counterID).Error; err != nil {
+		// same here if record not found
+		return counter.State{}, errors.Wrapf(err, "postgres.GetValue(#%d): failed", counterID)
+	}
+	return stateOf(c), nil
+}
+
+// GetSettings - return current counter settings and their version.
+func (s *storage) GetSettings(counterID int) (*counter.Settings, int, error) {
+	c := &model.Counter{}
+	if err := s.db.First(c, counterID).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			err = counter.ErrNotFound
+		}
+		return nil, 0, errors.Wrapf(err, "postgres.GetSettings(#%d): failed", counterID)
+	}
+	return settingsOf(c), c.Version, nil
+}
+
+// Increase - increase counter using previously stored settings without validating its consistency.
+// Counter is changed with single atomic UPDATE ... RETURNING statement,
+// so method returns exactly the committed counter value.
+// The statement applies overflow policy in the same way as `counter.Settings.Next` does,
+// boundaries are shifted by increment, so BIGINT arithmetic never overflows,
+// and counts cycles in the same way as `counter.State.Increased` does.
+// If counter/counter settings were not prepared before calling `postgres.Increase`, method will fail.
+// See `postgres.EnsureSettings`.
+func (s *storage) Increase(counterID int) (counter.State, error) {
+	table := s.db.NewScope(&model.Counter{}).QuotedTableName()
+	state, previousCycle := counter.State{}, 0
+	// previous value and cycle are selected with row lock, so they can not be changed before the update
+	err := s.db.Raw(
+		`UPDATE `+table+` AS "c" SET
+			"value" = CASE
+				WHEN "c"."increment" >= 0 AND "c"."value" > "c"."upper" - "c"."increment" THEN
+					CASE WHEN "c"."overflow" = ? THEN "c"."upper" ELSE "c"."lower" END
+				WHEN "c"."increment" < 0 AND "c"."value" < "c"."lower" - "c"."increment" THEN
+					CASE WHEN "c"."overflow" = ? THEN "c"."lower" ELSE "c"."upper" END
+				ELSE "c"."value" + "c"."increment"
+			END,
+			"cycle" = "c"."cycle" + CASE
+				WHEN "c"."overflow" = ? AND (
+					("c"."increment" >= 0 AND "c"."value" > "c"."upper" - "c"."increment") OR
+					("c"."increment" < 0 AND "c"."value" < "c"."lower" - "c"."increment")
+				) THEN 1
+				ELSE 0
+			END,
+			"updated_at" = CURRENT_TIMESTAMP
+		FROM (SELECT "counter_id", "value", "cycle" FROM `+table+` WHERE "counter_id" = ? FOR UPDATE) AS "previous"
+		WHERE "c"."counter_id" = "previous"."counter_id" AND NOT ("c"."overflow" = ? AND (
+			("c"."increment" >= 0 AND "c"."value" > "c"."upper" - "c"."increment") OR
+			("c"."increment" < 0 AND "c"."value" < "c"."lower" - "c"."increment")
+		))
+		RETURNING "c"."value", "c"."cycle", "previous"."value", "previous"."cycle"`,
+		int(counter.SaturateOnOverflow),
+		int(counter.SaturateOnOverflow),
+		int(counter.WrapOnOverflow),
+		counterID,
+		int(counter.FailOnOverflow),
+	).Row().Scan(&state.Value, &state.Cycle, &state.Previous, &previousCycle)
+	if err == sql.ErrNoRows {
+		// counter does not exist or it must fail on overflow
+		err = s.db.First(&model.Counter{}, counterID).Error
+		if err == nil {
+			err = counter.ErrOverflow
+		}
+	}
+	if err != nil {
+		return counter.State{}, errors.Wrapf(err, "postgres.Increase(#%d): failed", counterID)
+	}
+	state.Wraps = state.Cycle - previousCycle
+	return state, nil
+}
+
+// Decrease - decrease counter using previously stored settings, so it reverts the last increase.
+// Counter wraps from lower boundary to upper boundary, see `counter.Settings.Previous`.
+// Counter row is locked until the transaction ends, so concurrent changes are serialized.
+// If counter/counter settings were not prepared before calling `postgres.Decrease`, method will fail.
+func (s *storage) Decrease(counterID int) (counter.State, error) {
+	return s.changeState("Decrease", counterID, func(c *model.Counter) (counter.State, error) {
+		return stateOf(c).Decreased(settingsOf(c))
+	})
+}
+
+// DecreaseBy - decrease counter `size` times at once using previously stored settings.
+// Counter row is locked until the transaction ends, so all decreases are committed together.
+// If counter/counter settings were not prepared before calling `postgres.DecreaseBy`, method will fail.
+func (s *storage) DecreaseBy(counterID int, size int) (counter.State, error) {
+	return s.changeState("DecreaseBy", counterID, func(c *model.Counter) (counter.State, error) {
+		return stateOf(c).DecreasedBy(settingsOf(c), size)
+	})
+}
+
+// Reset - return counter to its start value, see `counter.Settings.Start`.
+// If counter/counter settings were not prepared before calling `postgres.Reset`, method will fail.
+func (s *storage) Reset(counterID int) (counter.State, error) {
+	return s.changeState("Reset", counterID, func(c *model.Counter) (counter.State, error) {
+		return stateOf(c).Reset(settingsOf(c)), nil
+	})
+}
+
+// changeState - replaces counter state with the result of `change` func within single transaction
+// and returns committed state. Transaction is rolled back when `change` fails.
+// `method` is used to describe errors only.
+func (s *storage) changeState(
+	method string,
+	counterID int,
+	change func(c *model.Counter) (counter.State, error),
+) (counter.State, error) {
+	tx := s.db.Begin()
+	if tx.Error != nil {
+		return counter.State{}, errors.Wrapf(tx.Error, "postgres.%s(#%d): failed to begin transaction", method, counterID)
+	}
+	c := &model.Counter{}
+	if err := tx.Set("gorm:query_option", "FOR UPDATE").First(c, counterID).Error; err != nil {
+		tx.Rollback()
+		// same here if record not found
+		return counter.State{}, errors.Wrapf(err, "postgres.%s(#%d): failed to get counter", method, counterID)
+	}
+	state, err := change(c)
+	if err != nil {
+		tx.Rollback()
+		return counter.State{}, errors.Wrapf(err, "postgres.%s(#%d): failed", method, counterID)
+	}
+	err = tx.Model(c).Updates(map[string]interface{}{"value": state.Value, "cycle": state.Cycle}).Error
+	if err != nil {
+		tx.Rollback()
+		return counter.State{}, errors.Wrapf(err, "postgres.%s(#%d): failed", method, counterID)
+	}
+	if err := tx.Commit().Error; err != nil {
+		return counter.State{}, errors.Wrapf(err, "postgres.%s(#%d): commit failed", method, counterID)
+	}
+	return state, nil
+}
+
+// Reserve - increase counter `size` times at once using previously stored settings.
+// Counter row is locked until the transaction ends, so the block does not overlap with others.
+// If counter/counter settings were not prepared before calling `postgres.Reserve`, method will fail.
+func (s *storage) Reserve(counterID int, size int) ([]counter.Range, counter.State, error) {
+	var ranges []counter.Range
+	state, err := s.changeState("Reserve", counterID, func(c *model.Counter) (counter.State, error) {
+		var (
+			state counter.State
+			err   error
+		)
+		ranges, state, err = stateOf(c).Reserved(settingsOf(c), size)
+		return state, err
+	})
+	if err != nil {
+		return nil, counter.State{}, err
+	}
+	return ranges, state, nil
+}
+
+// SetSettings - set new counter settings, current value of existing counter is changed according to mode.
+// New counter is created with start value.
+// When `version` is not zero, settings are changed only if it is equal to current version of settings,
+// otherwise `counter.ErrVersionMismatch` is returned. Every change increases version of settings.
+// Method returns previous settings (nil for new counter), the state after settings were applied
+// (the state keeps previous counter value) and new version of settings.
+func (s *storage) SetSettings(
+	counterID int,
+	settings *counter.Settings,
+	mode counter.ValueMode,
+	version int,
+) (*counter.Settings, counter.State, int, error) {
+	// we need transaction due to sequential select, insert/update queries
+	tx := s.db.Begin()
+	if tx.Error != nil {
+		return nil, counter.State{}, 0, errors.Wrapf(tx.Error, "postgres.SetSettings(#%d): failed to begin transaction", counterID)
+	}
+	var (
+		original = &model.Counter{}
+		previous *counter.Settings
+		state    counter.State
+		next     = 1
+		err      error
+	)
+	switch err = tx.Set("gorm:query_option", "FOR UPDATE").First(original, counterID).Error; {
+	default:
+		tx.Rollback()
+		return nil, counter.State{}, 0, errors.Wrapf(err, "postgres.SetSettings(#%d): failed to get counter", counterID)
+	case version != 0 && (err == gorm.ErrRecordNotFound || (err == nil && original.Version != version)):
+		tx.Rollback()
+		return nil, counter.State{}, 0, errors.Wrapf(counter.ErrVersionMismatch, "postgres.SetSettings(#%d): failed", counterID)
+	case err == nil:
+		// update
+		previous = settingsOf(original)
+		next = original.Version + 1
+		state = counter.State{
+			Value:    settings.Adjust(original.Value, mode),
+			Cycle:    original.Cycle,
+			Previous: original.Value,
+		}
+		err = tx.Model(original).
+			Updates(map[string]interface{}{
+				"value":      state.Value,
+				"increment":  settings.Increment,
+				"lower":      settings.Lower,
+				"upper":      settings.Upper,
+				"start_from": settings.StartFrom,
+				"overflow":   int(settings.Overflow),
+				"version":    next,
+			}).Error
+	case err == gorm.ErrRecordNotFound:
+		// insert
+		state = counter.State{Value: settings.StartFrom, Previous: settings.StartFrom}
+		err = tx.Create(&model.Counter{
+			CounterID: counterID,
+			Value:     state.Value,
+			Increment: settings.Increment,
+			Lower:     settings.Lower,
+			Upper:     settings.Upper,
+			StartFrom: settings.StartFrom,
+			Overflow:  int(settings.Overflow),
+			Version:   next,
+		}).Error
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return nil, counter.State{}, 0, errors.Wrapf(err, "postgres.SetSettings(#%d): failed to set %v", counterID, *settings)
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, counter.State{}, 0, errors.Wrapf(err, "postgres.SetSettings(#%d): failed to commit changes", counterID)
+	}
+	return previous, state, next, nil
+}
+
+// stateOf - extracts counter state from the model.
+func stateOf(c *model.Counter) counter.State {
+	return counter.State{Value: c.Value, Cycle: c.Cycle}
+}
+
+// settingsOf - extracts counter settings from the model.
+func settingsOf(c *model.Counter) *counter.Settings {
+	return &counter.Settings{
+		StartFrom: c.StartFrom,
+		Increment: c.Increment,
+		Lower:     c.Lower,
+		Upper:     c.Upper,
+		Overflow:  counter.OverflowPolicy(c.Overflow),
+	}
+}
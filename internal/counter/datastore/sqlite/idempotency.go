@@ -0,0 +1,33 @@
+package sqlite
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+	"github.com/wtask-go/auracounter/internal/counter"
+	"github.com/wtask-go/auracounter/internal/counter/datastore/gormcore"
+	"github.com/wtask-go/auracounter/internal/counter/datastore/sqlite/model"
+)
+
+// idempotency - statements of idempotent increase for sqlite
+var idempotency = gormcore.Idempotency{Expired: `"counter_id" = ? AND "expires_at" <= ?`}
+
+// IncreaseOnce - implements counter.IdempotentRepository interface.
+// Transaction takes database write lock at the beginning, so concurrent calls with the same key are serialized.
+// Expired keys of the counter are deleted when new key is remembered.
+// If counter/counter settings were not prepared before calling `sqlite.IncreaseOnce`, method will fail.
+func (s *storage) IncreaseOnce(counterID int, key string, ttl time.Duration) (counter.State, bool, error) {
+	c := &model.Counter{}
+	state, replayed, err := idempotency.IncreaseOnce(s.db, c, counterID, key, ttl,
+		func(tx *gorm.DB) (counter.State, error) {
+			state, err := stateOf(c).Increased(settingsOf(c))
+			if err != nil {
+				return state, err
+			}
+			err = tx.Model(c).Updates(map[string]interface{}{"value": state.Value, "cycle": state.Cycle}).Error
+			return state, err
+		},
+	)
+	return state, replayed, errors.Wrapf(err, "sqlite.IncreaseOnce(#%d): failed", counterID)
+}
@@ -0,0 +1,236 @@
+package sqlite
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+	"github.com/wtask-go/auracounter/internal/counter"
+	"github.com/wtask-go/auracounter/internal/counter/datastore/sqlite/model"
+)
+
+func (s *storage) EnsureSettings(counterID int, defaults *counter.Settings) error {
+	tx := s.db.Begin()
+	if tx.Error != nil {
+		return errors.Wrapf(tx.Error, "sqlite.EnsureSettings(#%d): failed to begin transaction.", counterID)
+	}
+	err := tx.Where(&model.Counter{CounterID: counterID}).
+		Attrs(&model.Counter{
+			Value:     defaults.StartFrom,
+			Increment: defaults.Increment,
+			Lower:     defaults.Lower,
+			Upper:     defaults.Upper,
+			StartFrom: defaults.StartFrom,
+			Overflow:  int(defaults.Overflow),
+			Version:   1,
+		}).FirstOrCreate(&model.Counter{}). // ignore result, but will check error
+		Error
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "sqlite.EnsureSettings(#%d): failed", counterID)
+	}
+
+	return errors.Wrapf(tx.Commit().Error, "sqlite.EnsureSettings(#%d): commit failed", counterID)
+}
+
+// Get - return current counter value and cycle
+func (s *storage) GetValue(counterID int) (counter.State, error) {
+	c := &model.Counter{}
+	if err := s.db.First(c, counterID).Error; err != nil {
+		// same here if record not found
+		return counter.State{}, errors.Wrapf(err, "sqlite.GetValue(#%d): failed", counterID)
+	}
+	return stateOf(c), nil
+}
+
+// GetSettings - return current counter settings and their version.
+func (s *storage) GetSettings(counterID int) (*counter.Settings, int, error) {
+	c := &model.Counter{}
+	if err := s.db.First(c, counterID).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			err = counter.ErrNotFound
+		}
+		return nil, 0, errors.Wrapf(err, "sqlite.GetSettings(#%d): failed", counterID)
+	}
+	return settingsOf(c), c.Version, nil
+}
+
+// Increase - increase counter using previously stored settings without validating its consistency.
+// Transaction takes database write lock at the beginning (see `sqlite.NewStorage`),
+// so concurrent calls are serialized and method returns exactly the committed counter value.
+// If counter/counter settings were not prepared before calling `sqlite.Increase`, method will fail.
+// See `sqlite.EnsureSettings`.
+func (s *storage) Increase(counterID int) (counter.State, error) {
+	return s.changeState("Increase", counterID, func(c *model.Counter) (counter.State, error) {
+		return stateOf(c).Increased(settingsOf(c))
+	})
+}
+
+// Decrease - decrease counter using previously stored settings, so it reverts the last increase.
+// Counter wraps from lower boundary to upper boundary, see `counter.Settings.Previous`.
+// If counter/counter settings were not prepared before calling `sqlite.Decrease`, method will fail.
+func (s *storage) Decrease(counterID int) (counter.State, error) {
+	return s.changeState("Decrease", counterID, func(c *model.Counter) (counter.State, error) {
+		return stateOf(c).Decreased(settingsOf(c))
+	})
+}
+
+// DecreaseBy - decrease counter `size` times at once using previously stored settings.
+// Transaction takes database write lock at the beginning, so all decreases are committed together.
+// If counter/counter settings were not prepared before calling `sqlite.DecreaseBy`, method will fail.
+func (s *storage) DecreaseBy(counterID int, size int) (counter.State, error) {
+	return s.changeState("DecreaseBy", counterID, func(c *model.Counter) (counter.State, error) {
+		return stateOf(c).DecreasedBy(settingsOf(c), size)
+	})
+}
+
+// Reset - return counter to its start value, see `counter.Settings.Start`.
+// If counter/counter settings were not prepared before calling `sqlite.Reset`, method will fail.
+func (s *storage) Reset(counterID int) (counter.State, error) {
+	return s.changeState("Reset", counterID, func(c *model.Counter) (counter.State, error) {
+		return stateOf(c).Reset(settingsOf(c)), nil
+	})
+}
+
+// changeState - replaces counter state with the result of `change` func within single transaction
+// and returns committed state. Transaction is rolled back when `change` fails.
+// `method` is used to describe errors only.
+func (s *storage) changeState(
+	method string,
+	counterID int,
+	change func(c *model.Counter) (counter.State, error),
+) (counter.State, error) {
+	tx := s.db.Begin()
+	if tx.Error != nil {
+		return counter.State{}, errors.Wrapf(tx.Error, "sqlite.%s(#%d): failed to begin transaction", method, counterID)
+	}
+	c := &model.Counter{}
+	if err := tx.First(c, counterID).Error; err != nil {
+		tx.Rollback()
+		// same here if record not found
+		return counter.State{}, errors.Wrapf(err, "sqlite.%s(#%d): failed to get counter", method, counterID)
+	}
+	state, err := change(c)
+	if err != nil {
+		tx.Rollback()
+		return counter.State{}, errors.Wrapf(err, "sqlite.%s(#%d): failed", method, counterID)
+	}
+	err = tx.Model(c).Updates(map[string]interface{}{"value": state.Value, "cycle": state.Cycle}).Error
+	if err != nil {
+		tx.Rollback()
+		return counter.State{}, errors.Wrapf(err, "sqlite.%s(#%d): failed", method, counterID)
+	}
+	if err := tx.Commit().Error; err != nil {
+		return counter.State{}, errors.Wrapf(err, "sqlite.%s(#%d): commit failed", method, counterID)
+	}
+	return state, nil
+}
+
+// Reserve - increase counter `size` times at once using previously stored settings.
+// Transaction takes database write lock at the beginning, so the block does not overlap with others.
+// If counter/counter settings were not prepared before calling `sqlite.Reserve`, method will fail.
+func (s *storage) Reserve(counterID int, size int) ([]counter.Range, counter.State, error) {
+	var ranges []counter.Range
+	state, err := s.changeState("Reserve", counterID, func(c *model.Counter) (counter.State, error) {
+		var (
+			state counter.State
+			err   error
+		)
+		ranges, state, err = stateOf(c).Reserved(settingsOf(c), size)
+		return state, err
+	})
+	if err != nil {
+		return nil, counter.State{}, err
+	}
+	return ranges, state, nil
+}
+
+// SetSettings - set new counter settings, current value of existing counter is changed according to mode.
+// New counter is created with start value.
+// When `version` is not zero, settings are changed only if it is equal to current version of settings,
+// otherwise `counter.ErrVersionMismatch` is returned. Every change increases version of settings.
+// Method returns previous settings (nil for new counter), the state after settings were applied
+// (the state keeps previous counter value) and new version of settings.
+func (s *storage) SetSettings(
+	counterID int,
+	settings *counter.Settings,
+	mode counter.ValueMode,
+	version int,
+) (*counter.Settings, counter.State, int, error) {
+	// we need transaction due to sequential select, insert/update queries
+	tx := s.db.Begin()
+	if tx.Error != nil {
+		return nil, counter.State{}, 0, errors.Wrapf(tx.Error, "sqlite.SetSettings(#%d): failed to begin transaction", counterID)
+	}
+	var (
+		original = &model.Counter{}
+		previous *counter.Settings
+		state    counter.State
+		next     = 1
+		err      error
+	)
+	switch err = tx.First(original, counterID).Error; {
+	default:
+		tx.Rollback()
+		return nil, counter.State{}, 0, errors.Wrapf(err, "sqlite.SetSettings(#%d): failed to get counter", counterID)
+	case version != 0 && (err == gorm.ErrRecordNotFound || (err == nil && original.Version != version)):
+		tx.Rollback()
+		return nil, counter.State{}, 0, errors.Wrapf(counter.ErrVersionMismatch, "sqlite.SetSettings(#%d): failed", counterID)
+	case err == nil:
+		// update
+		previous = settingsOf(original)
+		next = original.Version + 1
+		state = counter.State{
+			Value:    settings.Adjust(original.Value, mode),
+			Cycle:    original.Cycle,
+			Previous: original.Value,
+		}
+		err = tx.Model(original).
+			Updates(map[string]interface{}{
+				"value":      state.Value,
+				"increment":  settings.Increment,
+				"lower":      settings.Lower,
+				"upper":      settings.Upper,
+				"start_from": settings.StartFrom,
+				"overflow":   int(settings.Overflow),
+				"version":    next,
+			}).Error
+	case err == gorm.ErrRecordNotFound:
+		// insert
+		state = counter.State{Value: settings.StartFrom, Previous: settings.StartFrom}
+		err = tx.Create(&model.Counter{
+			CounterID: counterID,
+			Value:     state.Value,
+			Increment: settings.Increment,
+			Lower:     settings.Lower,
+			Upper:     settings.Upper,
+			StartFrom: settings.StartFrom,
+			Overflow:  int(settings.Overflow),
+			Version:   next,
+		}).Error
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return nil, counter.State{}, 0, errors.Wrapf(err, "sqlite.SetSettings(#%d): failed to set %v", counterID, *settings)
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, counter.State{}, 0, errors.Wrapf(err, "sqlite.SetSettings(#%d): failed to commit changes", counterID)
+	}
+	return previous, state, next, nil
+}
+
+// stateOf - extracts counter state from the model.
+func stateOf(c *model.Counter) counter.State {
+	return counter.State{Value: c.Value, Cycle: c.Cycle}
+}
+
+// settingsOf - extracts counter settings from the model.
+func settingsOf(c *model.Counter) *counter.Settings {
+	return &counter.Settings{
+		StartFrom: c.StartFrom,
+		Increment: c.Increment,
+		Lower:     c.Lower,
+		Upper:     c.Upper,
+		Overflow:  counter.OverflowPolicy(c.Overflow),
+	}
+}
@@ -0,0 +1,131 @@
+// This test uses SQLite database created in temporary directory,
+// so it does not need any external services and runs with regular tests.
+package sqlite
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+	"github.com/wtask-go/auracounter/internal/counter"
+	"github.com/wtask-go/auracounter/internal/counter/datastore/datastoretest"
+	"github.com/wtask-go/auracounter/internal/counter/datastore/gormcore"
+	"github.com/wtask-go/auracounter/internal/counter/datastore/sqlite/model"
+)
+
+type test func(*testing.T)
+
+// connectDB - creates independent connection to database used for tests.
+func connectDB(dsn, tablePrefix string) *gorm.DB {
+	iface, err := NewStorage(dsn, WithTablePrefix(tablePrefix))
+	if err != nil {
+		panic(errors.Wrap(err, "Failed to build storage interface"))
+	}
+	impl, ok := iface.(*storage)
+	if !ok {
+		panic(errors.New("Typecast failed, expected sqlite.storage"))
+	}
+	return impl.db
+}
+
+// clearDB - drops all known tables in the database
+func clearDB(checker *gorm.DB) error {
+	return checker.DropTable(
+		&model.Counter{},
+		&gormcore.CounterIdempotency{},
+		&gormcore.CounterAudit{},
+	).Error
+}
+
+// clearRows - deletes all records from known tables
+func clearRows(checker *gorm.DB) error {
+	if err := checker.Delete(&model.Counter{}).Error; err != nil {
+		return err
+	}
+	if err := checker.Delete(&gormcore.CounterIdempotency{}).Error; err != nil {
+		return err
+	}
+	return checker.Delete(&gormcore.CounterAudit{}).Error
+}
+
+func TestDatastore(t *testing.T) {
+	// package-level setup
+	dir, err := ioutil.TempDir("", "auracounter")
+	if err != nil {
+		panic(errors.Wrap(err, "Poor testing environment"))
+	}
+	defer os.RemoveAll(dir)
+	dsn := "sqlite://" + filepath.Join(dir, "aura_test.db")
+
+	checker := connectDB(dsn, "")
+	defer func() {
+		clearDB(checker)
+		checker.Close()
+	}()
+
+	clearDB(checker)
+
+	storage, err := NewStorage(dsn)
+	if err != nil {
+		t.Errorf("Unable to create sqlite storage: %v", err)
+	}
+	defer storage.Close()
+	// StorageEnsureLatest runs first,
+	// when the test was successful it should guarantee appropriate db structure
+	if !t.Run("StorageEnsureLatest", StorageEnsureLatest(checker, storage)) {
+		return
+	}
+	t.Run("Repository", func(t *testing.T) {
+		datastoretest.RunRepositorySuite(t, func(t *testing.T) (counter.Repository, func()) {
+			// every test starts with empty tables
+			if err := clearRows(checker); err != nil {
+				t.Fatalf("Unable to clear database: %v", err)
+			}
+			return storage.Repository(), func() {}
+		})
+	})
+	t.Run("StorageAuditLog", func(t *testing.T) {
+		if err := clearRows(checker); err != nil {
+			t.Fatalf("Unable to clear database: %v", err)
+		}
+		datastoretest.RunAuditLogSuite(t, storage.(counter.AuditStorage).AuditLog())
+	})
+}
+
+func StorageEnsureLatest(checker *gorm.DB, storage counter.Storage) test {
+	return func(t *testing.T) {
+		t.Log("TEST: Storage.(sqlite).EnsureLatest()")
+		if checker.HasTable(&model.Counter{}) {
+			t.Error("Unable to start test, model.Counter table exists in the database")
+		}
+		if err := storage.EnsureLatest(); err != nil {
+			t.Errorf("EnsureLatest() for sqlite failed: %v", err)
+		}
+		if !checker.HasTable(&model.Counter{}) {
+			// expected previous err == nil, so table must exits
+			t.Error("Unexpected EnsureLatest() behaviour, model.Counter does not exist in the database")
+		}
+	}
+}
+
+func TestLockingDSN(t *testing.T) {
+	cases := []struct {
+		dsn, expected string
+	}{
+		{"aura.db", "aura.db?_busy_timeout=5000&_txlock=immediate"},
+		{"file:aura.db?mode=rwc", "file:aura.db?_busy_timeout=5000&_txlock=immediate&mode=rwc"},
+		{"aura.db?_txlock=exclusive&_timeout=100", "aura.db?_timeout=100&_txlock=exclusive"},
+	}
+	for _, c := range cases {
+		actual, err := lockingDSN(c.dsn)
+		if err != nil {
+			t.Errorf("lockingDSN(%q): unexpected error: %v", c.dsn, err)
+		}
+		if actual != c.expected {
+			t.Errorf("lockingDSN(%q): expected %q, got %q", c.dsn, c.expected, actual)
+		}
+	}
+}
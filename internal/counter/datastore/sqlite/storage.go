@@ -0,0 +1,157 @@
+package sqlite
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/wtask-go/auracounter/internal/counter/datastore/gormcore"
+	"github.com/wtask-go/auracounter/internal/counter/datastore/sqlite/model"
+
+	"github.com/pkg/errors"
+
+	"github.com/wtask-go/auracounter/internal/counter"
+
+	"github.com/jinzhu/gorm"
+
+	// "mattn/go-sqlite3" initialization via gorm wrapper
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+type (
+	storage struct {
+		db     *gorm.DB
+		dsn    string
+		prefix string
+	}
+
+	storageOption func() (func(*storage), error)
+)
+
+// failedOption - helper to expose error from option builder
+func failedOption(err error) storageOption {
+	return func() (func(*storage), error) {
+		return nil, err
+	}
+}
+
+// properOption - helper to expose setter from option builder
+func properOption(setter func(*storage)) storageOption {
+	return func() (func(*storage), error) {
+		return setter, nil
+	}
+}
+
+// setup - set storage options
+func (s *storage) setup(options ...storageOption) error {
+	if s == nil {
+		return nil
+	}
+	for _, option := range options {
+		if option == nil {
+			continue
+		}
+		setter, err := option()
+		if err != nil {
+			return err
+		}
+		if setter != nil {
+			setter(s)
+		}
+	}
+	return nil
+}
+
+// WithTablePrefix - common custom prefix for underlying database table(s).
+// By default, storage does not use prefix for  table names.
+func WithTablePrefix(prefix string) storageOption {
+	return properOption(func(s *storage) {
+		s.prefix = prefix
+	})
+}
+
+// lockingDSN - complements DSN with params required to serialize counter changes between processes:
+// every transaction takes write lock at the beginning and waits for the lock held by another process.
+func lockingDSN(dsn string) (string, error) {
+	parts := strings.SplitN(dsn, "?", 2)
+	params := url.Values{}
+	if len(parts) > 1 {
+		var err error
+		if params, err = url.ParseQuery(parts[1]); err != nil {
+			return "", err
+		}
+	}
+	if params.Get("_txlock") == "" {
+		params.Set("_txlock", "immediate")
+	}
+	if params.Get("_busy_timeout") == "" && params.Get("_timeout") == "" {
+		params.Set("_busy_timeout", "5000")
+	}
+	return parts[0] + "?" + params.Encode(), nil
+}
+
+// NewStorage - implements counter.Storage interface to store cyclic incremental counter with SQLite.
+// DSN is expected as path to database file with optional `sqlite://` prefix and go-sqlite3 params.
+// SQLite allows single writer only, so storage uses the only connection within the process
+// and takes write lock at the beginning of every transaction (`_txlock=immediate`)
+// to serialize changes made by several processes.
+// If storage was created without errors, you may use it after has ensured it has latest version
+// and is up-to-date, see `EnsureLatest()` method.
+func NewStorage(dsn string, options ...storageOption) (counter.Storage, error) {
+	s := (&storage{
+		dsn: strings.TrimPrefix(dsn, "sqlite://"),
+	})
+
+	if s.dsn == "" {
+		return nil, errors.New("sqlite.NewStorage: required DSN is missed")
+	}
+
+	var err error
+	if s.dsn, err = lockingDSN(s.dsn); err != nil {
+		return nil, errors.Wrap(err, "sqlite.NewStorage: invalid DSN")
+	}
+
+	if err := s.setup(options...); err != nil {
+		return nil, errors.Wrap(err, "sqlite.NewStorage: option error")
+	}
+
+	if s.prefix != "" {
+		gorm.DefaultTableNameHandler = func(db *gorm.DB, defaultTableName string) string {
+			return s.prefix + defaultTableName
+		}
+	}
+
+	db, err := gorm.Open("sqlite3", s.dsn)
+	if err != nil {
+		return nil, errors.Wrap(err, "sqlite.NewStorage: failed to open DB connection")
+	}
+	s.db = db
+
+	s.db.LogMode(false).
+		SingularTable(true)
+	s.db.DB().SetMaxOpenConns(1)
+
+	return s, nil
+}
+
+// EnsureLatest - make sure underlying database has latest version and is up-to-date to store counter.
+func (s *storage) EnsureLatest() error {
+	err := s.db.
+		AutoMigrate(&model.Counter{}, &gormcore.CounterIdempotency{}, &gormcore.CounterAudit{}).
+		Error
+	return errors.Wrap(err, "sqlite.EnsureLatest: failed")
+}
+
+// Close - close and free all used connections and resources.
+func (s *storage) Close() error {
+	if s == nil || s.db == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+func (s *storage) Repository() counter.Repository {
+	if s == nil {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,141 @@
+package counter
+
+import (
+	"sync"
+
+	"github.com/wtask-go/auracounter/internal/api"
+)
+
+// EventHistorySize - how many of the latest events are kept by EventBus to resume subscriptions.
+const EventHistorySize = 1024
+
+type (
+	// EventBus - in-process publish/subscribe bus of counter events,
+	// implements api.CounterEventSource and api.CounterEventHistory.
+	// Publishing never blocks: subscriber whose queue is full is unsubscribed and its channel is closed,
+	// so slow subscriber does not slow down changes of counters.
+	EventBus struct {
+		mx          sync.Mutex
+		subscribers map[*subscriber]struct{}
+		closed      bool
+		// lastID - ID of the latest published event
+		lastID int64
+		// history - up to EventHistorySize of the latest events in order of publishing
+		history []api.CounterEvent
+	}
+
+	// subscriber - queue of events of single subscription
+	subscriber struct {
+		counterID int
+		events    chan api.CounterEvent
+	}
+)
+
+// NewEventBus - builds new event bus without subscribers.
+func NewEventBus() *EventBus {
+	return &EventBus{subscribers: map[*subscriber]struct{}{}}
+}
+
+// Publish - assigns the next ID to the event, keeps it in history
+// and delivers it to subscribers of the counter and to subscribers of all counters.
+func (b *EventBus) Publish(event api.CounterEvent) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	if b.closed {
+		return
+	}
+	b.lastID++
+	event.ID = b.lastID
+	if len(b.history) == EventHistorySize {
+		b.history = b.history[1:]
+	}
+	b.history = append(b.history, event)
+	for s := range b.subscribers {
+		if s.counterID != 0 && s.counterID != event.CounterID {
+			continue
+		}
+		select {
+		case s.events <- event:
+		default:
+			b.unsubscribe(s)
+		}
+	}
+}
+
+// SubscribeCounterEvents - implements api.CounterEventSource interface.
+// Queue of subscriber has room at least for one event.
+func (b *EventBus) SubscribeCounterEvents(counterID, buffer int) (<-chan api.CounterEvent, func()) {
+	if buffer < 1 {
+		buffer = 1
+	}
+	s := &subscriber{counterID: counterID, events: make(chan api.CounterEvent, buffer)}
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	if b.closed {
+		close(s.events)
+		return s.events, func() {}
+	}
+	b.subscribers[s] = struct{}{}
+	return s.events, func() {
+		b.mx.Lock()
+		b.unsubscribe(s)
+		b.mx.Unlock()
+	}
+}
+
+// ResumeCounterEvents - implements api.CounterEventHistory interface.
+// Queue of subscriber has room for all resumed events and for `buffer` events more.
+func (b *EventBus) ResumeCounterEvents(counterID, buffer int, after int64) (<-chan api.CounterEvent, func(), int64, bool) {
+	if buffer < 1 {
+		buffer = 1
+	}
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	// events after `after` are kept when the next one is the first kept event or it is not published yet
+	first := b.lastID + 1
+	if len(b.history) > 0 {
+		first = b.history[0].ID
+	}
+	resumed := !b.closed && after >= 0 && after <= b.lastID && after+1 >= first
+	missed := []api.CounterEvent{}
+	if resumed {
+		for _, event := range b.history[after+1-first:] {
+			if counterID == 0 || event.CounterID == counterID {
+				missed = append(missed, event)
+			}
+		}
+	}
+	s := &subscriber{counterID: counterID, events: make(chan api.CounterEvent, len(missed)+buffer)}
+	if b.closed {
+		close(s.events)
+		return s.events, func() {}, b.lastID, false
+	}
+	for _, event := range missed {
+		s.events <- event
+	}
+	b.subscribers[s] = struct{}{}
+	return s.events, func() {
+		b.mx.Lock()
+		b.unsubscribe(s)
+		b.mx.Unlock()
+	}, b.lastID, resumed
+}
+
+// Close - unsubscribes all subscribers, events are not published after close.
+func (b *EventBus) Close() {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	b.closed = true
+	for s := range b.subscribers {
+		b.unsubscribe(s)
+	}
+}
+
+// unsubscribe - forgets subscriber and closes its channel, the bus must be locked.
+func (b *EventBus) unsubscribe(s *subscriber) {
+	if _, ok := b.subscribers[s]; !ok {
+		return
+	}
+	delete(b.subscribers, s)
+	close(s.events)
+}
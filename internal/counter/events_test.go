@@ -0,0 +1,117 @@
+package counter
+
+import (
+	"testing"
+
+	"github.com/wtask-go/auracounter/internal/api"
+)
+
+func TestEventBus(t *testing.T) {
+	bus := NewEventBus()
+	one, cancelOne := bus.SubscribeCounterEvents(1, 2)
+	all, cancelAll := bus.SubscribeCounterEvents(0, 10)
+	defer cancelAll()
+
+	bus.Publish(api.CounterEvent{Type: api.ValueEvent, CounterID: 1, Value: 1})
+	bus.Publish(api.CounterEvent{Type: api.ValueEvent, CounterID: 2, Value: 1})
+	if e := <-one; e.CounterID != 1 || e.Value != 1 {
+		t.Errorf("Subscriber of counter #1: unexpected event %+v", e)
+	}
+	if len(one) != 0 {
+		t.Errorf("Subscriber of counter #1: unexpected events of other counters")
+	}
+	for _, id := range []int{1, 2} {
+		if e := <-all; e.CounterID != id {
+			t.Errorf("Subscriber of all counters: expected event of counter #%d, got %+v", id, e)
+		}
+	}
+
+	cancelOne()
+	cancelOne()
+	if _, ok := <-one; ok {
+		t.Errorf("Cancelled subscriber: expected closed channel")
+	}
+
+	slow, cancelSlow := bus.SubscribeCounterEvents(1, 1)
+	defer cancelSlow()
+	bus.Publish(api.CounterEvent{Type: api.ValueEvent, CounterID: 1, Value: 2})
+	bus.Publish(api.CounterEvent{Type: api.ValueEvent, CounterID: 1, Value: 3})
+	if e := <-slow; e.Value != 2 {
+		t.Errorf("Slow subscriber: expected queued event, got %+v", e)
+	}
+	if _, ok := <-slow; ok {
+		t.Errorf("Slow subscriber: expected closed channel after overflow of queue")
+	}
+	if len(all) != 2 {
+		t.Errorf("Subscriber of all counters: expected 2 events, got %d", len(all))
+	}
+
+	bus.Close()
+	if _, ok := <-all; !ok {
+		t.Errorf("Subscriber of all counters: expected queued event after close")
+	}
+	<-all
+	if _, ok := <-all; ok {
+		t.Errorf("Subscriber of all counters: expected closed channel after close")
+	}
+	closed, _ := bus.SubscribeCounterEvents(1, 1)
+	if _, ok := <-closed; ok {
+		t.Errorf("Subscriber of closed bus: expected closed channel")
+	}
+	bus.Publish(api.CounterEvent{Type: api.ValueEvent, CounterID: 1})
+}
+
+func TestEventBus_Resume(t *testing.T) {
+	bus := NewEventBus()
+	if _, cancel, last, resumed := bus.ResumeCounterEvents(1, 1, 0); !resumed || last != 0 {
+		t.Errorf("Empty bus: expected resumed subscription after #0, got last %d, resumed %t", last, resumed)
+	} else {
+		cancel()
+	}
+	for i := 1; i <= EventHistorySize+10; i++ {
+		bus.Publish(api.CounterEvent{Type: api.ValueEvent, CounterID: 1 + i%2, Value: int64(i)})
+	}
+	last := int64(EventHistorySize + 10)
+
+	events, cancel, actual, resumed := bus.ResumeCounterEvents(1, 1, last-4)
+	defer cancel()
+	if !resumed || actual != last {
+		t.Errorf("Expected resumed subscription with last #%d, got #%d, resumed %t", last, actual, resumed)
+	}
+	// events of counter #1 have even IDs
+	for _, id := range []int64{last - 2, last} {
+		if e := <-events; e.ID != id || e.CounterID != 1 || e.Value != id {
+			t.Errorf("Expected missed event #%d of counter #1, got %+v", id, e)
+		}
+	}
+	bus.Publish(api.CounterEvent{Type: api.ValueEvent, CounterID: 1})
+	if e := <-events; e.ID != last+1 {
+		t.Errorf("Expected published event #%d, got %+v", last+1, e)
+	}
+
+	cases := []struct {
+		after    int64
+		resumed  bool
+		expected int // number of queued events
+	}{
+		{last + 1, true, 0},
+		{last + 2, false, 0},
+		{-1, false, 0},
+		// the oldest kept event follows the event #11
+		{11, true, EventHistorySize},
+		{10, false, 0},
+	}
+	for _, c := range cases {
+		events, cancel, _, resumed := bus.ResumeCounterEvents(0, 1, c.after)
+		if resumed != c.resumed || len(events) != c.expected {
+			t.Errorf("Resume after #%d: expected resumed %t with %d events, got %t with %d", c.after, c.resumed, c.expected, resumed, len(events))
+		}
+		cancel()
+	}
+
+	bus.Close()
+	closed, _, _, resumed := bus.ResumeCounterEvents(1, 1, last)
+	if _, ok := <-closed; ok || resumed {
+		t.Errorf("Subscriber of closed bus: expected closed channel")
+	}
+}
@@ -0,0 +1,18 @@
+package counter
+
+import "time"
+
+const (
+	// MaxIdempotencyKeyLength - the longest idempotency key which can be stored by repository.
+	MaxIdempotencyKeyLength = 128
+	// DefaultIdempotencyTTL - how long the result of increase is remembered with idempotency key by default.
+	DefaultIdempotencyTTL = 24 * time.Hour
+)
+
+// IdempotentRepository - optional extension of Repository to increase counter once per idempotency key.
+type IdempotentRepository interface {
+	// IncreaseOnce - increase counter and remember the state with given key for `ttl`.
+	// When the key was used for the counter before and is not expired,
+	// counter is not changed and remembered state is returned with `replayed` flag.
+	IncreaseOnce(counterID int, key string, ttl time.Duration) (state State, replayed bool, err error)
+}
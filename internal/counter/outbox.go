@@ -0,0 +1,65 @@
+package counter
+
+import (
+	"time"
+
+	"github.com/wtask-go/auracounter/internal/api"
+)
+
+// OutboxEvent - change of the counter which is recorded by repository within the same transaction as the change,
+// so every committed change is delivered downstream even if the process fails right after the commit.
+// `Ranges` are set for reserve operation only, `Settings` are set for settings operation only.
+type OutboxEvent struct {
+	ID        int64                `json:"id"`
+	CounterID int                  `json:"counter_id"`
+	Time      time.Time            `json:"time"`
+	Operation string               `json:"operation"`
+	Value     int64                `json:"value"`
+	Previous  int64                `json:"previous"`
+	Cycle     int                  `json:"cycle"`
+	Wraps     int                  `json:"wraps,omitempty"`
+	Ranges    []api.IntRange       `json:"ranges,omitempty"`
+	Settings  *api.CounterSettings `json:"settings,omitempty"`
+}
+
+// Outbox - events of counter changes which are waiting for delivery.
+type Outbox interface {
+	// Pending - returns up to `limit` undelivered events in ascending order of IDs.
+	// Events which can not be decoded are moved to dead-letter state instead of being returned,
+	// so they do not block delivery of further events, their IDs are returned as `dead`.
+	Pending(limit int) (events []OutboxEvent, dead []int64, err error)
+	// Delivered - forgets delivered events by their IDs.
+	Delivered(ids []int64) error
+}
+
+// OutboxStorage - storage which is able to record changes of counters into outbox along with changes.
+type OutboxStorage interface {
+	// Outbox - exposes the storage as an outbox.
+	Outbox() Outbox
+}
+
+// NewChangeEvent - builds outbox event of the counter value change,
+// `ranges` are passed for reserve operation only.
+func NewChangeEvent(counterID int, operation string, state State, ranges []Range) *OutboxEvent {
+	event := &OutboxEvent{
+		CounterID: counterID,
+		Time:      time.Now().UTC(),
+		Operation: operation,
+		Value:     state.Value,
+		Previous:  state.Previous,
+		Cycle:     state.Cycle,
+		Wraps:     state.Wraps,
+	}
+	for _, r := range ranges {
+		event.Ranges = append(event.Ranges, api.IntRange{First: r.First, Last: r.Last, Step: r.Step, Count: r.Count})
+	}
+	return event
+}
+
+// NewSettingsEvent - builds outbox event of the counter settings change.
+func NewSettingsEvent(counterID int, settings *Settings, version int, state State) *OutboxEvent {
+	event := NewChangeEvent(counterID, SettingsOperation, state, nil)
+	event.Settings = counterSettings(settings)
+	event.Settings.Version = version
+	return event
+}
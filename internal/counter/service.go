@@ -1,18 +1,62 @@
 package counter
 
 import (
+	"fmt"
+	"sync"
+	"time"
+
 	"github.com/pkg/errors"
 	"github.com/wtask-go/auracounter/internal/api"
 )
 
+const (
+	// MaxBlockSize - the largest number of values which can be reserved at once.
+	MaxBlockSize = 10000
+	// MaxAuditPageSize - the largest number of audit records which can be returned at once.
+	MaxAuditPageSize = 1000
+	// settingsAttempts - how many times unconditional v1 settings change is tried
+	// when settings are changed concurrently between they were loaded and saved.
+	settingsAttempts = 3
+)
+
 type (
-	// service - struct to implement api.CyclicCounterService interface
+	// service - struct to implement api.CyclicCounterService interface,
+	// struct is copied by `WithCaller`, so all shared state must be referenced by pointers
 	service struct {
-		repo      Repository
-		counterID int
-		defaults  *Settings
+		repo     Repository
+		defaults *Settings
+		// ensured - IDs of counters which settings are known as persisted
+		ensured *ensuredCounters
+		// onWrap - optional hook which is called when counter wraps
+		onWrap WrapHook
+		// auditLog - optional sink of counter changes
+		auditLog AuditLog
+		// onAuditFailure - optional handler of audit errors
+		onAuditFailure AuditFailureHandler
+		// events - optional bus to publish changes of counters
+		events *EventBus
+		// caller - client on whose behalf the service acts
+		caller api.Caller
+		// idempotencyTTL - how long the result of increase is remembered with idempotency key
+		idempotencyTTL time.Duration
 	}
 
+	// ensuredCounters - thread-safe set of counter IDs
+	ensuredCounters struct {
+		mx  sync.RWMutex
+		ids map[int]struct{}
+	}
+
+	// WrapHook - func to be notified about counter wraps.
+	// It is called synchronously after the change of counter was persisted,
+	// so the hook should not block for a long time.
+	// For decrease `state.Wraps` is negative.
+	WrapHook func(counterID int, state State)
+
+	// AuditFailureHandler - func to be notified when audit record was not saved.
+	// The change of counter is already persisted at this point, so the failure does not fail the operation.
+	AuditFailureHandler func(record *AuditRecord, err error)
+
 	// serviceOption - high-level func to make service option setter or error
 	serviceOption func() (func(*service), error)
 )
@@ -65,64 +109,547 @@ func WithDefaults(settings *Settings) serviceOption {
 	})
 }
 
+// WithWrapHook - sets the hook to be called every time counter wraps
+// on increase, decrease or block reservation.
+func WithWrapHook(hook WrapHook) serviceOption {
+	if hook == nil {
+		return failedOption(errors.New("counter.WithWrapHook: unable to use nil hook"))
+	}
+	return properOption(func(s *service) {
+		s.onWrap = hook
+	})
+}
+
+// WithAuditLog - sets the sink to record every change of counters after it was persisted.
+// Audit is best-effort, optional `failed` handler is called when the record was not saved,
+// but the change itself is not reverted.
+func WithAuditLog(log AuditLog, failed AuditFailureHandler) serviceOption {
+	if log == nil {
+		return failedOption(errors.New("counter.WithAuditLog: unable to use nil audit log"))
+	}
+	return properOption(func(s *service) {
+		s.auditLog = log
+		s.onAuditFailure = failed
+	})
+}
+
+// WithEventBus - sets the bus to publish every change of counters after it was persisted.
+func WithEventBus(bus *EventBus) serviceOption {
+	if bus == nil {
+		return failedOption(errors.New("counter.WithEventBus: unable to use nil event bus"))
+	}
+	return properOption(func(s *service) {
+		s.events = bus
+	})
+}
+
+// WithIdempotencyTTL - sets how long the result of increase is remembered with idempotency key,
+// see `DefaultIdempotencyTTL`.
+func WithIdempotencyTTL(ttl time.Duration) serviceOption {
+	if ttl <= 0 {
+		return failedOption(errors.Errorf("counter.WithIdempotencyTTL: invalid TTL (%s)", ttl))
+	}
+	return properOption(func(s *service) {
+		s.idempotencyTTL = ttl
+	})
+}
+
 // NewCyclicCounterService - builds new instance of api.CyclicCounterService implementation.
-func NewCyclicCounterService(counterID int, r Repository, options ...serviceOption) (api.CyclicCounterService, error) {
+// Service does not bind any counter at start, settings for every counter will be ensured lazily,
+// when the counter is accessed first time.
+func NewCyclicCounterService(r Repository, options ...serviceOption) (api.CyclicCounterService, error) {
 	// required params
-	if counterID <= 0 {
-		return nil, errors.Errorf("counter.NewCyclicCounterService: invalid counter ID (%d)", counterID)
-	}
 	if r == nil {
 		return nil, errors.New("counter.NewCyclicCounterService: unable to use nil as Repository")
 	}
 	s := &service{
-		repo:      r,
-		counterID: counterID,
-		defaults:  DefaultSettings(),
+		repo:           r,
+		defaults:       DefaultSettings(),
+		ensured:        &ensuredCounters{ids: map[int]struct{}{}},
+		idempotencyTTL: DefaultIdempotencyTTL,
 	}
 	// options
 	if err := s.setup(options...); err != nil {
 		return nil, errors.WithMessage(err, "counter.NewCyclicCounterService: setup error")
 	}
-	if err := s.repo.EnsureSettings(s.counterID, s.defaults); err != nil {
-		return nil,
-			errors.WithMessage(err, "counter.NewCyclicCounterService: unable to ensure counter settings")
-	}
 	return s, nil
 }
 
-// GetCounterValue - return current value of maintained counter.
-func (s *service) GetCounterValue() (*api.IntValueResult, *api.Error) {
-	value, err := s.repo.GetValue(s.counterID)
+// WithCaller - returns copy of the service which acts on behalf of given caller.
+func (s *service) WithCaller(caller api.Caller) api.CyclicCounterService {
+	c := *s
+	c.caller = caller
+	return &c
+}
+
+// has - checks counter settings were ensured before.
+func (e *ensuredCounters) has(counterID int) bool {
+	e.mx.RLock()
+	defer e.mx.RUnlock()
+	_, ok := e.ids[counterID]
+	return ok
+}
+
+// add - remembers counter settings are persisted.
+func (e *ensuredCounters) add(counterID int) {
+	e.mx.Lock()
+	e.ids[counterID] = struct{}{}
+	e.mx.Unlock()
+}
+
+// verifyCounterID - returns client API error for invalid counter ID.
+func verifyCounterID(counterID int) *api.Error {
+	if counterID <= 0 {
+		return &api.Error{Message: fmt.Sprintf("invalid counter ID (%d)", counterID)}
+	}
+	return nil
+}
+
+// ensure - makes sure counter with given ID is valid and has persisted settings.
+// Default settings are saved for unknown counter.
+func (s *service) ensure(counterID int) *api.Error {
+	if err := verifyCounterID(counterID); err != nil {
+		return err
+	}
+	if s.ensured.has(counterID) {
+		return nil
+	}
+	if err := s.repo.EnsureSettings(counterID, s.defaults); err != nil {
+		return &api.Error{Message: "failed to ensure counter settings", Internal: err}
+	}
+	s.ensured.add(counterID)
+	return nil
+}
+
+// notify - calls the wrap hook if the counter wrapped during the last change.
+func (s *service) notify(counterID int, state State) {
+	if s.onWrap != nil && state.Wraps != 0 {
+		s.onWrap(counterID, state)
+	}
+}
+
+// audit - appends the change of counter to the audit log if it is set.
+func (s *service) audit(record *AuditRecord) {
+	if s.auditLog == nil {
+		return
+	}
+	record.Time = time.Now().UTC()
+	record.RequestID = s.caller.RequestID
+	record.Client = s.caller.Client
+	if err := s.auditLog.Append(record); err != nil && s.onAuditFailure != nil {
+		s.onAuditFailure(record, err)
+	}
+}
+
+// auditState - appends the change of counter value to the audit log.
+func (s *service) auditState(counterID int, operation string, state State) {
+	s.audit(&AuditRecord{
+		CounterID: counterID,
+		Operation: operation,
+		OldValue:  state.Previous,
+		NewValue:  state.Value,
+	})
+}
+
+// auditSettings - appends the change of counter settings to the audit log.
+func (s *service) auditSettings(counterID int, previous, settings *Settings, state State) {
+	s.audit(&AuditRecord{
+		CounterID:   counterID,
+		Operation:   SettingsOperation,
+		OldValue:    state.Previous,
+		NewValue:    state.Value,
+		OldSettings: previous,
+		NewSettings: settings,
+	})
+}
+
+// publish - publishes the change of counter value to the event bus if it is set,
+// wrap of the counter is published as separate event.
+func (s *service) publish(counterID int, operation string, state State) {
+	if s.events == nil {
+		return
+	}
+	event := api.CounterEvent{
+		Type:      api.ValueEvent,
+		CounterID: counterID,
+		Time:      time.Now().UTC(),
+		Operation: operation,
+		Value:     state.Value,
+		Cycle:     state.Cycle,
+	}
+	s.events.Publish(event)
+	if state.Wraps != 0 {
+		event.Type = api.WrapEvent
+		event.Wraps = state.Wraps
+		s.events.Publish(event)
+	}
+}
+
+// publishSettings - publishes the change of counter settings to the event bus if it is set.
+func (s *service) publishSettings(counterID int, settings *Settings, version int, state State) {
+	if s.events == nil {
+		return
+	}
+	event := api.CounterEvent{
+		Type:      api.SettingsEvent,
+		CounterID: counterID,
+		Time:      time.Now().UTC(),
+		Operation: SettingsOperation,
+		Value:     state.Value,
+		Cycle:     state.Cycle,
+		Settings:  counterSettings(settings),
+	}
+	event.Settings.Version = version
+	s.events.Publish(event)
+}
+
+// intValueResult - converts counter state into API result.
+func intValueResult(state State) *api.IntValueResult {
+	return &api.IntValueResult{Value: state.Value, Cycle: state.Cycle, Wrapped: state.Wraps != 0}
+}
+
+// repositoryError - converts repository error into API error.
+// Counter overflow and settings version mismatch are reported as client errors
+// with api.CounterOverflowCode and api.VersionMismatchCode, other errors are internal.
+func repositoryError(err error, message string) *api.Error {
+	switch errors.Cause(err) {
+	case ErrOverflow:
+		return &api.Error{Code: api.CounterOverflowCode, Message: "counter overflow"}
+	case ErrVersionMismatch:
+		return &api.Error{Code: api.VersionMismatchCode, Message: "counter settings were changed since expected version"}
+	default:
+		return &api.Error{Message: message, Internal: err}
+	}
+}
+
+// overflowPolicy - returns overflow policy by its API name.
+func overflowPolicy(name string) (OverflowPolicy, error) {
+	switch name {
+	case "", api.WrapOverflow:
+		return WrapOnOverflow, nil
+	case api.SaturateOverflow:
+		return SaturateOnOverflow, nil
+	case api.FailOverflow:
+		return FailOnOverflow, nil
+	default:
+		return 0, fmt.Errorf("unknown overflow policy (%q)", name)
+	}
+}
+
+// counterSettings - converts settings into API struct.
+func counterSettings(settings *Settings) *api.CounterSettings {
+	if settings == nil {
+		return nil
+	}
+	return &api.CounterSettings{
+		Increment: settings.Increment,
+		Lower:     settings.Lower,
+		Upper:     settings.Upper,
+		StartFrom: settings.StartFrom,
+		Overflow:  overflowName(settings.Overflow),
+	}
+}
+
+// overflowName - returns API name of overflow policy.
+func overflowName(policy OverflowPolicy) string {
+	switch policy {
+	case SaturateOnOverflow:
+		return api.SaturateOverflow
+	case FailOnOverflow:
+		return api.FailOverflow
+	default:
+		return api.WrapOverflow
+	}
+}
+
+// GetCounterValue - return current value of counter with given ID.
+func (s *service) GetCounterValue(counterID int) (*api.IntValueResult, *api.Error) {
+	if err := s.ensure(counterID); err != nil {
+		return nil, err
+	}
+	state, err := s.repo.GetValue(counterID)
 	if err != nil {
 		// TODO log internal error
 		return nil, &api.Error{Message: "failed to get counter value", Internal: err}
 	}
-	return &api.IntValueResult{Value: value}, nil
+	return intValueResult(state), nil
 }
 
-// IncreaseCounter - increase value of maintained counter.
-func (s *service) IncreaseCounter() (*api.IntValueResult, *api.Error) {
-	value, err := s.repo.Increase(s.counterID)
+// IncreaseCounter - increase value of counter with given ID.
+func (s *service) IncreaseCounter(counterID int) (*api.IntValueResult, *api.Error) {
+	if err := s.ensure(counterID); err != nil {
+		return nil, err
+	}
+	state, err := s.repo.Increase(counterID)
 	if err != nil {
 		// TODO log internal error
-		return nil, &api.Error{Message: "failed to increase counter", Internal: err}
+		return nil, repositoryError(err, "failed to increase counter")
 	}
-	return &api.IntValueResult{Value: value}, nil
+	s.auditState(counterID, IncreaseOperation, state)
+	s.notify(counterID, state)
+	s.publish(counterID, IncreaseOperation, state)
+	return intValueResult(state), nil
 }
 
-// SetCounterSettings - set new settings for maintained counter.
-func (s *service) SetCounterSettings(increment, lower, upper int) (*api.OKResult, *api.Error) {
-	settings := &Settings{
-		StartFrom: lower, // we disallow to set start in this version
-		Increment: increment,
-		Lower:     lower, // for API v1 expected 0 always
-		Upper:     upper,
+// IncreaseCounterOnce - increase value of counter with given ID once per idempotency key.
+// Repository must implement IdempotentRepository.
+func (s *service) IncreaseCounterOnce(counterID int, idempotencyKey string) (*api.IntValueResult, *api.Error) {
+	if idempotencyKey == "" || len(idempotencyKey) > MaxIdempotencyKeyLength {
+		return nil, &api.Error{
+			Message: fmt.Sprintf("idempotency key length is out of the range [1:%d]", MaxIdempotencyKeyLength),
+		}
 	}
-	if err := settings.verify(); err != nil {
+	repo, ok := s.repo.(IdempotentRepository)
+	if !ok {
+		return nil, &api.Error{Message: "idempotency keys are not supported by counter storage"}
+	}
+	if err := s.ensure(counterID); err != nil {
+		return nil, err
+	}
+	state, replayed, err := repo.IncreaseOnce(counterID, idempotencyKey, s.idempotencyTTL)
+	if err != nil {
+		return nil, repositoryError(err, "failed to increase counter")
+	}
+	result := intValueResult(state)
+	if replayed {
+		// the counter was not changed
+		result.Replayed = true
+		return result, nil
+	}
+	s.auditState(counterID, IncreaseOperation, state)
+	s.notify(counterID, state)
+	s.publish(counterID, IncreaseOperation, state)
+	return result, nil
+}
+
+// DecreaseCounter - decrease value of counter with given ID.
+func (s *service) DecreaseCounter(counterID int) (*api.IntValueResult, *api.Error) {
+	if err := s.ensure(counterID); err != nil {
+		return nil, err
+	}
+	state, err := s.repo.Decrease(counterID)
+	if err != nil {
+		return nil, repositoryError(err, "failed to decrease counter")
+	}
+	s.auditState(counterID, DecreaseOperation, state)
+	s.notify(counterID, state)
+	s.publish(counterID, DecreaseOperation, state)
+	return intValueResult(state), nil
+}
+
+// DecreaseCounterBy - decrease value of counter with given ID `size` times at once.
+func (s *service) DecreaseCounterBy(counterID, size int) (*api.IntValueResult, *api.Error) {
+	if size < 1 || size > MaxBlockSize {
+		return nil, &api.Error{Message: fmt.Sprintf("decrease size (%d) is out of the range [1:%d]", size, MaxBlockSize)}
+	}
+	if err := s.ensure(counterID); err != nil {
+		return nil, err
+	}
+	state, err := s.repo.DecreaseBy(counterID, size)
+	if err != nil {
+		return nil, repositoryError(err, "failed to decrease counter")
+	}
+	s.auditState(counterID, DecreaseOperation, state)
+	s.notify(counterID, state)
+	s.publish(counterID, DecreaseOperation, state)
+	return intValueResult(state), nil
+}
+
+// ResetCounter - return counter with given ID to its start value.
+func (s *service) ResetCounter(counterID int) (*api.IntValueResult, *api.Error) {
+	if err := s.ensure(counterID); err != nil {
+		return nil, err
+	}
+	state, err := s.repo.Reset(counterID)
+	if err != nil {
+		return nil, &api.Error{Message: "failed to reset counter", Internal: err}
+	}
+	s.auditState(counterID, ResetOperation, state)
+	s.publish(counterID, ResetOperation, state)
+	return intValueResult(state), nil
+}
+
+// ReserveCounterBlock - increase counter with given ID `size` times at once.
+func (s *service) ReserveCounterBlock(counterID, size int) (*api.BlockResult, *api.Error) {
+	if size < 1 || size > MaxBlockSize {
+		return nil, &api.Error{Message: fmt.Sprintf("block size (%d) is out of the range [1:%d]", size, MaxBlockSize)}
+	}
+	if err := s.ensure(counterID); err != nil {
+		return nil, err
+	}
+	ranges, state, err := s.repo.Reserve(counterID, size)
+	if err != nil {
+		return nil, repositoryError(err, "failed to reserve counter block")
+	}
+	s.auditState(counterID, ReserveOperation, state)
+	s.notify(counterID, state)
+	s.publish(counterID, ReserveOperation, state)
+	result := &api.BlockResult{
+		Ranges:  make([]api.IntRange, len(ranges)),
+		Cycle:   state.Cycle,
+		Wrapped: state.Wraps != 0,
+	}
+	for i, r := range ranges {
+		result.Ranges[i] = api.IntRange{First: r.First, Last: r.Last, Step: r.Step, Count: r.Count}
+	}
+	return result, nil
+}
+
+// SetCounterSettings - set new settings for counter with given ID.
+// API v1 does not expose overflow policy and start value, so they are kept for existing counter,
+// the start value is set to default only for new counter or when it is out of the new range.
+// Settings are saved with the version they were loaded, unconditional change (zero `version`)
+// is retried when settings were changed concurrently.
+func (s *service) SetCounterSettings(
+	counterID int,
+	increment, lower, upper int64,
+	version int,
+) (*api.SettingsResult, *api.Error) {
+	if err := verifyCounterID(counterID); err != nil {
+		return nil, err
+	}
+	for attempt := 1; ; attempt++ {
+		current, expected, err := s.repo.GetSettings(counterID)
+		switch {
+		case errors.Cause(err) == ErrNotFound:
+			// new counter wraps on overflow and starts from default value
+			current, expected = nil, version
+		case err != nil:
+			return nil, &api.Error{Message: "failed to get counter settings", Internal: err}
+		case version != 0 && version != expected:
+			return nil, repositoryError(ErrVersionMismatch, "failed to set new settings")
+		}
+		settings := &Settings{
+			StartFrom: lower,
+			Increment: increment,
+			Lower:     lower, // for API v1 expected 0 always
+			Upper:     upper,
+		}
+		if increment < 0 {
+			// descending counter starts from upper boundary
+			settings.StartFrom = upper
+		}
+		if current != nil {
+			settings.Overflow = current.Overflow
+			if lower <= current.StartFrom && current.StartFrom <= upper {
+				settings.StartFrom = current.StartFrom
+			}
+		}
+		if err := settings.verify(); err != nil {
+			return nil, &api.Error{Message: err.Error()}
+		}
+		previous, state, next, err := s.repo.SetSettings(counterID, settings, PreserveValue, expected)
+		if errors.Cause(err) == ErrVersionMismatch && version == 0 && attempt < settingsAttempts {
+			continue
+		}
+		if err != nil {
+			return nil, repositoryError(err, "failed to set new settings")
+		}
+		// repository creates counter if it did not exist
+		s.ensured.add(counterID)
+		s.auditSettings(counterID, previous, settings, state)
+		s.publishSettings(counterID, settings, next, state)
+		return &api.SettingsResult{OK: true, Version: next}, nil
+	}
+}
+
+// GetCounterSettings - return current settings of counter with given ID.
+func (s *service) GetCounterSettings(counterID int) (*api.CounterSettings, *api.Error) {
+	if err := s.ensure(counterID); err != nil {
+		return nil, err
+	}
+	settings, version, err := s.repo.GetSettings(counterID)
+	if err != nil {
+		return nil, &api.Error{Message: "failed to get counter settings", Internal: err}
+	}
+	result := counterSettings(settings)
+	result.Version = version
+	return result, nil
+}
+
+// UpdateCounterSettings - set new settings for counter with given ID and change its value according to mode.
+func (s *service) UpdateCounterSettings(
+	counterID int,
+	settings *api.CounterSettings,
+	valueMode string,
+	version int,
+) (*api.SettingsResult, *api.Error) {
+	if err := verifyCounterID(counterID); err != nil {
+		return nil, err
+	}
+	if settings == nil {
+		return nil, &api.Error{Message: "counter settings are required"}
+	}
+	var mode ValueMode
+	switch valueMode {
+	case "", api.PreserveValue:
+		mode = PreserveValue
+	case api.ClampValue:
+		mode = ClampValue
+	case api.ResetValue:
+		mode = ResetValue
+	default:
+		return nil, &api.Error{Message: fmt.Sprintf("unknown value mode (%q)", valueMode)}
+	}
+	overflow, err := overflowPolicy(settings.Overflow)
+	if err != nil {
 		return nil, &api.Error{Message: err.Error()}
 	}
-	if err := s.repo.SetSettings(s.counterID, settings); err != nil {
-		return nil, &api.Error{Message: "failed to set new settings", Internal: err}
+	updated := &Settings{
+		StartFrom: settings.StartFrom,
+		Increment: settings.Increment,
+		Lower:     settings.Lower,
+		Upper:     settings.Upper,
+		Overflow:  overflow,
+	}
+	if err := updated.verify(); err != nil {
+		return nil, &api.Error{Message: err.Error()}
+	}
+	previous, state, next, err := s.repo.SetSettings(counterID, updated, mode, version)
+	if err != nil {
+		return nil, repositoryError(err, "failed to set new settings")
+	}
+	// repository creates counter if it did not exist
+	s.ensured.add(counterID)
+	s.auditSettings(counterID, previous, updated, state)
+	s.publishSettings(counterID, updated, next, state)
+	return &api.SettingsResult{OK: true, Version: next}, nil
+}
+
+// GetCounterAudit - return page of audit records of counter with given ID.
+func (s *service) GetCounterAudit(counterID int, after int64, limit int) (*api.AuditPage, *api.Error) {
+	if err := verifyCounterID(counterID); err != nil {
+		return nil, err
+	}
+	if s.auditLog == nil {
+		return nil, &api.Error{Message: "counter audit is disabled"}
+	}
+	if limit < 1 || limit > MaxAuditPageSize {
+		return nil, &api.Error{Message: fmt.Sprintf("page size (%d) is out of the range [1:%d]", limit, MaxAuditPageSize)}
+	}
+	if after < 0 {
+		return nil, &api.Error{Message: fmt.Sprintf("invalid audit record ID (%d)", after)}
+	}
+	records, err := s.auditLog.List(counterID, after, limit)
+	if err != nil {
+		return nil, &api.Error{Message: "failed to get counter audit", Internal: err}
+	}
+	page := &api.AuditPage{Records: make([]api.AuditRecord, len(records))}
+	for i, r := range records {
+		page.Records[i] = api.AuditRecord{
+			ID:          r.ID,
+			Time:        r.Time,
+			Operation:   r.Operation,
+			OldValue:    r.OldValue,
+			NewValue:    r.NewValue,
+			OldSettings: counterSettings(r.OldSettings),
+			NewSettings: counterSettings(r.NewSettings),
+			RequestID:   r.RequestID,
+			Client:      r.Client,
+		}
+	}
+	if len(records) == limit {
+		page.Next = records[len(records)-1].ID
 	}
-	return &api.OKResult{OK: true}, nil
+	return page, nil
 }
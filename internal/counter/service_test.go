@@ -1,45 +1,114 @@
 package counter
 
 import (
-	"errors"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/wtask-go/auracounter/internal/api"
 )
 
 type repository struct {
+	ensured            []int // IDs of counters passed into EnsureSettings
 	failEnsureSettings bool
 	failGet            bool
 	failIncrease       bool
+	overflow           bool // Increase, Decrease and Reserve fail with ErrOverflow
+	failDecrease       bool
+	failReset          bool
+	failReserve        bool
+	failGetSettings    bool
 	failSetSettings    bool
+	mode               ValueMode // the last mode passed into SetSettings
+	version            int       // the current version of settings, it is increased by SetSettings
+	settings           *Settings // the last settings passed into SetSettings, defaults are used when nil
+	wraps              bool      // Increase, Decrease and Reserve report the counter wrapped
 }
 
-func (r *repository) EnsureSettings(_ int, _ *Settings) error {
+// state - returns the state of changed counter.
+func (r *repository) state(wraps int) State {
+	if !r.wraps {
+		return State{}
+	}
+	return State{Cycle: wraps, Wraps: wraps}
+}
+
+func (r *repository) EnsureSettings(counterID int, _ *Settings) error {
 	if r.failEnsureSettings {
 		return errors.New("repository.EnsureSettings() failed")
 	}
+	r.ensured = append(r.ensured, counterID)
 	return nil
 }
 
-func (r *repository) GetValue(_ int) (int, error) {
+func (r *repository) GetValue(_ int) (State, error) {
 	if r.failGet {
-		return 0, errors.New("repository.Get() failed")
+		return State{}, errors.New("repository.Get() failed")
 	}
-	return 0, nil
+	return State{}, nil
 }
 
-func (r *repository) Increase(_ int) (int, error) {
+func (r *repository) Increase(_ int) (State, error) {
 	if r.failIncrease {
-		return 0, errors.New("repository.Increase() failed")
+		return State{}, errors.New("repository.Increase() failed")
 	}
-	return 0, nil
+	if r.overflow {
+		return State{}, errors.Wrap(ErrOverflow, "repository.Increase() failed")
+	}
+	return r.state(1), nil
 }
 
-func (r *repository) SetSettings(_ int, _ *Settings) error {
+func (r *repository) Decrease(_ int) (State, error) {
+	if r.failDecrease {
+		return State{}, errors.New("repository.Decrease() failed")
+	}
+	return r.state(-1), nil
+}
+
+func (r *repository) DecreaseBy(_, _ int) (State, error) {
+	if r.failDecrease {
+		return State{}, errors.New("repository.DecreaseBy() failed")
+	}
+	return r.state(-1), nil
+}
+
+func (r *repository) Reset(_ int) (State, error) {
+	if r.failReset {
+		return State{}, errors.New("repository.Reset() failed")
+	}
+	return State{}, nil
+}
+
+func (r *repository) Reserve(_ int, size int) ([]Range, State, error) {
+	if r.failReserve {
+		return nil, State{}, errors.New("repository.Reserve() failed")
+	}
+	return []Range{{First: 1, Last: int64(size), Step: 1, Count: size}}, r.state(1), nil
+}
+
+func (r *repository) GetSettings(_ int) (*Settings, int, error) {
+	if r.failGetSettings {
+		return nil, 0, errors.New("repository.GetSettings() failed")
+	}
+	if r.settings != nil {
+		settings := *r.settings
+		return &settings, r.version, nil
+	}
+	return DefaultSettings(), r.version, nil
+}
+
+func (r *repository) SetSettings(_ int, settings *Settings, mode ValueMode, version int) (*Settings, State, int, error) {
 	if r.failSetSettings {
-		return errors.New("repository.SetSettings() failed")
+		return nil, State{}, 0, errors.New("repository.SetSettings() failed")
 	}
-	return nil
+	if version != 0 && version != r.version {
+		return nil, State{}, 0, ErrVersionMismatch
+	}
+	r.mode = mode
+	r.settings = settings
+	r.version++
+	return DefaultSettings(), State{}, r.version, nil
 }
 
 func TestServiceBuilder(t *testing.T) {
@@ -49,68 +118,48 @@ func TestServiceBuilder(t *testing.T) {
 		mustSuccessful bool
 	}{
 		{
-			"NewCyclicCounterService(-1, nil)",
-			func() (api.CyclicCounterService, error) { return NewCyclicCounterService(-1, nil) },
-			false,
-		},
-		{
-			"NewCyclicCounterService(0, nil)",
-			func() (api.CyclicCounterService, error) { return NewCyclicCounterService(0, nil) },
-			false,
-		},
-		{
-			"NewCyclicCounterService(1, nil)",
-			func() (api.CyclicCounterService, error) { return NewCyclicCounterService(1, nil) },
+			"NewCyclicCounterService(nil)",
+			func() (api.CyclicCounterService, error) { return NewCyclicCounterService(nil) },
 			false,
 		},
 		{
-			"NewCyclicCounterService(1, &repository{failEnsureSettings: true})",
+			// settings are ensured lazily, so builder does not touch repository
+			"NewCyclicCounterService(&repository{failEnsureSettings: true})",
 			func() (api.CyclicCounterService, error) {
-				return NewCyclicCounterService(1, &repository{failEnsureSettings: true})
+				return NewCyclicCounterService(&repository{failEnsureSettings: true})
 			},
-			false,
-		},
-		{
-			"NewCyclicCounterService(-1, &repository{})",
-			func() (api.CyclicCounterService, error) { return NewCyclicCounterService(-1, &repository{}) },
-			false,
-		},
-		{
-			"NewCyclicCounterService(0, &repository{})",
-			func() (api.CyclicCounterService, error) { return NewCyclicCounterService(0, &repository{}) },
-			false,
+			true,
 		},
 		{
-			"NewCyclicCounterService(1, &repository{})",
-			func() (api.CyclicCounterService, error) { return NewCyclicCounterService(1, &repository{}) },
+			"NewCyclicCounterService(&repository{})",
+			func() (api.CyclicCounterService, error) { return NewCyclicCounterService(&repository{}) },
 			true,
 		},
 		{
-			"NewCyclicCounterService(1, &repository{}, WithDefaults(DefaultSettings()))",
+			"NewCyclicCounterService(&repository{}, WithDefaults(DefaultSettings()))",
 			func() (api.CyclicCounterService, error) {
-				return NewCyclicCounterService(1, &repository{}, WithDefaults(DefaultSettings()))
+				return NewCyclicCounterService(&repository{}, WithDefaults(DefaultSettings()))
 			},
 			true,
 		},
 		{
-			"NewCyclicCounterService(1, &repository{}, WithDefaults(&Settings{Increment: -1}))",
+			"NewCyclicCounterService(&repository{}, WithDefaults(&Settings{Increment: -1}))",
 			func() (api.CyclicCounterService, error) {
-				return NewCyclicCounterService(1, &repository{}, WithDefaults(&Settings{Increment: -1}))
+				return NewCyclicCounterService(&repository{}, WithDefaults(&Settings{Increment: -1}))
 			},
 			false,
 		},
 		{
-			"NewCyclicCounterService(1, &repository{}, WithDefaults(&Settings{Increment: 0}))",
+			"NewCyclicCounterService(&repository{}, WithDefaults(&Settings{Increment: 0}))",
 			func() (api.CyclicCounterService, error) {
-				return NewCyclicCounterService(1, &repository{}, WithDefaults(&Settings{Increment: 0}))
+				return NewCyclicCounterService(&repository{}, WithDefaults(&Settings{Increment: 0}))
 			},
 			true,
 		},
 		{
-			"NewCyclicCounterService(1, &repository{}, WithDefaults(&Settings{StartFrom: -1, Upper: 1}))",
+			"NewCyclicCounterService(&repository{}, WithDefaults(&Settings{StartFrom: -1, Upper: 1}))",
 			func() (api.CyclicCounterService, error) {
 				return NewCyclicCounterService(
-					1,
 					&repository{},
 					WithDefaults(&Settings{StartFrom: -1, Upper: 1}),
 				)
@@ -118,10 +167,9 @@ func TestServiceBuilder(t *testing.T) {
 			false,
 		},
 		{
-			"NewCyclicCounterService(1, &repository{}, WithDefaults(&Settings{Lower: -1, Upper: 1, Increment: 1}))",
+			"NewCyclicCounterService(&repository{}, WithDefaults(&Settings{Lower: -1, Upper: 1, Increment: 1}))",
 			func() (api.CyclicCounterService, error) {
 				return NewCyclicCounterService(
-					1,
 					&repository{},
 					WithDefaults(&Settings{Lower: -1, Upper: 1, Increment: 1}),
 				)
@@ -129,10 +177,9 @@ func TestServiceBuilder(t *testing.T) {
 			true,
 		},
 		{
-			"NewCyclicCounterService(1, &repository{}, WithDefaults(&Settings{Lower: 1, Upper: -1, Increment: 1}))",
+			"NewCyclicCounterService(&repository{}, WithDefaults(&Settings{Lower: 1, Upper: -1, Increment: 1}))",
 			func() (api.CyclicCounterService, error) {
 				return NewCyclicCounterService(
-					1,
 					&repository{},
 					WithDefaults(&Settings{Lower: 1, Upper: -1, Increment: 1}),
 				)
@@ -140,10 +187,9 @@ func TestServiceBuilder(t *testing.T) {
 			false,
 		},
 		{
-			"NewCyclicCounterService(1, &repository{}, WithDefaults(&Settings{Lower: 1, Upper: -1, Increment: 1}))",
+			"NewCyclicCounterService(&repository{}, WithDefaults(&Settings{Lower: 1, Upper: 3, Increment: 3}))",
 			func() (api.CyclicCounterService, error) {
 				return NewCyclicCounterService(
-					1,
 					&repository{},
 					WithDefaults(&Settings{Lower: 1, Upper: 3, Increment: 3}),
 				)
@@ -171,13 +217,13 @@ func TestServiceBuilder(t *testing.T) {
 }
 
 func TestService_GetValue(t *testing.T) {
-	service, err := NewCyclicCounterService(1, &repository{}) // good working repo
+	service, err := NewCyclicCounterService(&repository{}) // good working repo
 	if err != nil {
 		// duplicates cases in TestServiceBuilder
 		t.Errorf("Unexpected error when building service with non-failed repository: %+v", err)
 	}
 
-	intResult, apiErr := service.GetCounterValue()
+	intResult, apiErr := service.GetCounterValue(1)
 	if intResult == nil {
 		t.Errorf("GetCounterValue(): unexpected nil as result")
 	}
@@ -185,12 +231,12 @@ func TestService_GetValue(t *testing.T) {
 		t.Errorf("GetCounterValue(): unexpected API error %q", apiErr.ExposeError())
 	}
 
-	service, err = NewCyclicCounterService(1, &repository{failGet: true})
+	service, err = NewCyclicCounterService(&repository{failGet: true})
 	if err != nil {
 		// duplicates cases in TestServiceBuilder
 		t.Errorf("Unexpected error when building service: %+v", err)
 	}
-	intResult, apiErr = service.GetCounterValue()
+	intResult, apiErr = service.GetCounterValue(1)
 	if intResult != nil {
 		t.Errorf("GetCounterValue(): unexpected non-nil result %+v", intResult)
 	}
@@ -200,13 +246,13 @@ func TestService_GetValue(t *testing.T) {
 }
 
 func TestService_Increase(t *testing.T) {
-	service, err := NewCyclicCounterService(1, &repository{}) // good working repo
+	service, err := NewCyclicCounterService(&repository{}) // good working repo
 	if err != nil {
 		// duplicates cases in TestServiceBuilder
 		t.Errorf("Unexpected error when building service with non-failed repository: %+v", err)
 	}
 
-	intResult, apiErr := service.IncreaseCounter()
+	intResult, apiErr := service.IncreaseCounter(1)
 	if intResult == nil {
 		t.Errorf("IncreaseCounter(): unexpected nil as result")
 	}
@@ -214,12 +260,12 @@ func TestService_Increase(t *testing.T) {
 		t.Errorf("IncreaseCounter(): unexpected API error %q", apiErr.ExposeError())
 	}
 
-	service, err = NewCyclicCounterService(1, &repository{failIncrease: true})
+	service, err = NewCyclicCounterService(&repository{failIncrease: true})
 	if err != nil {
 		// duplicates cases in TestServiceBuilder
 		t.Errorf("Unexpected error when building service: %+v", err)
 	}
-	intResult, apiErr = service.IncreaseCounter()
+	intResult, apiErr = service.IncreaseCounter(1)
 	if intResult != nil {
 		t.Errorf("IncreaseCounter(): unexpected non-nil result %+v", intResult)
 	}
@@ -228,66 +274,231 @@ func TestService_Increase(t *testing.T) {
 	}
 }
 
+func TestService_IncreaseOverflow(t *testing.T) {
+	service, err := NewCyclicCounterService(&repository{overflow: true})
+	if err != nil {
+		// duplicates cases in TestServiceBuilder
+		t.Errorf("Unexpected error when building service: %+v", err)
+	}
+	intResult, apiErr := service.IncreaseCounter(1)
+	if intResult != nil {
+		t.Errorf("IncreaseCounter(): unexpected non-nil result %+v", intResult)
+	}
+	if apiErr == nil || apiErr.IsInternal() || apiErr.Code != api.CounterOverflowCode {
+		t.Errorf("IncreaseCounter(): expected client API error with overflow code, got %+v", apiErr)
+	}
+}
+
+func TestService_Decrease(t *testing.T) {
+	service, err := NewCyclicCounterService(&repository{}) // good working repo
+	if err != nil {
+		// duplicates cases in TestServiceBuilder
+		t.Errorf("Unexpected error when building service with non-failed repository: %+v", err)
+	}
+
+	intResult, apiErr := service.DecreaseCounter(1)
+	if intResult == nil {
+		t.Errorf("DecreaseCounter(): unexpected nil as result")
+	}
+	if apiErr != nil {
+		t.Errorf("DecreaseCounter(): unexpected API error %q", apiErr.ExposeError())
+	}
+
+	service, err = NewCyclicCounterService(&repository{failDecrease: true})
+	if err != nil {
+		// duplicates cases in TestServiceBuilder
+		t.Errorf("Unexpected error when building service: %+v", err)
+	}
+	intResult, apiErr = service.DecreaseCounter(1)
+	if intResult != nil {
+		t.Errorf("DecreaseCounter(): unexpected non-nil result %+v", intResult)
+	}
+	if apiErr == nil {
+		t.Errorf("DecreaseCounter(): expected API error, but got nil")
+	}
+}
+
+func TestService_DecreaseBy(t *testing.T) {
+	service, err := NewCyclicCounterService(&repository{}) // good working repo
+	if err != nil {
+		// duplicates cases in TestServiceBuilder
+		t.Errorf("Unexpected error when building service with non-failed repository: %+v", err)
+	}
+	for _, size := range []int{1, MaxBlockSize} {
+		intResult, apiErr := service.DecreaseCounterBy(1, size)
+		if intResult == nil || apiErr != nil {
+			t.Errorf("DecreaseCounterBy(1, %d): unexpected result %+v (%v)", size, intResult, apiErr)
+		}
+	}
+	for _, size := range []int{-1, 0, MaxBlockSize + 1} {
+		intResult, apiErr := service.DecreaseCounterBy(1, size)
+		if intResult != nil {
+			t.Errorf("DecreaseCounterBy(1, %d): unexpected non-nil result %+v", size, intResult)
+		}
+		if apiErr == nil || apiErr.IsInternal() {
+			t.Errorf("DecreaseCounterBy(1, %d): expected client API error, got %v", size, apiErr)
+		}
+	}
+
+	service, err = NewCyclicCounterService(&repository{failDecrease: true})
+	if err != nil {
+		// duplicates cases in TestServiceBuilder
+		t.Errorf("Unexpected error when building service: %+v", err)
+	}
+	intResult, apiErr := service.DecreaseCounterBy(1, 10)
+	if intResult != nil {
+		t.Errorf("DecreaseCounterBy(): unexpected non-nil result %+v", intResult)
+	}
+	if apiErr == nil {
+		t.Errorf("DecreaseCounterBy(): expected API error, but got nil")
+	}
+}
+
+func TestService_Reset(t *testing.T) {
+	service, err := NewCyclicCounterService(&repository{}) // good working repo
+	if err != nil {
+		// duplicates cases in TestServiceBuilder
+		t.Errorf("Unexpected error when building service with non-failed repository: %+v", err)
+	}
+
+	intResult, apiErr := service.ResetCounter(1)
+	if intResult == nil {
+		t.Errorf("ResetCounter(): unexpected nil as result")
+	}
+	if apiErr != nil {
+		t.Errorf("ResetCounter(): unexpected API error %q", apiErr.ExposeError())
+	}
+
+	service, err = NewCyclicCounterService(&repository{failReset: true})
+	if err != nil {
+		// duplicates cases in TestServiceBuilder
+		t.Errorf("Unexpected error when building service: %+v", err)
+	}
+	intResult, apiErr = service.ResetCounter(1)
+	if intResult != nil {
+		t.Errorf("ResetCounter(): unexpected non-nil result %+v", intResult)
+	}
+	if apiErr == nil {
+		t.Errorf("ResetCounter(): expected API error, but got nil")
+	}
+}
+
+func TestService_ReserveBlock(t *testing.T) {
+	service, err := NewCyclicCounterService(&repository{}) // good working repo
+	if err != nil {
+		// duplicates cases in TestServiceBuilder
+		t.Errorf("Unexpected error when building service with non-failed repository: %+v", err)
+	}
+
+	for _, size := range []int{1, 100, MaxBlockSize} {
+		blockResult, apiErr := service.ReserveCounterBlock(1, size)
+		if blockResult == nil || len(blockResult.Ranges) != 1 || blockResult.Ranges[0].Count != size {
+			t.Errorf("ReserveCounterBlock(1, %d): unexpected result %+v", size, blockResult)
+		}
+		if apiErr != nil {
+			t.Errorf("ReserveCounterBlock(1, %d): unexpected API error %q", size, apiErr.ExposeError())
+		}
+	}
+	for _, size := range []int{-1, 0, MaxBlockSize + 1} {
+		blockResult, apiErr := service.ReserveCounterBlock(1, size)
+		if blockResult != nil {
+			t.Errorf("ReserveCounterBlock(1, %d): unexpected non-nil result %+v", size, blockResult)
+		}
+		if apiErr == nil || apiErr.IsInternal() {
+			t.Errorf("ReserveCounterBlock(1, %d): expected client API error, got %v", size, apiErr)
+		}
+	}
+
+	service, err = NewCyclicCounterService(&repository{failReserve: true})
+	if err != nil {
+		// duplicates cases in TestServiceBuilder
+		t.Errorf("Unexpected error when building service: %+v", err)
+	}
+	blockResult, apiErr := service.ReserveCounterBlock(1, 10)
+	if blockResult != nil {
+		t.Errorf("ReserveCounterBlock(): unexpected non-nil result %+v", blockResult)
+	}
+	if apiErr == nil {
+		t.Errorf("ReserveCounterBlock(): expected API error, but got nil")
+	}
+}
+
 func TestService_SetSettings(t *testing.T) {
 	cases := []struct {
 		signature          string
-		setCounterSettings func(s api.CyclicCounterService) (*api.OKResult, *api.Error)
+		setCounterSettings func(s api.CyclicCounterService) (*api.SettingsResult, *api.Error)
 		mustSuccessful     bool // if true faulty must fail
 	}{
 		{
 			// useless or sleepping counter
-			"SetCounterSettings(0, 0, 0)",
-			func(s api.CyclicCounterService) (*api.OKResult, *api.Error) {
-				return s.SetCounterSettings(0, 0, 0)
+			"SetCounterSettings(1, 0, 0, 0, 0)",
+			func(s api.CyclicCounterService) (*api.SettingsResult, *api.Error) {
+				return s.SetCounterSettings(1, 0, 0, 0, 0)
+			},
+			true,
+		},
+		{
+			// negative increment, descending counter
+			"SetCounterSettings(1, -1, 0, 1, 0)",
+			func(s api.CyclicCounterService) (*api.SettingsResult, *api.Error) {
+				return s.SetCounterSettings(1, -1, 0, 1, 0)
 			},
 			true,
 		},
 		{
-			// negative increment
-			"SetCounterSettings(-1, 0, 1)",
-			func(s api.CyclicCounterService) (*api.OKResult, *api.Error) {
-				return s.SetCounterSettings(-1, 0, 1)
+			// negative increment is wider than range
+			"SetCounterSettings(1, -2, 0, 1, 0)",
+			func(s api.CyclicCounterService) (*api.SettingsResult, *api.Error) {
+				return s.SetCounterSettings(1, -2, 0, 1, 0)
 			},
 			false,
 		},
 		{
-			"SetCounterSettings(0, 0, 1)",
-			func(s api.CyclicCounterService) (*api.OKResult, *api.Error) {
-				return s.SetCounterSettings(0, 0, 1)
+			"SetCounterSettings(1, 0, 0, 1, 0)",
+			func(s api.CyclicCounterService) (*api.SettingsResult, *api.Error) {
+				return s.SetCounterSettings(1, 0, 0, 1, 0)
 			},
 			true,
 		},
 		{
-			"SetCounterSettings(1, 0, 1)",
-			func(s api.CyclicCounterService) (*api.OKResult, *api.Error) {
-				return s.SetCounterSettings(1, 0, 1)
+			"SetCounterSettings(1, 1, 0, 1, 0)",
+			func(s api.CyclicCounterService) (*api.SettingsResult, *api.Error) {
+				return s.SetCounterSettings(1, 1, 0, 1, 0)
 			},
 			true,
 		},
 		{
 			// increment is wider than range
-			"SetCounterSettings(2, 0, 1)",
-			func(s api.CyclicCounterService) (*api.OKResult, *api.Error) {
-				return s.SetCounterSettings(2, 0, 1)
+			"SetCounterSettings(1, 2, 0, 1, 0)",
+			func(s api.CyclicCounterService) (*api.SettingsResult, *api.Error) {
+				return s.SetCounterSettings(1, 2, 0, 1, 0)
 			},
 			false,
 		},
 		{
 			// invalid lower-upper range [2:0]
-			"SetCounterSettings(2, 2, 0)",
-			func(s api.CyclicCounterService) (*api.OKResult, *api.Error) {
-				return s.SetCounterSettings(2, 2, 0)
+			"SetCounterSettings(1, 2, 2, 0, 0)",
+			func(s api.CyclicCounterService) (*api.SettingsResult, *api.Error) {
+				return s.SetCounterSettings(1, 2, 2, 0, 0)
+			},
+			false,
+		},
+		{
+			// invalid counter ID
+			"SetCounterSettings(0, 1, 0, 1, 0)",
+			func(s api.CyclicCounterService) (*api.SettingsResult, *api.Error) {
+				return s.SetCounterSettings(0, 1, 0, 1, 0)
 			},
 			false,
 		},
 	}
 
-	good, err := NewCyclicCounterService(1, &repository{})
+	good, err := NewCyclicCounterService(&repository{})
 	if err != nil {
 		// duplicates cases in TestServiceBuilder
 		t.Errorf("Unexpected error when building service with non-failed repository: %+v", err)
 	}
-	faulty, err := NewCyclicCounterService(1, &repository{failSetSettings: true})
+	faulty, err := NewCyclicCounterService(&repository{failSetSettings: true})
 	if err != nil {
 		// duplicates cases in TestServiceBuilder
 		t.Errorf("Unexpected error when building service with faulty repository: %+v", err)
@@ -322,3 +533,515 @@ func TestService_SetSettings(t *testing.T) {
 		}
 	}
 }
+
+func TestService_GetSettings(t *testing.T) {
+	service, err := NewCyclicCounterService(&repository{}) // good working repo
+	if err != nil {
+		// duplicates cases in TestServiceBuilder
+		t.Errorf("Unexpected error when building service with non-failed repository: %+v", err)
+	}
+	result, apiErr := service.GetCounterSettings(1)
+	if apiErr != nil {
+		t.Errorf("GetCounterSettings(): unexpected API error %q", apiErr.ExposeError())
+	}
+	defaults := DefaultSettings()
+	expected := api.CounterSettings{
+		Increment: defaults.Increment,
+		Lower:     defaults.Lower,
+		Upper:     defaults.Upper,
+		StartFrom: defaults.StartFrom,
+		Overflow:  api.WrapOverflow,
+	}
+	if result == nil || *result != expected {
+		t.Errorf("GetCounterSettings(): expected %+v, got %+v", expected, result)
+	}
+
+	service, err = NewCyclicCounterService(&repository{failGetSettings: true})
+	if err != nil {
+		// duplicates cases in TestServiceBuilder
+		t.Errorf("Unexpected error when building service: %+v", err)
+	}
+	if result, apiErr := service.GetCounterSettings(1); result != nil || apiErr == nil || !apiErr.IsInternal() {
+		t.Errorf("GetCounterSettings(): expected internal API error, got %+v", result)
+	}
+}
+
+func TestService_UpdateSettings(t *testing.T) {
+	cases := []struct {
+		counterID      int
+		settings       *api.CounterSettings
+		valueMode      string
+		mustSuccessful bool
+		expectedMode   ValueMode
+	}{
+		{1, &api.CounterSettings{Increment: 1, Lower: 0, Upper: 10, StartFrom: 5}, "", true, PreserveValue},
+		{1, &api.CounterSettings{Increment: 1, Lower: 0, Upper: 10, StartFrom: 5}, api.PreserveValue, true, PreserveValue},
+		{1, &api.CounterSettings{Increment: 1, Lower: 0, Upper: 10, StartFrom: 5}, api.ClampValue, true, ClampValue},
+		{1, &api.CounterSettings{Increment: 1, Lower: -5, Upper: 10, StartFrom: -5}, api.ResetValue, true, ResetValue},
+		{1, &api.CounterSettings{Increment: 1, Lower: 0, Upper: 10, StartFrom: 5}, "unknown", false, 0},
+		{1, &api.CounterSettings{Increment: 1, Lower: 0, Upper: 10, Overflow: api.WrapOverflow}, "", true, PreserveValue},
+		{1, &api.CounterSettings{Increment: 1, Lower: 0, Upper: 10, Overflow: api.SaturateOverflow}, "", true, PreserveValue},
+		{1, &api.CounterSettings{Increment: 1, Lower: 0, Upper: 10, Overflow: api.FailOverflow}, "", true, PreserveValue},
+		{1, &api.CounterSettings{Increment: 1, Lower: 0, Upper: 10, Overflow: "unknown"}, "", false, 0},
+		{1, &api.CounterSettings{Increment: 1, Lower: 0, Upper: 10, StartFrom: 11}, "", false, 0},
+		{1, &api.CounterSettings{Increment: 1, Lower: 10, Upper: 0, StartFrom: 5}, "", false, 0},
+		{1, nil, "", false, 0},
+		{0, &api.CounterSettings{Increment: 1, Lower: 0, Upper: 10, StartFrom: 5}, "", false, 0},
+	}
+
+	for _, c := range cases {
+		repo := &repository{}
+		service, err := NewCyclicCounterService(repo)
+		if err != nil {
+			// duplicates cases in TestServiceBuilder
+			t.Errorf("Unexpected error when building service with non-failed repository: %+v", err)
+		}
+		result, apiErr := service.UpdateCounterSettings(c.counterID, c.settings, c.valueMode, 0)
+		if !c.mustSuccessful {
+			if result != nil || apiErr == nil || apiErr.IsInternal() {
+				t.Errorf("UpdateCounterSettings(%d, %+v, %q): expected client API error, got %+v", c.counterID, c.settings, c.valueMode, result)
+			}
+			continue
+		}
+		if result == nil || apiErr != nil {
+			t.Errorf("UpdateCounterSettings(%d, %+v, %q): unexpected API error %v", c.counterID, c.settings, c.valueMode, apiErr)
+		}
+		if repo.mode != c.expectedMode {
+			t.Errorf("UpdateCounterSettings(%d, %+v, %q): expected mode %d, got %d", c.counterID, c.settings, c.valueMode, c.expectedMode, repo.mode)
+		}
+	}
+
+	service, err := NewCyclicCounterService(&repository{failSetSettings: true})
+	if err != nil {
+		// duplicates cases in TestServiceBuilder
+		t.Errorf("Unexpected error when building service: %+v", err)
+	}
+	settings := &api.CounterSettings{Increment: 1, Lower: 0, Upper: 10, StartFrom: 5}
+	if result, apiErr := service.UpdateCounterSettings(1, settings, "", 0); result != nil || apiErr == nil || !apiErr.IsInternal() {
+		t.Errorf("UpdateCounterSettings(): expected internal API error, got %+v", result)
+	}
+}
+
+func TestService_SettingsVersion(t *testing.T) {
+	service, err := NewCyclicCounterService(&repository{version: 1})
+	if err != nil {
+		// duplicates cases in TestServiceBuilder
+		t.Errorf("Unexpected error when building service with non-failed repository: %+v", err)
+	}
+	if result, apiErr := service.GetCounterSettings(1); apiErr != nil || result.Version != 1 {
+		t.Errorf("GetCounterSettings(): expected version 1, got %+v (%v)", result, apiErr)
+	}
+	settings := &api.CounterSettings{Increment: 1, Lower: 0, Upper: 10, StartFrom: 5}
+	if result, apiErr := service.UpdateCounterSettings(1, settings, "", 1); apiErr != nil || !result.OK || result.Version != 2 {
+		t.Errorf("UpdateCounterSettings(..., 1): expected version 2, got %+v (%v)", result, apiErr)
+	}
+	result, apiErr := service.UpdateCounterSettings(1, settings, "", 1)
+	if result != nil || apiErr == nil || apiErr.IsInternal() || apiErr.Code != api.VersionMismatchCode {
+		t.Errorf("UpdateCounterSettings(..., 1): expected version mismatch, got %+v (%v)", result, apiErr)
+	}
+	if result, apiErr := service.SetCounterSettings(1, 1, 0, 10, 0); apiErr != nil || result.Version != 3 {
+		t.Errorf("SetCounterSettings(..., 0): expected version 3, got %+v (%v)", result, apiErr)
+	}
+	result, apiErr = service.SetCounterSettings(1, 1, 0, 10, 2)
+	if result != nil || apiErr == nil || apiErr.Code != api.VersionMismatchCode {
+		t.Errorf("SetCounterSettings(..., 2): expected version mismatch, got %+v (%v)", result, apiErr)
+	}
+}
+
+func TestService_SetSettingsKeepsStored(t *testing.T) {
+	repo := &repository{}
+	service, err := NewCyclicCounterService(repo)
+	if err != nil {
+		// duplicates cases in TestServiceBuilder
+		t.Errorf("Unexpected error when building service with non-failed repository: %+v", err)
+	}
+	settings := &api.CounterSettings{Increment: 1, Lower: 0, Upper: 10, StartFrom: 5, Overflow: api.FailOverflow}
+	if _, apiErr := service.UpdateCounterSettings(1, settings, "", 0); apiErr != nil {
+		t.Fatalf("UpdateCounterSettings(): unexpected API error %v", apiErr)
+	}
+	if result, apiErr := service.SetCounterSettings(1, 2, 0, 100, 0); apiErr != nil || result.Version != 2 {
+		t.Fatalf("SetCounterSettings(): expected version 2, got %+v (%v)", result, apiErr)
+	}
+	result, apiErr := service.GetCounterSettings(1)
+	if apiErr != nil || result.Overflow != api.FailOverflow || result.Increment != 2 || result.Upper != 100 {
+		t.Errorf("SetCounterSettings() must keep overflow policy, got %+v (%v)", result, apiErr)
+	}
+	if result != nil && result.StartFrom != 5 {
+		t.Errorf("SetCounterSettings() must keep start value, got %+v", result)
+	}
+
+	t.Log("Case: start value is out of the new range")
+	if _, apiErr := service.SetCounterSettings(1, -1, 0, 3, 0); apiErr != nil {
+		t.Fatalf("SetCounterSettings(): unexpected API error %v", apiErr)
+	}
+	if result, apiErr := service.GetCounterSettings(1); apiErr != nil || result.StartFrom != 3 {
+		t.Errorf("SetCounterSettings(): expected default start 3, got %+v (%v)", result, apiErr)
+	}
+}
+
+func TestService_InvalidCounterID(t *testing.T) {
+	service, err := NewCyclicCounterService(&repository{})
+	if err != nil {
+		// duplicates cases in TestServiceBuilder
+		t.Errorf("Unexpected error when building service with non-failed repository: %+v", err)
+	}
+	for _, id := range []int{-1, 0} {
+		if result, apiErr := service.GetCounterValue(id); result != nil || apiErr == nil {
+			t.Errorf("GetCounterValue(%d): expected API error, got %+v", id, result)
+		} else if apiErr.IsInternal() {
+			t.Errorf("GetCounterValue(%d): unexpected internal error %q", id, apiErr.ExposeError())
+		}
+		if result, apiErr := service.IncreaseCounter(id); result != nil || apiErr == nil {
+			t.Errorf("IncreaseCounter(%d): expected API error, got %+v", id, result)
+		} else if apiErr.IsInternal() {
+			t.Errorf("IncreaseCounter(%d): unexpected internal error %q", id, apiErr.ExposeError())
+		}
+		if result, apiErr := service.DecreaseCounter(id); result != nil || apiErr == nil {
+			t.Errorf("DecreaseCounter(%d): expected API error, got %+v", id, result)
+		} else if apiErr.IsInternal() {
+			t.Errorf("DecreaseCounter(%d): unexpected internal error %q", id, apiErr.ExposeError())
+		}
+		if result, apiErr := service.ResetCounter(id); result != nil || apiErr == nil {
+			t.Errorf("ResetCounter(%d): expected API error, got %+v", id, result)
+		} else if apiErr.IsInternal() {
+			t.Errorf("ResetCounter(%d): unexpected internal error %q", id, apiErr.ExposeError())
+		}
+	}
+}
+
+func TestService_EnsureSettingsLazily(t *testing.T) {
+	repo := &repository{}
+	service, err := NewCyclicCounterService(repo)
+	if err != nil {
+		// duplicates cases in TestServiceBuilder
+		t.Errorf("Unexpected error when building service with non-failed repository: %+v", err)
+	}
+	if len(repo.ensured) != 0 {
+		t.Errorf("Unexpected settings ensured on service start: %v", repo.ensured)
+	}
+	service.GetCounterValue(1)
+	service.IncreaseCounter(1)
+	service.IncreaseCounter(2)
+	service.SetCounterSettings(3, 1, 0, 1, 0)
+	service.GetCounterValue(3)
+	service.GetCounterValue(2)
+	if len(repo.ensured) != 2 || repo.ensured[0] != 1 || repo.ensured[1] != 2 {
+		t.Errorf("Expected settings ensured once for counters [1 2], got: %v", repo.ensured)
+	}
+
+	service, err = NewCyclicCounterService(&repository{failEnsureSettings: true})
+	if err != nil {
+		// duplicates cases in TestServiceBuilder
+		t.Errorf("Unexpected error when building service: %+v", err)
+	}
+	if result, apiErr := service.IncreaseCounter(1); result != nil || !apiErr.IsInternal() {
+		t.Errorf("IncreaseCounter(): expected internal API error, got %+v", result)
+	}
+}
+
+func TestService_WrapHook(t *testing.T) {
+	if _, err := NewCyclicCounterService(&repository{}, WithWrapHook(nil)); err == nil {
+		t.Errorf("NewCyclicCounterService(): expected error for nil wrap hook")
+	}
+
+	type event struct {
+		counterID int
+		state     State
+	}
+	var events []event
+	hook := func(counterID int, state State) {
+		events = append(events, event{counterID, state})
+	}
+
+	service, err := NewCyclicCounterService(&repository{}, WithWrapHook(hook))
+	if err != nil {
+		t.Fatalf("NewCyclicCounterService(): unexpected error %q", err)
+	}
+	if intResult, apiErr := service.IncreaseCounter(1); apiErr != nil || intResult.Wrapped {
+		t.Errorf("IncreaseCounter(): unexpected result %+v, %+v", intResult, apiErr)
+	}
+	if len(events) != 0 {
+		t.Errorf("Hook: unexpected events %+v", events)
+	}
+
+	service, err = NewCyclicCounterService(&repository{wraps: true}, WithWrapHook(hook))
+	if err != nil {
+		t.Fatalf("NewCyclicCounterService(): unexpected error %q", err)
+	}
+	intResult, apiErr := service.IncreaseCounter(1)
+	if apiErr != nil || !intResult.Wrapped || intResult.Cycle != 1 {
+		t.Errorf("IncreaseCounter(): unexpected result %+v, %+v", intResult, apiErr)
+	}
+	intResult, apiErr = service.DecreaseCounter(2)
+	if apiErr != nil || !intResult.Wrapped || intResult.Cycle != -1 {
+		t.Errorf("DecreaseCounter(): unexpected result %+v, %+v", intResult, apiErr)
+	}
+	blockResult, apiErr := service.ReserveCounterBlock(3, 10)
+	if apiErr != nil || !blockResult.Wrapped || blockResult.Cycle != 1 {
+		t.Errorf("ReserveCounterBlock(): unexpected result %+v, %+v", blockResult, apiErr)
+	}
+	if _, apiErr = service.ResetCounter(4); apiErr != nil {
+		t.Errorf("ResetCounter(): unexpected API error %q", apiErr.ExposeError())
+	}
+	expected := []event{
+		{1, State{Cycle: 1, Wraps: 1}},
+		{2, State{Cycle: -1, Wraps: -1}},
+		{3, State{Cycle: 1, Wraps: 1}},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("Hook: expected events %+v, got %+v", expected, events)
+	}
+	for i, e := range expected {
+		if events[i] != e {
+			t.Errorf("Hook: expected event %+v, got %+v", e, events[i])
+		}
+	}
+}
+
+type auditLog struct {
+	records    []AuditRecord
+	failAppend bool
+	failList   bool
+}
+
+func (l *auditLog) Append(record *AuditRecord) error {
+	if l.failAppend {
+		return errors.New("auditLog.Append() failed")
+	}
+	record.ID = int64(len(l.records) + 1)
+	l.records = append(l.records, *record)
+	return nil
+}
+
+func (l *auditLog) List(counterID int, after int64, limit int) ([]AuditRecord, error) {
+	if l.failList {
+		return nil, errors.New("auditLog.List() failed")
+	}
+	records := []AuditRecord{}
+	for _, r := range l.records {
+		if r.CounterID == counterID && r.ID > after && len(records) < limit {
+			records = append(records, r)
+		}
+	}
+	return records, nil
+}
+
+func TestService_Audit(t *testing.T) {
+	if _, err := NewCyclicCounterService(&repository{}, WithAuditLog(nil, nil)); err == nil {
+		t.Errorf("NewCyclicCounterService(): expected error for nil audit log")
+	}
+
+	service, err := NewCyclicCounterService(&repository{})
+	if err != nil {
+		t.Fatalf("NewCyclicCounterService(): unexpected error %q", err)
+	}
+	if _, apiErr := service.GetCounterAudit(1, 0, 10); apiErr == nil || apiErr.IsInternal() {
+		t.Errorf("GetCounterAudit(): expected client API error for disabled audit, got %+v", apiErr)
+	}
+
+	log := &auditLog{}
+	service, err = NewCyclicCounterService(&repository{}, WithAuditLog(log, nil))
+	if err != nil {
+		t.Fatalf("NewCyclicCounterService(): unexpected error %q", err)
+	}
+	caller := api.Caller{RequestID: "request", Client: "client"}
+	service.WithCaller(caller).IncreaseCounter(1)
+	service.DecreaseCounter(1)
+	service.ResetCounter(1)
+	service.ReserveCounterBlock(1, 10)
+	service.WithCaller(caller).SetCounterSettings(1, 1, 0, 10, 0)
+	service.UpdateCounterSettings(2, &api.CounterSettings{Increment: 1, Upper: 10}, api.ResetValue, 0)
+	service.GetCounterValue(1)
+
+	expected := []AuditRecord{
+		{ID: 1, CounterID: 1, Operation: IncreaseOperation, RequestID: "request", Client: "client"},
+		{ID: 2, CounterID: 1, Operation: DecreaseOperation},
+		{ID: 3, CounterID: 1, Operation: ResetOperation},
+		{ID: 4, CounterID: 1, Operation: ReserveOperation},
+		{ID: 5, CounterID: 1, Operation: SettingsOperation, RequestID: "request", Client: "client"},
+		{ID: 6, CounterID: 2, Operation: SettingsOperation},
+	}
+	if len(log.records) != len(expected) {
+		t.Fatalf("Expected audit records %+v, got %+v", expected, log.records)
+	}
+	for i, r := range log.records {
+		e := expected[i]
+		if r.ID != e.ID || r.CounterID != e.CounterID || r.Operation != e.Operation ||
+			r.RequestID != e.RequestID || r.Client != e.Client || r.Time.IsZero() {
+			t.Errorf("Audit record #%d: expected %+v, got %+v", i, e, r)
+		}
+		if (r.Operation == SettingsOperation) != (r.NewSettings != nil) {
+			t.Errorf("Audit record #%d: unexpected settings %+v", i, r)
+		}
+	}
+
+	page, apiErr := service.GetCounterAudit(1, 0, 3)
+	if apiErr != nil || len(page.Records) != 3 || page.Next != 3 {
+		t.Fatalf("GetCounterAudit(): unexpected first page %+v, %+v", page, apiErr)
+	}
+	page, apiErr = service.GetCounterAudit(1, page.Next, 3)
+	if apiErr != nil || len(page.Records) != 2 || page.Next != 0 {
+		t.Errorf("GetCounterAudit(): unexpected last page %+v, %+v", page, apiErr)
+	}
+	if page.Records[1].Operation != SettingsOperation || page.Records[1].NewSettings == nil {
+		t.Errorf("GetCounterAudit(): unexpected settings record %+v", page.Records[1])
+	}
+	for _, c := range []struct {
+		after int64
+		limit int
+	}{{0, 0}, {0, MaxAuditPageSize + 1}, {-1, 10}} {
+		if _, apiErr := service.GetCounterAudit(1, c.after, c.limit); apiErr == nil || apiErr.IsInternal() {
+			t.Errorf("GetCounterAudit(1, %d, %d): expected client API error, got %+v", c.after, c.limit, apiErr)
+		}
+	}
+
+	var failures int
+	service, _ = NewCyclicCounterService(
+		&repository{},
+		WithAuditLog(&auditLog{failAppend: true, failList: true}, func(_ *AuditRecord, _ error) { failures++ }),
+	)
+	if _, apiErr := service.IncreaseCounter(1); apiErr != nil {
+		t.Errorf("IncreaseCounter(): audit failure must not fail the operation, got %+v", apiErr)
+	}
+	if failures != 1 {
+		t.Errorf("Expected 1 audit failure, got %d", failures)
+	}
+	if _, apiErr := service.GetCounterAudit(1, 0, 10); !apiErr.IsInternal() {
+		t.Errorf("GetCounterAudit(): expected internal API error, got %+v", apiErr)
+	}
+}
+
+type idempotentRepository struct {
+	*repository
+	keys map[string]State
+	ttl  time.Duration // the last TTL passed into IncreaseOnce
+}
+
+func (r *idempotentRepository) IncreaseOnce(counterID int, key string, ttl time.Duration) (State, bool, error) {
+	r.ttl = ttl
+	if state, ok := r.keys[key]; ok {
+		return state, true, nil
+	}
+	state, err := r.Increase(counterID)
+	if err != nil {
+		return state, false, err
+	}
+	r.keys[key] = state
+	return state, false, nil
+}
+
+func TestService_IncreaseOnce(t *testing.T) {
+	if _, err := NewCyclicCounterService(&repository{}, WithIdempotencyTTL(0)); err == nil {
+		t.Errorf("NewCyclicCounterService(): expected error for zero idempotency TTL")
+	}
+
+	service, _ := NewCyclicCounterService(&repository{})
+	if _, apiErr := service.IncreaseCounterOnce(1, "key"); apiErr == nil || apiErr.IsInternal() {
+		t.Errorf("IncreaseCounterOnce(): expected client API error for unsupported repository, got %+v", apiErr)
+	}
+
+	repo := &idempotentRepository{repository: &repository{wraps: true}, keys: map[string]State{}}
+	log := &auditLog{}
+	wraps := 0
+	service, err := NewCyclicCounterService(
+		repo,
+		WithIdempotencyTTL(time.Hour),
+		WithAuditLog(log, nil),
+		WithWrapHook(func(_ int, _ State) { wraps++ }),
+	)
+	if err != nil {
+		t.Fatalf("NewCyclicCounterService(): unexpected error %q", err)
+	}
+	for _, key := range []string{"", strings.Repeat("k", MaxIdempotencyKeyLength+1)} {
+		if _, apiErr := service.IncreaseCounterOnce(1, key); apiErr == nil || apiErr.IsInternal() {
+			t.Errorf("IncreaseCounterOnce(1, %q): expected client API error, got %+v", key, apiErr)
+		}
+	}
+	result, apiErr := service.IncreaseCounterOnce(1, "key")
+	if apiErr != nil || result.Replayed || !result.Wrapped {
+		t.Errorf("IncreaseCounterOnce(): unexpected result %+v, %+v", result, apiErr)
+	}
+	result, apiErr = service.IncreaseCounterOnce(1, "key")
+	if apiErr != nil || !result.Replayed || !result.Wrapped {
+		t.Errorf("IncreaseCounterOnce(): expected replayed result, got %+v, %+v", result, apiErr)
+	}
+	if repo.ttl != time.Hour {
+		t.Errorf("IncreaseCounterOnce(): expected TTL %s, got %s", time.Hour, repo.ttl)
+	}
+	if len(log.records) != 1 || wraps != 1 {
+		t.Errorf("Replayed increase must not be audited and notified, got %d records and %d wraps", len(log.records), wraps)
+	}
+
+	repo.failIncrease = true
+	if _, apiErr := service.IncreaseCounterOnce(1, "another"); !apiErr.IsInternal() {
+		t.Errorf("IncreaseCounterOnce(): expected internal API error, got %+v", apiErr)
+	}
+}
+
+func TestService_Events(t *testing.T) {
+	if _, err := NewCyclicCounterService(&repository{}, WithEventBus(nil)); err == nil {
+		t.Errorf("NewCyclicCounterService(): expected error for nil event bus")
+	}
+
+	bus := NewEventBus()
+	events, cancel := bus.SubscribeCounterEvents(0, 100)
+	defer cancel()
+	service, err := NewCyclicCounterService(&repository{wraps: true}, WithEventBus(bus))
+	if err != nil {
+		t.Fatalf("NewCyclicCounterService(): unexpected error %q", err)
+	}
+	if _, apiErr := service.GetCounterValue(1); apiErr != nil {
+		t.Errorf("GetCounterValue(): unexpected API error %q", apiErr.ExposeError())
+	}
+	if _, apiErr := service.IncreaseCounter(1); apiErr != nil {
+		t.Errorf("IncreaseCounter(): unexpected API error %q", apiErr.ExposeError())
+	}
+	if _, apiErr := service.DecreaseCounter(2); apiErr != nil {
+		t.Errorf("DecreaseCounter(): unexpected API error %q", apiErr.ExposeError())
+	}
+	if _, apiErr := service.ResetCounter(3); apiErr != nil {
+		t.Errorf("ResetCounter(): unexpected API error %q", apiErr.ExposeError())
+	}
+	if _, apiErr := service.ReserveCounterBlock(4, 10); apiErr != nil {
+		t.Errorf("ReserveCounterBlock(): unexpected API error %q", apiErr.ExposeError())
+	}
+	if _, apiErr := service.SetCounterSettings(5, 1, 0, 10, 0); apiErr != nil {
+		t.Errorf("SetCounterSettings(): unexpected API error %q", apiErr.ExposeError())
+	}
+	if _, apiErr := service.IncreaseCounter(0); apiErr == nil {
+		t.Errorf("IncreaseCounter(): expected API error for invalid counter ID")
+	}
+
+	expected := []api.CounterEvent{
+		{Type: api.ValueEvent, CounterID: 1, Operation: IncreaseOperation, Cycle: 1},
+		{Type: api.WrapEvent, CounterID: 1, Operation: IncreaseOperation, Cycle: 1, Wraps: 1},
+		{Type: api.ValueEvent, CounterID: 2, Operation: DecreaseOperation, Cycle: -1},
+		{Type: api.WrapEvent, CounterID: 2, Operation: DecreaseOperation, Cycle: -1, Wraps: -1},
+		{Type: api.ValueEvent, CounterID: 3, Operation: ResetOperation},
+		{Type: api.ValueEvent, CounterID: 4, Operation: ReserveOperation, Cycle: 1},
+		{Type: api.WrapEvent, CounterID: 4, Operation: ReserveOperation, Cycle: 1, Wraps: 1},
+		{Type: api.SettingsEvent, CounterID: 5, Operation: SettingsOperation},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %d events, got %d", len(expected), len(events))
+	}
+	for i, e := range expected {
+		event := <-events
+		if event.Time.IsZero() {
+			t.Errorf("Event %+v: time is not set", event)
+		}
+		// events are numbered by the bus in order of publishing
+		e.ID = int64(i + 1)
+		settings := event.Settings
+		event.Time, event.Settings = time.Time{}, nil
+		if event != e {
+			t.Errorf("Expected event %+v, got %+v", e, event)
+		}
+		if (e.Type == api.SettingsEvent) != (settings != nil) {
+			t.Errorf("Event %+v: unexpected settings %+v", e, settings)
+		}
+		if settings != nil && (settings.Upper != 10 || settings.Version != 1) {
+			t.Errorf("Event %+v: unexpected settings %+v", e, settings)
+		}
+	}
+}
@@ -6,16 +6,39 @@ import (
 	"math"
 )
 
+// ErrOverflow - counter can not be changed, because it exceeds the boundary and overflow policy forbids it.
+var ErrOverflow = errors.New("counter overflow")
+
+// ErrNotFound - counter does not exist, so it has no settings yet.
+var ErrNotFound = errors.New("counter not found")
+
+// ErrVersionMismatch - counter settings can not be changed, because they were changed since expected version.
+var ErrVersionMismatch = errors.New("counter settings version mismatch")
+
+// OverflowPolicy - defines what happens with the counter when it exceeds the boundary of its range.
+type OverflowPolicy int
+
+const (
+	// WrapOnOverflow - counter wraps to the opposite boundary and starts new cycle
+	WrapOnOverflow OverflowPolicy = iota
+	// SaturateOnOverflow - counter stops at the exceeded boundary
+	SaturateOnOverflow
+	// FailOnOverflow - counter is not changed, ErrOverflow is returned instead
+	FailOnOverflow
+)
+
 // Settings - common settings of counter.
 type Settings struct {
-	// StartFrom - default first counter value
-	StartFrom int
-	// Increment - counter increment, can only to be an positive int
-	Increment int
+	// StartFrom - default first counter value, counter also returns to it on reset
+	StartFrom int64
+	// Increment - counter increment, negative increment makes descending counter
+	Increment int64
 	// Lower - lower boundary of counter range
-	Lower int
+	Lower int64
 	// Upper - upper boundary of counter range
-	Upper int
+	Upper int64
+	// Overflow - overflow policy, the counter wraps by default
+	Overflow OverflowPolicy
 }
 
 // verify - validates settings at once
@@ -23,13 +46,13 @@ func (s *Settings) verify() error {
 	if s == nil {
 		return errors.New("counter.Settings: unable to verify nil settings")
 	}
-	if s.Increment < 0 {
-		return fmt.Errorf("counter.Settings: negative increment (%d)", s.Increment)
-	}
 	// Hmm... Zero increment will pause the counter
 	// if s.Increment == 0 {
 	// 	return errors.New("counter.Settings: useless zero increment")
 	// }
+	if s.Overflow < WrapOnOverflow || s.Overflow > FailOnOverflow {
+		return fmt.Errorf("counter.Settings: unknown overflow policy (%d)", s.Overflow)
+	}
 	if s.Lower > s.Upper {
 		return fmt.Errorf("counter.Settings: invalid counter range [%d:%d]", s.Lower, s.Upper)
 	}
@@ -41,7 +64,7 @@ func (s *Settings) verify() error {
 			s.Upper,
 		)
 	}
-	if float64(s.Increment) > math.Abs(float64(s.Upper-s.Lower)) {
+	if magnitude(s.Increment) > s.span() {
 		return fmt.Errorf(
 			"counter.Settings: increment (%d) is wider than counter range [%d:%d]",
 			s.Increment,
@@ -52,12 +75,204 @@ func (s *Settings) verify() error {
 	return nil
 }
 
+// Next - calculates counter value which follows the given one.
+// The value is increased by increment and wraps to lower boundary when upper boundary is exceeded.
+// Descending counter (with negative increment) wraps to upper boundary when lower boundary is exceeded.
+// Other overflow policies make the counter to stop at exceeded boundary or to fail with ErrOverflow.
+func (s *Settings) Next(value int64) (int64, error) {
+	if s.exceeds(value) {
+		if s.Increment >= 0 {
+			return s.overflow(s.Lower, s.Upper)
+		}
+		return s.overflow(s.Upper, s.Lower)
+	}
+	return value + s.Increment, nil
+}
+
+// Previous - calculates counter value which precedes the given one.
+// The value is decreased by increment and wraps from lower boundary to upper boundary.
+// Descending counter (with negative increment) wraps from upper boundary to lower boundary.
+// So decrease exactly reverts increase only when counter range is divisible by increment,
+// otherwise the wrapped value is the boundary instead of the last value of the previous cycle.
+// Overflow policy is applied when the counter exceeds the boundary from which it begins its cycle.
+func (s *Settings) Previous(value int64) (int64, error) {
+	if s.Increment == 0 {
+		return value, nil
+	}
+	if s.recedes(value) {
+		if s.Increment > 0 {
+			return s.overflow(s.Upper, s.Lower)
+		}
+		return s.overflow(s.Lower, s.Upper)
+	}
+	return value - s.Increment, nil
+}
+
+// Wraps - reports whether the counter with given value wraps on the next increase and starts new cycle.
+func (s *Settings) Wraps(value int64) bool {
+	return s.Overflow == WrapOnOverflow && s.exceeds(value)
+}
+
+// Unwraps - reports whether the counter with given value wraps on decrease and returns to the previous cycle.
+func (s *Settings) Unwraps(value int64) bool {
+	return s.Overflow == WrapOnOverflow && s.Increment != 0 && s.recedes(value)
+}
+
+// exceeds - reports whether increase of given value exceeds the boundary at which the counter ends its cycle.
+// The boundary is shifted by increment instead of the value, so comparison never overflows int64.
+func (s *Settings) exceeds(value int64) bool {
+	if s.Increment >= 0 {
+		return value > s.Upper-s.Increment
+	}
+	return value < s.Lower-s.Increment
+}
+
+// recedes - reports whether decrease of given value exceeds the boundary from which the counter begins its cycle.
+func (s *Settings) recedes(value int64) bool {
+	if s.Increment > 0 {
+		return value < s.Lower+s.Increment
+	}
+	return value > s.Upper+s.Increment
+}
+
+// span - returns width of counter range, it does not overflow for any int64 boundaries.
+func (s *Settings) span() uint64 {
+	return uint64(s.Upper) - uint64(s.Lower)
+}
+
+// magnitude - returns absolute value of v, it does not overflow for math.MinInt64.
+func magnitude(v int64) uint64 {
+	if v < 0 {
+		return uint64(-v)
+	}
+	return uint64(v)
+}
+
+// overflow - returns counter value after the boundary was exceeded according to overflow policy.
+func (s *Settings) overflow(wrapped, boundary int64) (int64, error) {
+	switch s.Overflow {
+	case SaturateOnOverflow:
+		return boundary, nil
+	case FailOnOverflow:
+		return 0, ErrOverflow
+	default:
+		return wrapped, nil
+	}
+}
+
+// Start - returns the value to which the counter is reset.
+// It is StartFrom, when it is within counter range, otherwise it is the boundary from which the counter
+// begins its cycle: lower for ascending counter and upper for descending one.
+func (s *Settings) Start() int64 {
+	if s.StartFrom < s.Lower || s.StartFrom > s.Upper {
+		if s.Increment < 0 {
+			return s.Upper
+		}
+		return s.Lower
+	}
+	return s.StartFrom
+}
+
+// ValueMode - defines how current counter value is changed when new settings are applied.
+type ValueMode int
+
+const (
+	// PreserveValue - keep current counter value as is, even if it is out of the new range
+	PreserveValue ValueMode = iota
+	// ClampValue - move current counter value to the nearest boundary of the new range if it is out of the range
+	ClampValue
+	// ResetValue - set counter value to the new start value
+	ResetValue
+)
+
+// Adjust - calculates counter value after the settings were applied to the counter with given value.
+func (s *Settings) Adjust(value int64, mode ValueMode) int64 {
+	switch mode {
+	case ClampValue:
+		if value < s.Lower {
+			return s.Lower
+		}
+		if value > s.Upper {
+			return s.Upper
+		}
+		return value
+	case ResetValue:
+		return s.Start()
+	default:
+		return value
+	}
+}
+
+// Range - arithmetic progression of counter values reserved at once.
+type Range struct {
+	// First - the first value of range
+	First int64
+	// Last - the last value of range
+	Last int64
+	// Step - difference between neighbour values, it is equal to increment and negative for descending counter,
+	// it is zero for repeated value of saturated counter
+	Step int64
+	// Count - number of values in range
+	Count int
+}
+
+// Reserve - calculates `size` counter values which follow the given one.
+// Values are returned as ranges, new range is started every time the counter wraps.
+// The last value of the last range is the new counter value.
+// Block is reserved entirely or is not reserved at all, when the counter fails on overflow.
+func (s *Settings) Reserve(value int64, size int) ([]Range, error) {
+	ranges := []Range{}
+	for size > 0 {
+		first, err := s.Next(value)
+		if err != nil {
+			return nil, err
+		}
+		if s.Increment == 0 {
+			// paused counter repeats the same value
+			return append(ranges, Range{First: first, Last: first, Step: 0, Count: size}), nil
+		}
+		limit := s.Upper
+		if s.Increment < 0 {
+			limit = s.Lower
+		}
+		if first == limit && s.Overflow == SaturateOnOverflow {
+			// saturated counter repeats the boundary
+			return append(ranges, Range{First: first, Last: first, Step: 0, Count: size}), nil
+		}
+		// the first value never exceeds the limit, so the distance is not negative
+		distance := uint64(limit) - uint64(first)
+		if s.Increment < 0 {
+			distance = uint64(first) - uint64(limit)
+		}
+		count := size
+		if steps := distance / magnitude(s.Increment); steps < uint64(size-1) {
+			count = int(steps) + 1
+		}
+		value = first + int64(count-1)*s.Increment
+		ranges = append(ranges, Range{First: first, Last: value, Step: s.Increment, Count: count})
+		size -= count
+	}
+	return ranges, nil
+}
+
+// CountWraps - returns how many times the counter with given value wraps, when it passes reserved ranges.
+func (s *Settings) CountWraps(value int64, ranges []Range) int {
+	wraps := 0
+	for _, r := range ranges {
+		if s.Wraps(value) {
+			wraps++
+		}
+		value = r.Last
+	}
+	return wraps
+}
+
 // DefaultSettings - return default (initial) counter settings.
 func DefaultSettings() *Settings {
 	return &Settings{
 		StartFrom: 0,
 		Increment: 1,
 		Lower:     0,
-		Upper:     int(^uint32(0) >> 1), // max int32
+		Upper:     math.MaxInt64,
 	}
 }
@@ -1,6 +1,8 @@
 package counter
 
 import (
+	"math"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -12,7 +14,10 @@ func TestSettingsVerification(t *testing.T) {
 	}{
 		{nil, "unable to verify nil settings"},
 		{&Settings{}, ""},
-		{&Settings{Increment: -1}, "negative increment (-1)"},
+		{&Settings{Increment: -1}, "increment (-1) is wider than counter range [0:0]"},
+		{&Settings{Increment: -10, Upper: 9}, "increment (-10) is wider than counter range [0:9]"},
+		{&Settings{Increment: -10, Upper: 10}, ""},
+		{&Settings{Increment: -2, Lower: -1, Upper: 1}, ""},
 		{&Settings{Increment: 1}, "increment (1) is wider than counter range [0:0]"},
 		{&Settings{Increment: 10}, "increment (10) is wider than counter range [0:0]"},
 		{&Settings{Increment: 10, Upper: 9}, "increment (10) is wider than counter range [0:9]"},
@@ -25,6 +30,10 @@ func TestSettingsVerification(t *testing.T) {
 		{&Settings{StartFrom: 10}, "start value (10) is out of the range [0:0]"},
 		{&Settings{StartFrom: -10}, "start value (-10) is out of the range [0:0]"},
 		{&Settings{StartFrom: 1, Upper: 10}, ""},
+		{&Settings{Upper: 10, Overflow: SaturateOnOverflow}, ""},
+		{&Settings{Upper: 10, Overflow: FailOnOverflow}, ""},
+		{&Settings{Upper: 10, Overflow: -1}, "unknown overflow policy (-1)"},
+		{&Settings{Upper: 10, Overflow: 3}, "unknown overflow policy (3)"},
 	}
 
 	for _, c := range cases {
@@ -42,3 +51,359 @@ func TestSettingsVerification(t *testing.T) {
 		}
 	}
 }
+
+func TestSettingsNext(t *testing.T) {
+	cases := []struct {
+		s        *Settings
+		value    int64
+		expected int64
+	}{
+		{&Settings{Increment: 0, Lower: 0, Upper: 10}, 5, 5},
+		{&Settings{Increment: 1, Lower: 0, Upper: 10}, 5, 6},
+		{&Settings{Increment: 1, Lower: 0, Upper: 10}, 9, 10},
+		{&Settings{Increment: 1, Lower: 0, Upper: 10}, 10, 0},
+		{&Settings{Increment: 3, Lower: 0, Upper: 10}, 8, 0},
+		{&Settings{Increment: 3, Lower: -5, Upper: 5}, 3, -5},
+		{&Settings{Increment: 10, Lower: 0, Upper: 10}, 0, 10},
+		// descending counter
+		{&Settings{Increment: -1, Lower: 0, Upper: 10}, 5, 4},
+		{&Settings{Increment: -1, Lower: 0, Upper: 10}, 1, 0},
+		{&Settings{Increment: -1, Lower: 0, Upper: 10}, 0, 10},
+		{&Settings{Increment: -3, Lower: 0, Upper: 10}, 2, 10},
+		{&Settings{Increment: -3, Lower: -5, Upper: 5}, -3, 5},
+		{&Settings{Increment: -10, Lower: 0, Upper: 10}, 10, 0},
+		// inconsistent value, which is out of counter range
+		{&Settings{Increment: 0, Lower: 0, Upper: 10}, 20, 0},
+		{&Settings{Increment: -1, Lower: 0, Upper: 10}, 20, 19},
+	}
+
+	for _, c := range cases {
+		if actual, err := c.s.Next(c.value); err != nil || actual != c.expected {
+			t.Errorf("%+v.Next(%d): expected %d, got %d (%v)", *c.s, c.value, c.expected, actual, err)
+		}
+	}
+}
+
+func TestSettingsPrevious(t *testing.T) {
+	cases := []struct {
+		s        *Settings
+		value    int64
+		expected int64
+	}{
+		{&Settings{Increment: 0, Lower: 0, Upper: 10}, 5, 5},
+		{&Settings{Increment: 1, Lower: 0, Upper: 10}, 5, 4},
+		{&Settings{Increment: 1, Lower: 0, Upper: 10}, 1, 0},
+		{&Settings{Increment: 1, Lower: 0, Upper: 10}, 0, 10},
+		{&Settings{Increment: 5, Lower: 0, Upper: 10}, 0, 10},
+		// range is not divisible by increment, counter wraps to the upper boundary anyway
+		{&Settings{Increment: 3, Lower: 0, Upper: 10}, 0, 10},
+		{&Settings{Increment: 3, Lower: 0, Upper: 10}, 2, 10},
+		{&Settings{Increment: 3, Lower: -5, Upper: 5}, -5, 5},
+		{&Settings{Increment: 10, Lower: 0, Upper: 10}, 10, 0},
+		// descending counter
+		{&Settings{Increment: -1, Lower: 0, Upper: 10}, 5, 6},
+		{&Settings{Increment: -1, Lower: 0, Upper: 10}, 10, 0},
+		{&Settings{Increment: -3, Lower: 0, Upper: 10}, 10, 0},
+		{&Settings{Increment: -3, Lower: 0, Upper: 10}, 8, 0},
+		{&Settings{Increment: -3, Lower: -5, Upper: 5}, 5, -5},
+	}
+
+	for _, c := range cases {
+		if actual, err := c.s.Previous(c.value); err != nil || actual != c.expected {
+			t.Errorf("%+v.Previous(%d): expected %d, got %d (%v)", *c.s, c.value, c.expected, actual, err)
+		}
+	}
+
+	// decrease reverts increase when range is divisible by increment
+	for _, s := range []*Settings{
+		{StartFrom: 0, Increment: 1, Lower: 0, Upper: 10},
+		{StartFrom: 0, Increment: 5, Lower: 0, Upper: 10},
+		{StartFrom: -7, Increment: 7, Lower: -7, Upper: 7},
+		{StartFrom: 10, Increment: -1, Lower: 0, Upper: 10},
+		{StartFrom: 10, Increment: -5, Lower: 0, Upper: 10},
+		{StartFrom: 7, Increment: -7, Lower: -7, Upper: 7},
+	} {
+		for value := s.Start(); value >= s.Lower && value <= s.Upper; value += s.Increment {
+			next, _ := s.Next(value)
+			if actual, _ := s.Previous(next); actual != value {
+				t.Errorf("%+v.Previous(Next(%d)): expected %d, got %d", *s, value, value, actual)
+			}
+		}
+	}
+}
+
+func TestSettingsStart(t *testing.T) {
+	cases := []struct {
+		s        *Settings
+		expected int64
+	}{
+		{&Settings{StartFrom: 5, Lower: 0, Upper: 10}, 5},
+		{&Settings{StartFrom: 0, Lower: 0, Upper: 10}, 0},
+		{&Settings{StartFrom: 10, Lower: 0, Upper: 10}, 10},
+		{&Settings{StartFrom: 0, Lower: 3, Upper: 10}, 3},
+		{&Settings{StartFrom: 11, Lower: 3, Upper: 10}, 3},
+		{&Settings{StartFrom: 5, Increment: -1, Lower: 0, Upper: 10}, 5},
+		{&Settings{StartFrom: 11, Increment: -1, Lower: 3, Upper: 10}, 10},
+	}
+
+	for _, c := range cases {
+		if actual := c.s.Start(); actual != c.expected {
+			t.Errorf("%+v.Start(): expected %d, got %d", *c.s, c.expected, actual)
+		}
+	}
+}
+
+func TestSettingsAdjust(t *testing.T) {
+	s := &Settings{StartFrom: 5, Increment: 1, Lower: 3, Upper: 10}
+	cases := []struct {
+		value    int64
+		mode     ValueMode
+		expected int64
+	}{
+		{0, PreserveValue, 0},
+		{7, PreserveValue, 7},
+		{20, PreserveValue, 20},
+		{0, ClampValue, 3},
+		{7, ClampValue, 7},
+		{20, ClampValue, 10},
+		{0, ResetValue, 5},
+		{7, ResetValue, 5},
+		{20, ResetValue, 5},
+	}
+
+	for _, c := range cases {
+		if actual := s.Adjust(c.value, c.mode); actual != c.expected {
+			t.Errorf("Adjust(%d, %d): expected %d, got %d", c.value, c.mode, c.expected, actual)
+		}
+	}
+}
+
+func TestSettingsReserve(t *testing.T) {
+	cases := []struct {
+		s        *Settings
+		value    int64
+		size     int
+		expected []Range
+	}{
+		{&Settings{Increment: 1, Lower: 0, Upper: 10}, 5, 0, []Range{}},
+		{&Settings{Increment: 1, Lower: 0, Upper: 10}, 5, 1, []Range{{6, 6, 1, 1}}},
+		{&Settings{Increment: 1, Lower: 0, Upper: 10}, 5, 5, []Range{{6, 10, 1, 5}}},
+		{&Settings{Increment: 1, Lower: 0, Upper: 10}, 5, 6, []Range{{6, 10, 1, 5}, {0, 0, 1, 1}}},
+		{&Settings{Increment: 1, Lower: 0, Upper: 10}, 10, 3, []Range{{0, 2, 1, 3}}},
+		{
+			&Settings{Increment: 1, Lower: 0, Upper: 2},
+			1,
+			8,
+			[]Range{{2, 2, 1, 1}, {0, 2, 1, 3}, {0, 2, 1, 3}, {0, 0, 1, 1}},
+		},
+		{&Settings{Increment: 3, Lower: 0, Upper: 10}, 2, 4, []Range{{5, 8, 3, 2}, {0, 3, 3, 2}}},
+		{&Settings{Increment: 3, Lower: -5, Upper: 5}, -5, 3, []Range{{-2, 4, 3, 3}}},
+		{&Settings{Increment: 0, Lower: 0, Upper: 10}, 7, 4, []Range{{7, 7, 0, 4}}},
+		// inconsistent value, which is out of counter range
+		{&Settings{Increment: 2, Lower: 10, Upper: 20}, 0, 2, []Range{{2, 4, 2, 2}}},
+		{&Settings{Increment: 2, Lower: 10, Upper: 20}, 30, 2, []Range{{10, 12, 2, 2}}},
+		// descending counter
+		{&Settings{Increment: -1, Lower: 0, Upper: 10}, 5, 5, []Range{{4, 0, -1, 5}}},
+		{&Settings{Increment: -1, Lower: 0, Upper: 10}, 5, 6, []Range{{4, 0, -1, 5}, {10, 10, -1, 1}}},
+		{&Settings{Increment: -1, Lower: 0, Upper: 10}, 0, 3, []Range{{10, 8, -1, 3}}},
+		{
+			&Settings{Increment: -1, Lower: 0, Upper: 2},
+			1,
+			8,
+			[]Range{{0, 0, -1, 1}, {2, 0, -1, 3}, {2, 0, -1, 3}, {2, 2, -1, 1}},
+		},
+		{&Settings{Increment: -3, Lower: 0, Upper: 10}, 8, 4, []Range{{5, 2, -3, 2}, {10, 7, -3, 2}}},
+		{&Settings{Increment: -3, Lower: -5, Upper: 5}, 5, 3, []Range{{2, -4, -3, 3}}},
+		// inconsistent value, which is out of counter range
+		{&Settings{Increment: -2, Lower: 10, Upper: 20}, 30, 2, []Range{{28, 26, -2, 2}}},
+		{&Settings{Increment: -2, Lower: 10, Upper: 20}, 0, 2, []Range{{20, 18, -2, 2}}},
+	}
+
+	for _, c := range cases {
+		actual, err := c.s.Reserve(c.value, c.size)
+		if err != nil || !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("%+v.Reserve(%d, %d): expected %v, got %v (%v)", *c.s, c.value, c.size, c.expected, actual, err)
+		}
+		// the same as sequential calls of Next()
+		value, n := c.value, 0
+		for _, r := range actual {
+			for i := 0; i < r.Count; i++ {
+				value, _ = c.s.Next(value)
+				if expected := r.First + int64(i)*r.Step; value != expected {
+					t.Errorf("%+v.Reserve(%d, %d): %d value %d is not equal to %d", *c.s, c.value, c.size, n, expected, value)
+				}
+				n++
+			}
+		}
+		if n != c.size {
+			t.Errorf("%+v.Reserve(%d, %d): reserved %d values", *c.s, c.value, c.size, n)
+		}
+	}
+}
+
+func TestSettingsOverflow(t *testing.T) {
+	saturated := &Settings{Increment: 3, Lower: 0, Upper: 10, Overflow: SaturateOnOverflow}
+	failed := &Settings{Increment: 3, Lower: 0, Upper: 10, Overflow: FailOnOverflow}
+	descending := &Settings{Increment: -3, Lower: 0, Upper: 10, Overflow: SaturateOnOverflow}
+	cases := []struct {
+		s        *Settings
+		method   string
+		value    int64
+		expected int64
+		err      error
+	}{
+		{saturated, "Next", 6, 9, nil},
+		{saturated, "Next", 9, 10, nil},
+		{saturated, "Next", 10, 10, nil},
+		{saturated, "Previous", 4, 1, nil},
+		{saturated, "Previous", 1, 0, nil},
+		{saturated, "Previous", 0, 0, nil},
+		{failed, "Next", 6, 9, nil},
+		{failed, "Next", 9, 0, ErrOverflow},
+		{failed, "Next", 10, 0, ErrOverflow},
+		{failed, "Previous", 3, 0, nil},
+		{failed, "Previous", 1, 0, ErrOverflow},
+		{descending, "Next", 1, 0, nil},
+		{descending, "Next", 0, 0, nil},
+		{descending, "Previous", 9, 10, nil},
+		{descending, "Previous", 10, 10, nil},
+	}
+
+	for _, c := range cases {
+		var (
+			actual int64
+			err    error
+		)
+		switch c.method {
+		case "Next":
+			actual, err = c.s.Next(c.value)
+		case "Previous":
+			actual, err = c.s.Previous(c.value)
+		}
+		if err != c.err || actual != c.expected {
+			t.Errorf("%+v.%s(%d): expected %d (%v), got %d (%v)", *c.s, c.method, c.value, c.expected, c.err, actual, err)
+		}
+	}
+
+	// saturated counter repeats the boundary
+	ranges, err := saturated.Reserve(3, 5)
+	expected := []Range{{6, 9, 3, 2}, {10, 10, 0, 3}}
+	if err != nil || !reflect.DeepEqual(ranges, expected) {
+		t.Errorf("%+v.Reserve(3, 5): expected %v, got %v (%v)", *saturated, expected, ranges, err)
+	}
+	ranges, err = descending.Reserve(3, 3)
+	expected = []Range{{0, 0, 0, 3}}
+	if err != nil || !reflect.DeepEqual(ranges, expected) {
+		t.Errorf("%+v.Reserve(3, 3): expected %v, got %v (%v)", *descending, expected, ranges, err)
+	}
+	// block is not reserved partially
+	if ranges, err := failed.Reserve(3, 2); err != nil || len(ranges) != 1 {
+		t.Errorf("%+v.Reserve(3, 2): unexpected result %v (%v)", *failed, ranges, err)
+	}
+	if ranges, err := failed.Reserve(3, 3); err != ErrOverflow || ranges != nil {
+		t.Errorf("%+v.Reserve(3, 3): expected %v, got %v (%v)", *failed, ErrOverflow, ranges, err)
+	}
+}
+
+func TestSettingsWraps(t *testing.T) {
+	cases := []struct {
+		s       *Settings
+		value   int64
+		wraps   bool
+		unwraps bool
+	}{
+		{&Settings{Increment: 1, Lower: 0, Upper: 10}, 5, false, false},
+		{&Settings{Increment: 1, Lower: 0, Upper: 10}, 10, true, false},
+		{&Settings{Increment: 1, Lower: 0, Upper: 10}, 0, false, true},
+		{&Settings{Increment: 3, Lower: 0, Upper: 10}, 9, true, false},
+		{&Settings{Increment: 3, Lower: 0, Upper: 10}, 2, false, true},
+		{&Settings{Increment: -3, Lower: 0, Upper: 10}, 2, true, false},
+		{&Settings{Increment: -3, Lower: 0, Upper: 10}, 9, false, true},
+		{&Settings{Increment: 0, Lower: 0, Upper: 10}, 10, false, false},
+		{&Settings{Increment: 1, Lower: 0, Upper: 10, Overflow: SaturateOnOverflow}, 10, false, false},
+		{&Settings{Increment: 1, Lower: 0, Upper: 10, Overflow: FailOnOverflow}, 0, false, false},
+	}
+
+	for _, c := range cases {
+		if actual := c.s.Wraps(c.value); actual != c.wraps {
+			t.Errorf("%+v.Wraps(%d): expected %t, got %t", *c.s, c.value, c.wraps, actual)
+		}
+		if actual := c.s.Unwraps(c.value); actual != c.unwraps {
+			t.Errorf("%+v.Unwraps(%d): expected %t, got %t", *c.s, c.value, c.unwraps, actual)
+		}
+	}
+
+	s := &Settings{Increment: 1, Lower: 0, Upper: 2}
+	for _, c := range []struct {
+		value          int64
+		size, expected int
+	}{
+		{1, 1, 0},
+		{2, 1, 1},
+		{1, 8, 3},
+		{0, 3, 1},
+	} {
+		ranges, _ := s.Reserve(c.value, c.size)
+		if actual := s.CountWraps(c.value, ranges); actual != c.expected {
+			t.Errorf("%+v.CountWraps(%d, %v): expected %d, got %d", *s, c.value, ranges, c.expected, actual)
+		}
+	}
+}
+
+func TestSettingsInt64Range(t *testing.T) {
+	full := &Settings{StartFrom: 0, Increment: 1, Lower: math.MinInt64, Upper: math.MaxInt64}
+	if err := full.verify(); err != nil {
+		t.Errorf("%+v.verify(): unexpected error %q", *full, err)
+	}
+	wide := &Settings{StartFrom: 0, Increment: math.MaxInt64, Lower: -1, Upper: math.MaxInt64}
+	if err := wide.verify(); err != nil {
+		t.Errorf("%+v.verify(): unexpected error %q", *wide, err)
+	}
+	countdown := &Settings{StartFrom: math.MaxInt64, Increment: math.MinInt64, Lower: math.MinInt64, Upper: math.MaxInt64}
+	if err := countdown.verify(); err != nil {
+		t.Errorf("%+v.verify(): unexpected error %q", *countdown, err)
+	}
+	narrow := &Settings{StartFrom: 0, Increment: math.MaxInt64, Lower: 0, Upper: math.MaxInt64 - 1}
+	if err := narrow.verify(); err == nil {
+		t.Errorf("%+v.verify(): expected error, got nothing", *narrow)
+	}
+
+	cases := []struct {
+		s        *Settings
+		method   string
+		value    int64
+		expected int64
+	}{
+		{full, "Next", math.MaxInt64 - 1, math.MaxInt64},
+		{full, "Next", math.MaxInt64, math.MinInt64},
+		{full, "Previous", math.MinInt64, math.MaxInt64},
+		{full, "Previous", math.MaxInt64, math.MaxInt64 - 1},
+		{wide, "Next", 0, math.MaxInt64},
+		{wide, "Next", 1, -1},
+		{wide, "Previous", -1, math.MaxInt64},
+		{countdown, "Next", math.MaxInt64, -1},
+		{countdown, "Next", -1, math.MaxInt64},
+		{countdown, "Previous", math.MaxInt64, math.MinInt64},
+	}
+	for _, c := range cases {
+		var (
+			actual int64
+			err    error
+		)
+		switch c.method {
+		case "Next":
+			actual, err = c.s.Next(c.value)
+		case "Previous":
+			actual, err = c.s.Previous(c.value)
+		}
+		if err != nil || actual != c.expected {
+			t.Errorf("%+v.%s(%d): expected %d, got %d (%v)", *c.s, c.method, c.value, c.expected, actual, err)
+		}
+	}
+
+	ranges, err := full.Reserve(math.MaxInt64-1, 3)
+	expected := []Range{{math.MaxInt64, math.MaxInt64, 1, 1}, {math.MinInt64, math.MinInt64 + 1, 1, 2}}
+	if err != nil || !reflect.DeepEqual(ranges, expected) {
+		t.Errorf("%+v.Reserve(MaxInt64-1, 3): expected %v, got %v (%v)", *full, expected, ranges, err)
+	}
+}
@@ -0,0 +1,74 @@
+package counter
+
+// State - counter value with number of its cycle.
+type State struct {
+	// Value - counter value
+	Value int64
+	// Cycle - number of the counter cycle, it is increased every time the counter wraps on increase
+	// and is decreased when the counter returns to the previous cycle on decrease
+	Cycle int
+	// Wraps - how many times the counter wrapped during the last change, it is negative for decrease
+	Wraps int
+	// Previous - counter value before the last change, it is set by methods which change the state
+	Previous int64
+}
+
+// Increased - returns the state after increase of the counter with given settings.
+func (st State) Increased(s *Settings) (State, error) {
+	next, err := s.Next(st.Value)
+	if err != nil {
+		return st, err
+	}
+	wraps := 0
+	if s.Wraps(st.Value) {
+		wraps = 1
+	}
+	return State{Value: next, Cycle: st.Cycle + wraps, Wraps: wraps, Previous: st.Value}, nil
+}
+
+// Decreased - returns the state after decrease of the counter with given settings.
+func (st State) Decreased(s *Settings) (State, error) {
+	previous, err := s.Previous(st.Value)
+	if err != nil {
+		return st, err
+	}
+	wraps := 0
+	if s.Unwraps(st.Value) {
+		wraps = -1
+	}
+	return State{Value: previous, Cycle: st.Cycle + wraps, Wraps: wraps, Previous: st.Value}, nil
+}
+
+// DecreasedBy - returns the state after `size` decreases of the counter with given settings,
+// so it reverts the block of the same size. Wraps of all decreases are summed up.
+// The state is not changed when any decrease fails.
+func (st State) DecreasedBy(s *Settings, size int) (State, error) {
+	state := State{Value: st.Value, Cycle: st.Cycle}
+	for i := 0; i < size; i++ {
+		next, err := state.Decreased(s)
+		if err != nil {
+			return st, err
+		}
+		state.Value, state.Cycle, state.Wraps = next.Value, next.Cycle, state.Wraps+next.Wraps
+	}
+	state.Previous = st.Value
+	return state, nil
+}
+
+// Reset - returns the state after reset of the counter with given settings, the cycle is kept unchanged.
+func (st State) Reset(s *Settings) State {
+	return State{Value: s.Start(), Cycle: st.Cycle, Previous: st.Value}
+}
+
+// Reserved - returns block of `size` values and the state after the block was reserved.
+func (st State) Reserved(s *Settings, size int) ([]Range, State, error) {
+	ranges, err := s.Reserve(st.Value, size)
+	if err != nil {
+		return nil, st, err
+	}
+	if len(ranges) == 0 {
+		return ranges, State{Value: st.Value, Cycle: st.Cycle, Previous: st.Value}, nil
+	}
+	wraps := s.CountWraps(st.Value, ranges)
+	return ranges, State{Value: ranges[len(ranges)-1].Last, Cycle: st.Cycle + wraps, Wraps: wraps, Previous: st.Value}, nil
+}
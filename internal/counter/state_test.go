@@ -0,0 +1,57 @@
+package counter
+
+import (
+	"testing"
+)
+
+func TestState(t *testing.T) {
+	s := &Settings{Increment: 1, Lower: 0, Upper: 2}
+	st := State{Value: 1, Cycle: 5}
+
+	expected := []State{{2, 5, 0, 1}, {0, 6, 1, 2}, {1, 6, 0, 0}, {2, 6, 0, 1}, {0, 7, 1, 2}}
+	for _, e := range expected {
+		var err error
+		if st, err = st.Increased(s); err != nil || st != e {
+			t.Errorf("Increased(): expected %+v, got %+v (%v)", e, st, err)
+		}
+	}
+
+	expected = []State{{2, 6, -1, 0}, {1, 6, 0, 2}}
+	for _, e := range expected {
+		var err error
+		if st, err = st.Decreased(s); err != nil || st != e {
+			t.Errorf("Decreased(): expected %+v, got %+v (%v)", e, st, err)
+		}
+	}
+
+	ranges, st, err := st.Reserved(s, 8)
+	if e := (State{Value: 0, Cycle: 9, Wraps: 3, Previous: 1}); err != nil || len(ranges) != 4 || st != e {
+		t.Errorf("Reserved(): expected %+v, got %+v %v (%v)", e, st, ranges, err)
+	}
+	if _, e, _ := st.Reserved(s, 0); e != (State{Value: 0, Cycle: 9}) {
+		t.Errorf("Reserved(): unexpected state %+v for empty block", e)
+	}
+
+	// the block is reverted by decrease of the same size
+	if actual, err := st.DecreasedBy(s, 8); err != nil || actual != (State{Value: 1, Cycle: 6, Wraps: -3, Previous: 0}) {
+		t.Errorf("DecreasedBy(): unexpected state %+v (%v)", actual, err)
+	}
+	if actual, _ := st.DecreasedBy(s, 0); actual != (State{Value: 0, Cycle: 9}) {
+		t.Errorf("DecreasedBy(): unexpected state %+v for zero size", actual)
+	}
+
+	if e := (State{Value: 0, Cycle: 9}); st.Reset(s) != e {
+		t.Errorf("Reset(): expected %+v, got %+v", e, st.Reset(s))
+	}
+
+	failed := &Settings{Increment: 1, Lower: 0, Upper: 2, Overflow: FailOnOverflow}
+	st = State{Value: 2, Cycle: 1}
+	if actual, err := st.Increased(failed); err != ErrOverflow || actual != st {
+		t.Errorf("Increased(): expected %v and unchanged state, got %+v (%v)", ErrOverflow, actual, err)
+	}
+	// the first decrease succeeds, but the second one fails, so nothing is changed
+	st = State{Value: 1, Cycle: 1}
+	if actual, err := st.DecreasedBy(failed, 2); err != ErrOverflow || actual != st {
+		t.Errorf("DecreasedBy(): expected %v and unchanged state, got %+v (%v)", ErrOverflow, actual, err)
+	}
+}
@@ -0,0 +1,191 @@
+package grpccore
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/wtask-go/auracounter/internal/api"
+	"github.com/wtask-go/auracounter/internal/grpccore/counterpb"
+
+	"github.com/wtask-go/auracounter/pkg/logging"
+)
+
+// Metadata keys to identify the caller of counter changes, see X-Request-ID and X-Client-ID headers of REST API
+const (
+	requestIDKey = "x-request-id"
+	clientIDKey  = "x-client-id"
+)
+
+// NewCounterServer - builds gRPC server for api.CyclicCounterService implementation.
+// If there is no a plan to log calls, pass logging.Printer as nil,
+// otherwise make an adapter to expose logging.Printer interface.
+func NewCounterServer(service api.CyclicCounterService, l logging.Printer, options ...grpc.ServerOption) *grpc.Server {
+	if service == nil {
+		panic(errors.New("grpccore.NewCounterServer: CounterService is not implemented"))
+	}
+	server := grpc.NewServer(options...)
+	counterpb.RegisterCounterServiceServer(server, &counterServer{service: service, l: l})
+	return server
+}
+
+// counterServer - implements counterpb.CounterServiceServer over api.CyclicCounterService
+type counterServer struct {
+	service api.CyclicCounterService
+	l       logging.Printer
+}
+
+// GetValue - returns current value of counter.
+func (s *counterServer) GetValue(ctx context.Context, req *counterpb.CounterRequest) (*counterpb.ValueResult, error) {
+	result, apiErr := s.service.GetCounterValue(int(req.CounterId))
+	if apiErr != nil {
+		return nil, s.fail(ctx, apiErr)
+	}
+	s.succeed(ctx)
+	return valueResult(result), nil
+}
+
+// Increase - increases counter once per idempotency key or every time when the key is empty.
+func (s *counterServer) Increase(ctx context.Context, req *counterpb.IncreaseRequest) (*counterpb.ValueResult, error) {
+	var (
+		result *api.IntValueResult
+		apiErr *api.Error
+	)
+	service := s.service.WithCaller(callerOf(ctx))
+	if req.IdempotencyKey != "" {
+		result, apiErr = service.IncreaseCounterOnce(int(req.CounterId), req.IdempotencyKey)
+	} else {
+		result, apiErr = service.IncreaseCounter(int(req.CounterId))
+	}
+	if apiErr != nil {
+		return nil, s.fail(ctx, apiErr)
+	}
+	s.succeed(ctx)
+	return valueResult(result), nil
+}
+
+// GetSettings - returns current settings of counter and their version.
+func (s *counterServer) GetSettings(ctx context.Context, req *counterpb.CounterRequest) (*counterpb.Settings, error) {
+	result, apiErr := s.service.GetCounterSettings(int(req.CounterId))
+	if apiErr != nil {
+		return nil, s.fail(ctx, apiErr)
+	}
+	s.succeed(ctx)
+	return &counterpb.Settings{
+		Increment: result.Increment,
+		Lower:     result.Lower,
+		Upper:     result.Upper,
+		StartFrom: result.StartFrom,
+		Overflow:  result.Overflow,
+		Version:   int32(result.Version),
+	}, nil
+}
+
+// SetSettings - sets new settings of counter when they have expected version (if any).
+func (s *counterServer) SetSettings(ctx context.Context, req *counterpb.SetSettingsRequest) (*counterpb.SetSettingsResult, error) {
+	var settings *api.CounterSettings
+	if req.Settings != nil {
+		settings = &api.CounterSettings{
+			Increment: req.Settings.Increment,
+			Lower:     req.Settings.Lower,
+			Upper:     req.Settings.Upper,
+			StartFrom: req.Settings.StartFrom,
+			Overflow:  req.Settings.Overflow,
+		}
+	}
+	result, apiErr := s.service.
+		WithCaller(callerOf(ctx)).
+		UpdateCounterSettings(int(req.CounterId), settings, req.ValueMode, int(req.Version))
+	if apiErr != nil {
+		return nil, s.fail(ctx, apiErr)
+	}
+	s.succeed(ctx)
+	return &counterpb.SetSettingsResult{Ok: result.OK, Version: int32(result.Version)}, nil
+}
+
+// succeed - logs successful call.
+func (s *counterServer) succeed(ctx context.Context) {
+	logging.PrintInfo(s.l, codes.OK, formatCall(ctx))
+}
+
+// fail - logs failed call and converts API error into gRPC status error.
+func (s *counterServer) fail(ctx context.Context, apiErr *api.Error) error {
+	code := statusCode(apiErr)
+	logging.PrintError(s.l, code, formatCall(ctx), logging.QuoteError(apiErr.ExposeError()))
+	return status.Error(code, apiErr.Error())
+}
+
+// statusCode - maps API error into gRPC status code.
+func statusCode(apiErr *api.Error) codes.Code {
+	switch {
+	case apiErr == nil:
+		return codes.OK
+	case apiErr.IsInternal():
+		return codes.Internal
+	case apiErr.Code == api.CounterOverflowCode:
+		return codes.OutOfRange
+	case apiErr.Code == api.VersionMismatchCode:
+		return codes.FailedPrecondition
+	default:
+		return codes.InvalidArgument
+	}
+}
+
+// callerOf - identifies the caller by incoming metadata, peer address is used when client ID is not passed.
+// Request ID is generated when it is missing and is returned to client within response header.
+func callerOf(ctx context.Context) api.Caller {
+	caller := api.Caller{}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(requestIDKey); len(values) > 0 {
+			caller.RequestID = values[0]
+		}
+		if values := md.Get(clientIDKey); len(values) > 0 {
+			caller.Client = values[0]
+		}
+	}
+	if caller.RequestID == "" {
+		id := make([]byte, 16)
+		if _, err := rand.Read(id); err == nil {
+			caller.RequestID = hex.EncodeToString(id)
+		}
+	}
+	if caller.Client == "" {
+		if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+			caller.Client = p.Addr.String()
+			if host, _, err := net.SplitHostPort(caller.Client); err == nil {
+				caller.Client = host
+			}
+		}
+	}
+	grpc.SetHeader(ctx, metadata.Pairs(requestIDKey, caller.RequestID))
+	return caller
+}
+
+// valueResult - converts API value into gRPC message.
+func valueResult(result *api.IntValueResult) *counterpb.ValueResult {
+	return &counterpb.ValueResult{
+		Value:    result.Value,
+		Cycle:    int32(result.Cycle),
+		Wrapped:  result.Wrapped,
+		Replayed: result.Replayed,
+	}
+}
+
+// formatCall - formats attributes of the call as solid string.
+func formatCall(ctx context.Context) string {
+	method, _ := grpc.Method(ctx)
+	address := ""
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		address = p.Addr.String()
+	}
+	return fmt.Sprintf("gRPC %s %s", method, address)
+}
@@ -0,0 +1,597 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: counter.proto
+
+package counterpb
+
+/*
+Cyclic counters over gRPC, see internal/api for the meaning of every field.
+Regenerate Go code after changing this file:
+  protoc --go_out=plugins=grpc:. counter.proto
+*/
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+import (
+	context "golang.org/x/net/context"
+	grpc "google.golang.org/grpc"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+// CounterRequest - addresses counter by ID.
+type CounterRequest struct {
+	CounterId            int32    `protobuf:"varint,1,opt,name=counter_id,json=counterId,proto3" json:"counter_id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CounterRequest) Reset()         { *m = CounterRequest{} }
+func (m *CounterRequest) String() string { return proto.CompactTextString(m) }
+func (*CounterRequest) ProtoMessage()    {}
+func (*CounterRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_counter_b5e32fb58d792497, []int{0}
+}
+func (m *CounterRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CounterRequest.Unmarshal(m, b)
+}
+func (m *CounterRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CounterRequest.Marshal(b, m, deterministic)
+}
+func (dst *CounterRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CounterRequest.Merge(dst, src)
+}
+func (m *CounterRequest) XXX_Size() int {
+	return xxx_messageInfo_CounterRequest.Size(m)
+}
+func (m *CounterRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_CounterRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CounterRequest proto.InternalMessageInfo
+
+func (m *CounterRequest) GetCounterId() int32 {
+	if m != nil {
+		return m.CounterId
+	}
+	return 0
+}
+
+// IncreaseRequest - addresses counter by ID with optional idempotency key (up to 128 chars).
+type IncreaseRequest struct {
+	CounterId            int32    `protobuf:"varint,1,opt,name=counter_id,json=counterId,proto3" json:"counter_id,omitempty"`
+	IdempotencyKey       string   `protobuf:"bytes,2,opt,name=idempotency_key,json=idempotencyKey,proto3" json:"idempotency_key,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *IncreaseRequest) Reset()         { *m = IncreaseRequest{} }
+func (m *IncreaseRequest) String() string { return proto.CompactTextString(m) }
+func (*IncreaseRequest) ProtoMessage()    {}
+func (*IncreaseRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_counter_b5e32fb58d792497, []int{1}
+}
+func (m *IncreaseRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_IncreaseRequest.Unmarshal(m, b)
+}
+func (m *IncreaseRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_IncreaseRequest.Marshal(b, m, deterministic)
+}
+func (dst *IncreaseRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_IncreaseRequest.Merge(dst, src)
+}
+func (m *IncreaseRequest) XXX_Size() int {
+	return xxx_messageInfo_IncreaseRequest.Size(m)
+}
+func (m *IncreaseRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_IncreaseRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_IncreaseRequest proto.InternalMessageInfo
+
+func (m *IncreaseRequest) GetCounterId() int32 {
+	if m != nil {
+		return m.CounterId
+	}
+	return 0
+}
+
+func (m *IncreaseRequest) GetIdempotencyKey() string {
+	if m != nil {
+		return m.IdempotencyKey
+	}
+	return ""
+}
+
+// ValueResult - value of counter, see api.IntValueResult.
+type ValueResult struct {
+	Value                int64    `protobuf:"varint,1,opt,name=value,proto3" json:"value,omitempty"`
+	Cycle                int32    `protobuf:"varint,2,opt,name=cycle,proto3" json:"cycle,omitempty"`
+	Wrapped              bool     `protobuf:"varint,3,opt,name=wrapped,proto3" json:"wrapped,omitempty"`
+	Replayed             bool     `protobuf:"varint,4,opt,name=replayed,proto3" json:"replayed,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ValueResult) Reset()         { *m = ValueResult{} }
+func (m *ValueResult) String() string { return proto.CompactTextString(m) }
+func (*ValueResult) ProtoMessage()    {}
+func (*ValueResult) Descriptor() ([]byte, []int) {
+	return fileDescriptor_counter_b5e32fb58d792497, []int{2}
+}
+func (m *ValueResult) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ValueResult.Unmarshal(m, b)
+}
+func (m *ValueResult) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ValueResult.Marshal(b, m, deterministic)
+}
+func (dst *ValueResult) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ValueResult.Merge(dst, src)
+}
+func (m *ValueResult) XXX_Size() int {
+	return xxx_messageInfo_ValueResult.Size(m)
+}
+func (m *ValueResult) XXX_DiscardUnknown() {
+	xxx_messageInfo_ValueResult.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ValueResult proto.InternalMessageInfo
+
+func (m *ValueResult) GetValue() int64 {
+	if m != nil {
+		return m.Value
+	}
+	return 0
+}
+
+func (m *ValueResult) GetCycle() int32 {
+	if m != nil {
+		return m.Cycle
+	}
+	return 0
+}
+
+func (m *ValueResult) GetWrapped() bool {
+	if m != nil {
+		return m.Wrapped
+	}
+	return false
+}
+
+func (m *ValueResult) GetReplayed() bool {
+	if m != nil {
+		return m.Replayed
+	}
+	return false
+}
+
+// Settings - settings of counter, see api.CounterSettings.
+// Version is returned only, it is ignored when settings are set.
+type Settings struct {
+	Increment            int64    `protobuf:"varint,1,opt,name=increment,proto3" json:"increment,omitempty"`
+	Lower                int64    `protobuf:"varint,2,opt,name=lower,proto3" json:"lower,omitempty"`
+	Upper                int64    `protobuf:"varint,3,opt,name=upper,proto3" json:"upper,omitempty"`
+	StartFrom            int64    `protobuf:"varint,4,opt,name=start_from,json=startFrom,proto3" json:"start_from,omitempty"`
+	Overflow             string   `protobuf:"bytes,5,opt,name=overflow,proto3" json:"overflow,omitempty"`
+	Version              int32    `protobuf:"varint,6,opt,name=version,proto3" json:"version,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Settings) Reset()         { *m = Settings{} }
+func (m *Settings) String() string { return proto.CompactTextString(m) }
+func (*Settings) ProtoMessage()    {}
+func (*Settings) Descriptor() ([]byte, []int) {
+	return fileDescriptor_counter_b5e32fb58d792497, []int{3}
+}
+func (m *Settings) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Settings.Unmarshal(m, b)
+}
+func (m *Settings) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Settings.Marshal(b, m, deterministic)
+}
+func (dst *Settings) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Settings.Merge(dst, src)
+}
+func (m *Settings) XXX_Size() int {
+	return xxx_messageInfo_Settings.Size(m)
+}
+func (m *Settings) XXX_DiscardUnknown() {
+	xxx_messageInfo_Settings.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Settings proto.InternalMessageInfo
+
+func (m *Settings) GetIncrement() int64 {
+	if m != nil {
+		return m.Increment
+	}
+	return 0
+}
+
+func (m *Settings) GetLower() int64 {
+	if m != nil {
+		return m.Lower
+	}
+	return 0
+}
+
+func (m *Settings) GetUpper() int64 {
+	if m != nil {
+		return m.Upper
+	}
+	return 0
+}
+
+func (m *Settings) GetStartFrom() int64 {
+	if m != nil {
+		return m.StartFrom
+	}
+	return 0
+}
+
+func (m *Settings) GetOverflow() string {
+	if m != nil {
+		return m.Overflow
+	}
+	return ""
+}
+
+func (m *Settings) GetVersion() int32 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+// SetSettingsRequest - new settings of counter.
+// Value mode is one of "preserve" (default), "clamp" or "reset".
+// Non-zero version is the expected version of current settings,
+// settings are not changed when they have another version.
+type SetSettingsRequest struct {
+	CounterId            int32     `protobuf:"varint,1,opt,name=counter_id,json=counterId,proto3" json:"counter_id,omitempty"`
+	Settings             *Settings `protobuf:"bytes,2,opt,name=settings,proto3" json:"settings,omitempty"`
+	ValueMode            string    `protobuf:"bytes,3,opt,name=value_mode,json=valueMode,proto3" json:"value_mode,omitempty"`
+	Version              int32     `protobuf:"varint,4,opt,name=version,proto3" json:"version,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
+	XXX_unrecognized     []byte    `json:"-"`
+	XXX_sizecache        int32     `json:"-"`
+}
+
+func (m *SetSettingsRequest) Reset()         { *m = SetSettingsRequest{} }
+func (m *SetSettingsRequest) String() string { return proto.CompactTextString(m) }
+func (*SetSettingsRequest) ProtoMessage()    {}
+func (*SetSettingsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_counter_b5e32fb58d792497, []int{4}
+}
+func (m *SetSettingsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SetSettingsRequest.Unmarshal(m, b)
+}
+func (m *SetSettingsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SetSettingsRequest.Marshal(b, m, deterministic)
+}
+func (dst *SetSettingsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetSettingsRequest.Merge(dst, src)
+}
+func (m *SetSettingsRequest) XXX_Size() int {
+	return xxx_messageInfo_SetSettingsRequest.Size(m)
+}
+func (m *SetSettingsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetSettingsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetSettingsRequest proto.InternalMessageInfo
+
+func (m *SetSettingsRequest) GetCounterId() int32 {
+	if m != nil {
+		return m.CounterId
+	}
+	return 0
+}
+
+func (m *SetSettingsRequest) GetSettings() *Settings {
+	if m != nil {
+		return m.Settings
+	}
+	return nil
+}
+
+func (m *SetSettingsRequest) GetValueMode() string {
+	if m != nil {
+		return m.ValueMode
+	}
+	return ""
+}
+
+func (m *SetSettingsRequest) GetVersion() int32 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+// SetSettingsResult - new version of settings.
+type SetSettingsResult struct {
+	Ok                   bool     `protobuf:"varint,1,opt,name=ok,proto3" json:"ok,omitempty"`
+	Version              int32    `protobuf:"varint,2,opt,name=version,proto3" json:"version,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SetSettingsResult) Reset()         { *m = SetSettingsResult{} }
+func (m *SetSettingsResult) String() string { return proto.CompactTextString(m) }
+func (*SetSettingsResult) ProtoMessage()    {}
+func (*SetSettingsResult) Descriptor() ([]byte, []int) {
+	return fileDescriptor_counter_b5e32fb58d792497, []int{5}
+}
+func (m *SetSettingsResult) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SetSettingsResult.Unmarshal(m, b)
+}
+func (m *SetSettingsResult) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SetSettingsResult.Marshal(b, m, deterministic)
+}
+func (dst *SetSettingsResult) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetSettingsResult.Merge(dst, src)
+}
+func (m *SetSettingsResult) XXX_Size() int {
+	return xxx_messageInfo_SetSettingsResult.Size(m)
+}
+func (m *SetSettingsResult) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetSettingsResult.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetSettingsResult proto.InternalMessageInfo
+
+func (m *SetSettingsResult) GetOk() bool {
+	if m != nil {
+		return m.Ok
+	}
+	return false
+}
+
+func (m *SetSettingsResult) GetVersion() int32 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func init() {
+	proto.RegisterType((*CounterRequest)(nil), "aura.counter.v1.CounterRequest")
+	proto.RegisterType((*IncreaseRequest)(nil), "aura.counter.v1.IncreaseRequest")
+	proto.RegisterType((*ValueResult)(nil), "aura.counter.v1.ValueResult")
+	proto.RegisterType((*Settings)(nil), "aura.counter.v1.Settings")
+	proto.RegisterType((*SetSettingsRequest)(nil), "aura.counter.v1.SetSettingsRequest")
+	proto.RegisterType((*SetSettingsResult)(nil), "aura.counter.v1.SetSettingsResult")
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// CounterServiceClient is the client API for CounterService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type CounterServiceClient interface {
+	// GetValue - returns current value of counter.
+	GetValue(ctx context.Context, in *CounterRequest, opts ...grpc.CallOption) (*ValueResult, error)
+	// Increase - increases counter by its increment and returns new value.
+	// Increase with non-empty idempotency key is done once per key until the key expires.
+	Increase(ctx context.Context, in *IncreaseRequest, opts ...grpc.CallOption) (*ValueResult, error)
+	// GetSettings - returns current settings of counter and their version.
+	GetSettings(ctx context.Context, in *CounterRequest, opts ...grpc.CallOption) (*Settings, error)
+	// SetSettings - sets new settings of counter atomically.
+	SetSettings(ctx context.Context, in *SetSettingsRequest, opts ...grpc.CallOption) (*SetSettingsResult, error)
+}
+
+type counterServiceClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewCounterServiceClient(cc *grpc.ClientConn) CounterServiceClient {
+	return &counterServiceClient{cc}
+}
+
+func (c *counterServiceClient) GetValue(ctx context.Context, in *CounterRequest, opts ...grpc.CallOption) (*ValueResult, error) {
+	out := new(ValueResult)
+	err := c.cc.Invoke(ctx, "/aura.counter.v1.CounterService/GetValue", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *counterServiceClient) Increase(ctx context.Context, in *IncreaseRequest, opts ...grpc.CallOption) (*ValueResult, error) {
+	out := new(ValueResult)
+	err := c.cc.Invoke(ctx, "/aura.counter.v1.CounterService/Increase", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *counterServiceClient) GetSettings(ctx context.Context, in *CounterRequest, opts ...grpc.CallOption) (*Settings, error) {
+	out := new(Settings)
+	err := c.cc.Invoke(ctx, "/aura.counter.v1.CounterService/GetSettings", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *counterServiceClient) SetSettings(ctx context.Context, in *SetSettingsRequest, opts ...grpc.CallOption) (*SetSettingsResult, error) {
+	out := new(SetSettingsResult)
+	err := c.cc.Invoke(ctx, "/aura.counter.v1.CounterService/SetSettings", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CounterServiceServer is the server API for CounterService service.
+type CounterServiceServer interface {
+	// GetValue - returns current value of counter.
+	GetValue(context.Context, *CounterRequest) (*ValueResult, error)
+	// Increase - increases counter by its increment and returns new value.
+	// Increase with non-empty idempotency key is done once per key until the key expires.
+	Increase(context.Context, *IncreaseRequest) (*ValueResult, error)
+	// GetSettings - returns current settings of counter and their version.
+	GetSettings(context.Context, *CounterRequest) (*Settings, error)
+	// SetSettings - sets new settings of counter atomically.
+	SetSettings(context.Context, *SetSettingsRequest) (*SetSettingsResult, error)
+}
+
+func RegisterCounterServiceServer(s *grpc.Server, srv CounterServiceServer) {
+	s.RegisterService(&_CounterService_serviceDesc, srv)
+}
+
+func _CounterService_GetValue_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CounterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CounterServiceServer).GetValue(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/aura.counter.v1.CounterService/GetValue",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CounterServiceServer).GetValue(ctx, req.(*CounterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CounterService_Increase_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IncreaseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CounterServiceServer).Increase(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/aura.counter.v1.CounterService/Increase",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CounterServiceServer).Increase(ctx, req.(*IncreaseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CounterService_GetSettings_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CounterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CounterServiceServer).GetSettings(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/aura.counter.v1.CounterService/GetSettings",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CounterServiceServer).GetSettings(ctx, req.(*CounterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CounterService_SetSettings_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetSettingsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CounterServiceServer).SetSettings(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/aura.counter.v1.CounterService/SetSettings",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CounterServiceServer).SetSettings(ctx, req.(*SetSettingsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _CounterService_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "aura.counter.v1.CounterService",
+	HandlerType: (*CounterServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetValue",
+			Handler:    _CounterService_GetValue_Handler,
+		},
+		{
+			MethodName: "Increase",
+			Handler:    _CounterService_Increase_Handler,
+		},
+		{
+			MethodName: "GetSettings",
+			Handler:    _CounterService_GetSettings_Handler,
+		},
+		{
+			MethodName: "SetSettings",
+			Handler:    _CounterService_SetSettings_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "counter.proto",
+}
+
+func init() { proto.RegisterFile("counter.proto", fileDescriptor_counter_b5e32fb58d792497) }
+
+var fileDescriptor_counter_b5e32fb58d792497 = []byte{
+	// 455 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x53, 0x4d, 0x8f, 0x93, 0x50,
+	0x14, 0x0d, 0x30, 0x1d, 0xe1, 0x12, 0xdb, 0xf8, 0xe2, 0x02, 0x9b, 0x31, 0x36, 0xb8, 0xb0, 0xab,
+	0x1a, 0xc7, 0xb8, 0x74, 0xa3, 0x89, 0x93, 0x6a, 0xdc, 0xbc, 0x26, 0x93, 0xe8, 0xa6, 0x61, 0xe0,
+	0x8e, 0x21, 0x05, 0xde, 0xf3, 0xf1, 0xa0, 0xe1, 0xf7, 0xb8, 0xf2, 0xaf, 0xf8, 0xab, 0xcc, 0xbb,
+	0x14, 0x86, 0xb6, 0x3a, 0xd3, 0xe5, 0x39, 0xdc, 0x8f, 0x73, 0xcf, 0x3b, 0xc0, 0xe3, 0x58, 0x54,
+	0x85, 0x46, 0xb5, 0x90, 0x4a, 0x68, 0xc1, 0x26, 0x51, 0xa5, 0xa2, 0x45, 0xc7, 0xd5, 0x6f, 0xc2,
+	0xd7, 0x30, 0xfe, 0xd8, 0x22, 0x8e, 0x3f, 0x2b, 0x2c, 0x35, 0x7b, 0x0e, 0xb0, 0xfb, 0xbe, 0x4e,
+	0x93, 0xc0, 0x9a, 0x59, 0xf3, 0x11, 0xf7, 0x76, 0xcc, 0x32, 0x09, 0xbf, 0xc1, 0x64, 0x59, 0xc4,
+	0x0a, 0xa3, 0x12, 0x4f, 0xeb, 0x60, 0xaf, 0x60, 0x92, 0x26, 0x98, 0x4b, 0xa1, 0xb1, 0x88, 0x9b,
+	0xf5, 0x06, 0x9b, 0xc0, 0x9e, 0x59, 0x73, 0x8f, 0x8f, 0x07, 0xf4, 0x17, 0x6c, 0x42, 0x01, 0xfe,
+	0x75, 0x94, 0x55, 0xc8, 0xb1, 0xac, 0x32, 0xcd, 0x9e, 0xc2, 0xa8, 0x36, 0x90, 0x26, 0x3a, 0xbc,
+	0x05, 0x86, 0x8d, 0x9b, 0x38, 0x43, 0x9a, 0x31, 0xe2, 0x2d, 0x60, 0x01, 0x3c, 0xda, 0xaa, 0x48,
+	0x4a, 0x4c, 0x02, 0x67, 0x66, 0xcd, 0x5d, 0xde, 0x41, 0x36, 0x05, 0x57, 0xa1, 0xcc, 0xa2, 0x06,
+	0x93, 0xe0, 0x8c, 0x3e, 0xf5, 0x38, 0xfc, 0x6d, 0x81, 0xbb, 0x42, 0xad, 0xd3, 0xe2, 0x47, 0xc9,
+	0x2e, 0xc0, 0x4b, 0xcd, 0x61, 0x39, 0x16, 0x7a, 0xb7, 0xf2, 0x8e, 0x30, 0x6b, 0x33, 0xb1, 0x45,
+	0x45, 0x6b, 0x1d, 0xde, 0x02, 0xc3, 0x56, 0x52, 0xa2, 0xa2, 0xa5, 0x0e, 0x6f, 0x81, 0xf1, 0xa3,
+	0xd4, 0x91, 0xd2, 0xeb, 0x5b, 0x25, 0x72, 0x5a, 0xea, 0x70, 0x8f, 0x98, 0x4f, 0x4a, 0xe4, 0x46,
+	0x91, 0xa8, 0x51, 0xdd, 0x66, 0x62, 0x1b, 0x8c, 0xc8, 0x88, 0x1e, 0x9b, 0x3b, 0x6a, 0x54, 0x65,
+	0x2a, 0x8a, 0xe0, 0x9c, 0xee, 0xeb, 0x60, 0xf8, 0xcb, 0x02, 0xb6, 0x42, 0xdd, 0xc9, 0x3d, 0xd1,
+	0xfb, 0x77, 0xe0, 0x96, 0xbb, 0x0e, 0x52, 0xee, 0x5f, 0x3e, 0x5b, 0x1c, 0x44, 0x60, 0xd1, 0x8f,
+	0xec, 0x4b, 0xcd, 0x54, 0x72, 0x7b, 0x9d, 0x8b, 0x04, 0xe9, 0x38, 0x8f, 0x7b, 0xc4, 0x7c, 0x15,
+	0x09, 0x0e, 0x55, 0x9e, 0xed, 0xab, 0x7c, 0x0f, 0x4f, 0xf6, 0x44, 0xd2, 0x43, 0x8e, 0xc1, 0x16,
+	0x1b, 0xd2, 0xe6, 0x72, 0x5b, 0x6c, 0x86, 0xed, 0xf6, 0x5e, 0xfb, 0xe5, 0x1f, 0xbb, 0x8f, 0xe3,
+	0x0a, 0x55, 0x9d, 0xc6, 0xc8, 0x96, 0xe0, 0x5e, 0xa1, 0xa6, 0x5c, 0xb0, 0x17, 0x47, 0xda, 0xf7,
+	0xb3, 0x3b, 0xbd, 0x38, 0x2a, 0x18, 0x06, 0xea, 0x33, 0xb8, 0x5d, 0x74, 0xd9, 0xec, 0xa8, 0xf2,
+	0x20, 0xd5, 0x0f, 0xcc, 0x5a, 0x82, 0x7f, 0x75, 0x77, 0xe8, 0xc3, 0xca, 0xfe, 0x6f, 0x3b, 0xbb,
+	0x06, 0x7f, 0xe0, 0x19, 0x7b, 0xf9, 0xaf, 0xca, 0x83, 0x67, 0x9f, 0x86, 0xf7, 0x17, 0x19, 0x89,
+	0x1f, 0xfc, 0xef, 0x5d, 0x10, 0xe4, 0xcd, 0xcd, 0x39, 0xfd, 0xff, 0x6f, 0xff, 0x0e, 0x00, 0x92,
+	0x32, 0xca, 0xf0, 0x10, 0x04, 0x00, 0x00,
+}
@@ -0,0 +1,5 @@
+/*
+Package grpccore define public gRPC API of counter service,
+see protobuf definitions in counterpb package.
+*/
+package grpccore
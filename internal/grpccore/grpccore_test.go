@@ -0,0 +1,120 @@
+package grpccore
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/wtask-go/auracounter/internal/counter"
+	"github.com/wtask-go/auracounter/internal/counter/datastore/memory"
+	"github.com/wtask-go/auracounter/internal/grpccore/counterpb"
+)
+
+// newClient - starts gRPC server with in-memory storage and connects to it.
+func newClient(t *testing.T) (counterpb.CounterServiceClient, func()) {
+	service, err := counter.NewCyclicCounterService(memory.NewStorage().Repository())
+	if err != nil {
+		t.Fatalf("Unable to build counter service: %v", err)
+	}
+	listener := bufconn.Listen(1024 * 1024)
+	server := NewCounterServer(service, nil)
+	go server.Serve(listener)
+	conn, err := grpc.Dial(
+		"bufconn",
+		grpc.WithInsecure(),
+		grpc.WithDialer(func(string, time.Duration) (net.Conn, error) { return listener.Dial() }),
+	)
+	if err != nil {
+		t.Fatalf("Unable to connect gRPC server: %v", err)
+	}
+	return counterpb.NewCounterServiceClient(conn), func() {
+		conn.Close()
+		server.Stop()
+	}
+}
+
+func TestCounterServer(t *testing.T) {
+	client, stop := newClient(t)
+	defer stop()
+	ctx := context.Background()
+
+	settings := &counterpb.Settings{Increment: 10, Lower: 0, Upper: 20}
+	set, err := client.SetSettings(ctx, &counterpb.SetSettingsRequest{CounterId: 1, Settings: settings})
+	if err != nil || !set.Ok || set.Version != 1 {
+		t.Fatalf("SetSettings(): unexpected result %+v (%v)", set, err)
+	}
+	got, err := client.GetSettings(ctx, &counterpb.CounterRequest{CounterId: 1})
+	if err != nil || got.Increment != 10 || got.Upper != 20 || got.Overflow != "wrap" || got.Version != 1 {
+		t.Errorf("GetSettings(): unexpected result %+v (%v)", got, err)
+	}
+
+	var header metadata.MD
+	ctx = metadata.AppendToOutgoingContext(ctx, requestIDKey, "request")
+	value, err := client.Increase(ctx, &counterpb.IncreaseRequest{CounterId: 1}, grpc.Header(&header))
+	if err != nil || value.Value != 10 {
+		t.Errorf("Increase(): unexpected result %+v (%v)", value, err)
+	}
+	if ids := header.Get(requestIDKey); len(ids) != 1 || ids[0] != "request" {
+		t.Errorf("Increase(): expected request ID within header, got %v", header)
+	}
+	for i, expected := range []*counterpb.ValueResult{{Value: 20}, {Value: 20, Replayed: true}} {
+		value, err := client.Increase(ctx, &counterpb.IncreaseRequest{CounterId: 1, IdempotencyKey: "key"})
+		if err != nil || value.Value != expected.Value || value.Replayed != expected.Replayed {
+			t.Errorf("Increase() #%d with key: expected %+v, got %+v (%v)", i+1, expected, value, err)
+		}
+	}
+	value, err = client.Increase(ctx, &counterpb.IncreaseRequest{CounterId: 1})
+	if err != nil || value.Value != 0 || value.Cycle != 1 || !value.Wrapped {
+		t.Errorf("Increase(): expected wrapped counter, got %+v (%v)", value, err)
+	}
+	if value, err := client.GetValue(ctx, &counterpb.CounterRequest{CounterId: 1}); err != nil || value.Value != 0 {
+		t.Errorf("GetValue(): unexpected result %+v (%v)", value, err)
+	}
+
+	overflowing := &counterpb.Settings{Increment: 10, Lower: 0, Upper: 20, Overflow: "fail"}
+	if _, err := client.SetSettings(ctx, &counterpb.SetSettingsRequest{CounterId: 2, Settings: overflowing}); err != nil {
+		t.Fatalf("SetSettings(): unexpected error %v", err)
+	}
+	client.Increase(ctx, &counterpb.IncreaseRequest{CounterId: 2})
+	client.Increase(ctx, &counterpb.IncreaseRequest{CounterId: 2})
+
+	failures := []struct {
+		call     string
+		err      error
+		expected codes.Code
+	}{
+		{"GetValue(0)", call(client.GetValue(ctx, &counterpb.CounterRequest{CounterId: 0})), codes.InvalidArgument},
+		{
+			"SetSettings() with stale version",
+			call(client.SetSettings(ctx, &counterpb.SetSettingsRequest{CounterId: 1, Settings: settings, Version: 2})),
+			codes.FailedPrecondition,
+		},
+		{
+			"SetSettings() without settings",
+			call(client.SetSettings(ctx, &counterpb.SetSettingsRequest{CounterId: 1})),
+			codes.InvalidArgument,
+		},
+		{
+			"Increase() on overflow",
+			call(client.Increase(ctx, &counterpb.IncreaseRequest{CounterId: 2})),
+			codes.OutOfRange,
+		},
+	}
+	for _, f := range failures {
+		if code := status.Code(f.err); code != f.expected {
+			t.Errorf("%s: expected status %s, got %s (%v)", f.call, f.expected, code, f.err)
+		}
+	}
+}
+
+// call - drops result of the call and returns its error.
+func call(_ interface{}, err error) error {
+	return err
+}
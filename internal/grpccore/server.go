@@ -0,0 +1,55 @@
+package grpccore
+
+import (
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// StartServer - starts gRPC server in background or return startup error.
+func StartServer(server *grpc.Server, address string, startupTimeout time.Duration) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	fail := make(chan error, 1)
+	go func() {
+		fail <- server.Serve(listener)
+		close(fail)
+	}()
+	select {
+	case err := <-fail:
+		return err
+	case <-time.After(startupTimeout):
+		return nil
+	}
+}
+
+// StopServer - gracefully stops gRPC server,
+// the server is stopped forcibly when pending calls are not finished within timeout.
+func StopServer(server *grpc.Server, shutdownTimeout time.Duration) error {
+	done := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(shutdownTimeout):
+		server.Stop()
+	}
+	return nil
+}
+
+// LaunchServer - starts server in background, see httpcore.LaunchServer.
+// First returned value is a shutdown function for started server.
+// Second returned value is a startup error.
+func LaunchServer(server *grpc.Server, address string, timeout time.Duration) (shutdown func(timeout time.Duration) error, startup error) {
+	if err := StartServer(server, address, timeout); err != nil {
+		return nil, err
+	}
+	return func(d time.Duration) error {
+		return StopServer(server, d)
+	}, nil
+}
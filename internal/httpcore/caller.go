@@ -0,0 +1,45 @@
+package httpcore
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/wtask-go/auracounter/internal/api"
+)
+
+// Headers to identify the caller of counter changes
+const (
+	RequestIDHeader = "X-Request-ID"
+	ClientIDHeader  = "X-Client-ID"
+)
+
+// CallerOf - identifies the caller by request headers, client address is used when client ID is not passed.
+// Request ID is generated when it is missing and is returned to client within response headers.
+func CallerOf(w http.ResponseWriter, r *http.Request) api.Caller {
+	caller := api.Caller{
+		RequestID: r.Header.Get(RequestIDHeader),
+		Client:    r.Header.Get(ClientIDHeader),
+	}
+	if caller.RequestID == "" {
+		id := make([]byte, 16)
+		if _, err := rand.Read(id); err == nil {
+			caller.RequestID = hex.EncodeToString(id)
+		}
+	}
+	if caller.Client == "" {
+		caller.Client = r.RemoteAddr
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			caller.Client = host
+		}
+	}
+	w.Header().Set(RequestIDHeader, caller.RequestID)
+	return caller
+}
+
+// FormatRequest - formats request attributes as solid string to log it.
+func FormatRequest(r *http.Request) string {
+	return fmt.Sprintf("%s %s %s %s %s", r.Proto, r.Method, r.URL, r.RemoteAddr, r.UserAgent())
+}
@@ -0,0 +1,67 @@
+package httpcore
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// WriteDeadlines - replaces WriteTimeout of http.Server with write deadline per request,
+// so handlers of long-lived responses (e.g. event streams) are able to extend the deadline before every write,
+// while other responses are still limited with the timeout.
+// Use ConnState as http.Server.ConnState hook, wrap server handler with Handler and leave WriteTimeout of server zero.
+type WriteDeadlines struct {
+	timeout time.Duration
+	mx      sync.Mutex
+	// conns - served connections by remote address
+	conns map[string]net.Conn
+}
+
+// deadlineKey - context key of the func to extend write deadline of the request
+type deadlineKey struct{}
+
+// NewWriteDeadlines - builds write deadlines with given timeout per request or per extension.
+func NewWriteDeadlines(timeout time.Duration) *WriteDeadlines {
+	return &WriteDeadlines{timeout: timeout, conns: map[string]net.Conn{}}
+}
+
+// ConnState - tracks connections of the server, hijacked connections manage their deadlines themselves.
+func (d *WriteDeadlines) ConnState(conn net.Conn, state http.ConnState) {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	switch state {
+	case http.StateNew:
+		d.conns[conn.RemoteAddr().String()] = conn
+	case http.StateHijacked, http.StateClosed:
+		delete(d.conns, conn.RemoteAddr().String())
+	}
+}
+
+// Handler - sets write deadline of the connection before the request is handled
+// and exposes it to `next` handler, see ExtendWriteDeadline.
+func (d *WriteDeadlines) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d.mx.Lock()
+		conn, ok := d.conns[r.RemoteAddr]
+		d.mx.Unlock()
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		extend := func() {
+			conn.SetWriteDeadline(time.Now().Add(d.timeout))
+		}
+		extend()
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), deadlineKey{}, extend)))
+	})
+}
+
+// ExtendWriteDeadline - moves write deadline of the request connection forward by timeout of WriteDeadlines.
+// It does nothing when the request is not served through WriteDeadlines.Handler.
+func ExtendWriteDeadline(r *http.Request) {
+	if extend, ok := r.Context().Value(deadlineKey{}).(func()); ok {
+		extend()
+	}
+}
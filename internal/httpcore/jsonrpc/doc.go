@@ -0,0 +1,4 @@
+/*
+Package jsonrpc define public HTTP API in JSON-RPC 2.0 style.
+*/
+package jsonrpc
@@ -0,0 +1,195 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/wtask-go/auracounter/internal/api"
+	"github.com/wtask-go/auracounter/internal/httpcore"
+	"github.com/wtask-go/auracounter/internal/httpcore/response"
+
+	"github.com/wtask-go/auracounter/pkg/logging"
+)
+
+const (
+	// MaxBatchSize - the largest number of requests within single batch
+	MaxBatchSize = 100
+	// maxBodySize - the largest size of HTTP request body in bytes
+	maxBodySize = 1 << 20
+)
+
+// Error codes defined by JSON-RPC 2.0 specification.
+// Client API errors with code (see api.CounterOverflowCode etc.) are returned with the same code.
+const (
+	ParseErrorCode     = -32700
+	InvalidRequestCode = -32600
+	MethodNotFoundCode = -32601
+	InvalidParamsCode  = -32602
+	InternalErrorCode  = -32603
+)
+
+// rpcRequest - single JSON-RPC request, request without ID is a notification and is not answered.
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// rpcResponse - single JSON-RPC response
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// rpcError - JSON-RPC error
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// nullID - ID of response when ID of request can not be detected
+var nullID = json.RawMessage("null")
+
+// NewCounterHandler - builds http handler of JSON-RPC 2.0 requests for api.CounterService implementation.
+// Handler serves POST requests of any path, so it can be mounted under any URI.
+// Single and batch requests are supported, see supported methods in `methods`.
+// If there is no a plan to log requests, pass logging.Printer as nil,
+// otherwise make an adapter to expose logging.Printer interface.
+func NewCounterHandler(service api.CyclicCounterService, l logging.Printer) http.Handler {
+	if service == nil {
+		panic(errors.New("jsonrpc.NewCounterHandler: CounterService is not implemented"))
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			logging.PrintError(l, http.StatusMethodNotAllowed, httpcore.FormatRequest(r))
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+		if err != nil {
+			logging.PrintError(l, ParseErrorCode, httpcore.FormatRequest(r), logging.QuoteError(err))
+			respond(w, r, fail(nullID, ParseErrorCode, "Parse error"))
+			return
+		}
+		caller := httpcore.CallerOf(w, r)
+		// call - handles single request, returns nil response for notification
+		call := func(req json.RawMessage) *rpcResponse {
+			resp, notification, err := handle(service, caller, req)
+			if err != nil {
+				logging.PrintError(l, resp.Error.Code, httpcore.FormatRequest(r), logging.QuoteError(err))
+			} else {
+				logging.PrintInfo(l, http.StatusOK, httpcore.FormatRequest(r))
+			}
+			if notification {
+				return nil
+			}
+			return resp
+		}
+
+		body = bytes.TrimSpace(body)
+		if len(body) == 0 || body[0] != '[' {
+			if resp := call(body); resp != nil {
+				respond(w, r, resp)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		batch := []json.RawMessage{}
+		if err := json.Unmarshal(body, &batch); err != nil {
+			logging.PrintError(l, ParseErrorCode, httpcore.FormatRequest(r), logging.QuoteError(err))
+			respond(w, r, fail(nullID, ParseErrorCode, "Parse error"))
+			return
+		}
+		if len(batch) == 0 || len(batch) > MaxBatchSize {
+			message := fmt.Sprintf("Invalid Request: batch must contain from 1 to %d requests", MaxBatchSize)
+			logging.PrintError(l, InvalidRequestCode, httpcore.FormatRequest(r), logging.QuoteError(errors.New(message)))
+			respond(w, r, fail(nullID, InvalidRequestCode, message))
+			return
+		}
+		responses := []*rpcResponse{}
+		for _, req := range batch {
+			if resp := call(req); resp != nil {
+				responses = append(responses, resp)
+			}
+		}
+		if len(responses) == 0 {
+			// batch of notifications
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		respond(w, r, responses)
+	})
+}
+
+// handle - decodes and executes single request.
+// Returned error describes the reason of failure for logging purposes.
+func handle(service api.CyclicCounterService, caller api.Caller, data json.RawMessage) (resp *rpcResponse, notification bool, err error) {
+	req := &rpcRequest{}
+	if err := json.Unmarshal(data, req); err != nil {
+		if _, ok := err.(*json.SyntaxError); ok {
+			return fail(nullID, ParseErrorCode, "Parse error"), false, err
+		}
+		return fail(nullID, InvalidRequestCode, "Invalid Request"), false, err
+	}
+	notification = req.ID == nil
+	id := req.ID
+	if notification {
+		id = nullID
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		// invalid request is answered even without ID
+		err := fmt.Errorf("unsupported version %q or empty method %q", req.JSONRPC, req.Method)
+		return fail(id, InvalidRequestCode, "Invalid Request"), false, err
+	}
+	m, ok := methods[req.Method]
+	if !ok {
+		return fail(id, MethodNotFoundCode, "Method not found"), notification, fmt.Errorf("method %q is not found", req.Method)
+	}
+	params := req.Params
+	if len(params) == 0 || string(params) == "null" {
+		params = json.RawMessage("{}")
+	}
+	result, apiErr := m(service.WithCaller(caller), params)
+	if apiErr != nil {
+		return fail(id, errorCode(apiErr), apiErr.Error()), notification, apiErr.ExposeError()
+	}
+	return &rpcResponse{JSONRPC: "2.0", Result: result, ID: id}, notification, nil
+}
+
+// fail - builds error response.
+func fail(id json.RawMessage, code int, message string) *rpcResponse {
+	return &rpcResponse{
+		JSONRPC: "2.0",
+		Error:   &rpcError{Code: code, Message: message},
+		ID:      id,
+	}
+}
+
+// errorCode - maps API error into JSON-RPC error code.
+// Client errors without code are reported as invalid params.
+func errorCode(apiErr *api.Error) int {
+	switch {
+	case apiErr.IsInternal():
+		return InternalErrorCode
+	case apiErr.Code != 0:
+		return apiErr.Code
+	default:
+		return InvalidParamsCode
+	}
+}
+
+// respond - writes single response or batch of responses as JSON,
+// HTTP status is always OK as far as errors are described within responses.
+func respond(w http.ResponseWriter, r *http.Request, data interface{}) {
+	response.HandleJSON(http.StatusOK, data)(w, r)
+}
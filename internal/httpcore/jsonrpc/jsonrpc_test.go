@@ -0,0 +1,105 @@
+package jsonrpc
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wtask-go/auracounter/internal/counter"
+	"github.com/wtask-go/auracounter/internal/counter/datastore/memory"
+)
+
+func TestCounterHandler(t *testing.T) {
+	service, err := counter.NewCyclicCounterService(memory.NewStorage().Repository())
+	if err != nil {
+		t.Fatalf("Unable to build counter service: %v", err)
+	}
+	server := httptest.NewServer(NewCounterHandler(service, nil))
+	defer server.Close()
+
+	cases := []struct {
+		request        string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			`{"jsonrpc":"2.0","method":"counter.setSettings","params":{"id":1,"increment":10,"lower":0,"upper":20,"overflow":"fail"},"id":1}`,
+			http.StatusOK,
+			`{"jsonrpc":"2.0","result":{"ok":true,"version":1},"id":1}`,
+		},
+		{
+			`{"jsonrpc":"2.0","method":"counter.increment","params":{"id":1},"id":"a"}`,
+			http.StatusOK,
+			`{"jsonrpc":"2.0","result":{"value":10},"id":"a"}`,
+		},
+		{
+			// notification is not answered
+			`{"jsonrpc":"2.0","method":"counter.increment","params":{"id":1}}`,
+			http.StatusNoContent,
+			``,
+		},
+		{
+			`[{"jsonrpc":"2.0","method":"counter.get","params":{"id":1},"id":1},` +
+				`{"jsonrpc":"2.0","method":"counter.increment","params":{"id":1},"id":2},` +
+				`{"jsonrpc":"2.0","method":"counter.decrement","params":{"id":1}},` +
+				`{"jsonrpc":"2.0","method":"counter.setSettings","params":{"id":1,"increment":1,"lower":0,"upper":20,"version":3},"id":3},` +
+				`{"jsonrpc":"2.0","method":"counter.getSettings","params":{"id":1},"id":4}]`,
+			http.StatusOK,
+			`[{"jsonrpc":"2.0","result":{"value":20},"id":1},` +
+				`{"jsonrpc":"2.0","error":{"code":1,"message":"counter overflow"},"id":2},` +
+				`{"jsonrpc":"2.0","error":{"code":2,"message":"counter settings were changed since expected version"},"id":3},` +
+				`{"jsonrpc":"2.0","result":{"increment":10,"lower":0,"upper":20,"start_from":0,"overflow":"fail","version":1},"id":4}]`,
+		},
+		{
+			`{"jsonrpc":"2.0","method":"counter.get","params":{"id":0},"id":1}`,
+			http.StatusOK,
+			`{"jsonrpc":"2.0","error":{"code":-32602,"message":"invalid counter ID (0)"},"id":1}`,
+		},
+		{
+			`{"jsonrpc":"2.0","method":"counter.setSettings","params":{"id":1,"lower":0,"upper":20},"id":1}`,
+			http.StatusOK,
+			`{"jsonrpc":"2.0","error":{"code":-32602,"message":"Invalid params: increment is required"},"id":1}`,
+		},
+		{
+			`{"jsonrpc":"2.0","method":"counter.unknown","id":1}`,
+			http.StatusOK,
+			`{"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found"},"id":1}`,
+		},
+		{
+			`{"jsonrpc":"1.0","method":"counter.get","id":1}`,
+			http.StatusOK,
+			`{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":1}`,
+		},
+		{
+			`[1]`,
+			http.StatusOK,
+			`[{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}]`,
+		},
+		{
+			`[]`,
+			http.StatusOK,
+			`{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request: batch must contain from 1 to 100 requests"},"id":null}`,
+		},
+		{
+			`{"jsonrpc":"2.0","method"`,
+			http.StatusOK,
+			`{"jsonrpc":"2.0","error":{"code":-32700,"message":"Parse error"},"id":null}`,
+		},
+	}
+	for _, c := range cases {
+		resp, err := http.Post(server.URL, "application/json", strings.NewReader(c.request))
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", c.request, err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != c.expectedStatus {
+			t.Errorf("%s: expected status %d, got %d", c.request, c.expectedStatus, resp.StatusCode)
+		}
+		if actual := strings.TrimSpace(string(body)); actual != c.expectedBody {
+			t.Errorf("%s:\nexpected %s\ngot      %s", c.request, c.expectedBody, actual)
+		}
+	}
+}
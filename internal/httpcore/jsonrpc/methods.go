@@ -0,0 +1,145 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/wtask-go/auracounter/internal/api"
+)
+
+// method - decodes params by name and calls the service on behalf of the caller.
+// Invalid params are reported as client API error.
+type method func(service api.CyclicCounterService, params json.RawMessage) (interface{}, *api.Error)
+
+// methods - supported methods by name
+var methods = map[string]method{
+	"counter.get":         getCounter,
+	"counter.increment":   increaseCounter,
+	"counter.decrement":   decreaseCounter,
+	"counter.reset":       resetCounter,
+	"counter.reserve":     reserveCounterBlock,
+	"counter.getSettings": getCounterSettings,
+	"counter.setSettings": setCounterSettings,
+}
+
+// counterParams - params of methods which address counter only
+type counterParams struct {
+	ID int `json:"id"`
+}
+
+// increaseParams - params of `counter.increment`, counter is increased once per non-empty idempotency key
+type increaseParams struct {
+	ID             int    `json:"id"`
+	IdempotencyKey string `json:"idempotency_key"`
+}
+
+// reserveParams - params of `counter.reserve`
+type reserveParams struct {
+	ID   int `json:"id"`
+	Size int `json:"size"`
+}
+
+// settingsParams - params of `counter.setSettings`.
+// Increment, lower and upper are required, start value is equal to lower when omitted
+// or to upper for descending counter. Non-zero version is the expected version of current settings.
+type settingsParams struct {
+	ID        int    `json:"id"`
+	Increment *int64 `json:"increment"`
+	Lower     *int64 `json:"lower"`
+	Upper     *int64 `json:"upper"`
+	StartFrom *int64 `json:"start_from"`
+	Overflow  string `json:"overflow"`
+	ValueMode string `json:"value_mode"`
+	Version   int    `json:"version"`
+}
+
+// decodeParams - decodes params object into struct, unknown params are not allowed.
+func decodeParams(params json.RawMessage, v interface{}) *api.Error {
+	decoder := json.NewDecoder(bytes.NewReader(params))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return &api.Error{Message: fmt.Sprintf("Invalid params: %s", err)}
+	}
+	return nil
+}
+
+func getCounter(service api.CyclicCounterService, params json.RawMessage) (interface{}, *api.Error) {
+	p := &counterParams{}
+	if err := decodeParams(params, p); err != nil {
+		return nil, err
+	}
+	return service.GetCounterValue(p.ID)
+}
+
+func increaseCounter(service api.CyclicCounterService, params json.RawMessage) (interface{}, *api.Error) {
+	p := &increaseParams{}
+	if err := decodeParams(params, p); err != nil {
+		return nil, err
+	}
+	if p.IdempotencyKey != "" {
+		return service.IncreaseCounterOnce(p.ID, p.IdempotencyKey)
+	}
+	return service.IncreaseCounter(p.ID)
+}
+
+func decreaseCounter(service api.CyclicCounterService, params json.RawMessage) (interface{}, *api.Error) {
+	p := &counterParams{}
+	if err := decodeParams(params, p); err != nil {
+		return nil, err
+	}
+	return service.DecreaseCounter(p.ID)
+}
+
+func resetCounter(service api.CyclicCounterService, params json.RawMessage) (interface{}, *api.Error) {
+	p := &counterParams{}
+	if err := decodeParams(params, p); err != nil {
+		return nil, err
+	}
+	return service.ResetCounter(p.ID)
+}
+
+func reserveCounterBlock(service api.CyclicCounterService, params json.RawMessage) (interface{}, *api.Error) {
+	p := &reserveParams{}
+	if err := decodeParams(params, p); err != nil {
+		return nil, err
+	}
+	return service.ReserveCounterBlock(p.ID, p.Size)
+}
+
+func getCounterSettings(service api.CyclicCounterService, params json.RawMessage) (interface{}, *api.Error) {
+	p := &counterParams{}
+	if err := decodeParams(params, p); err != nil {
+		return nil, err
+	}
+	return service.GetCounterSettings(p.ID)
+}
+
+func setCounterSettings(service api.CyclicCounterService, params json.RawMessage) (interface{}, *api.Error) {
+	p := &settingsParams{}
+	if err := decodeParams(params, p); err != nil {
+		return nil, err
+	}
+	switch {
+	case p.Increment == nil:
+		return nil, &api.Error{Message: "Invalid params: increment is required"}
+	case p.Lower == nil:
+		return nil, &api.Error{Message: "Invalid params: lower is required"}
+	case p.Upper == nil:
+		return nil, &api.Error{Message: "Invalid params: upper is required"}
+	}
+	settings := &api.CounterSettings{
+		Increment: *p.Increment,
+		Lower:     *p.Lower,
+		Upper:     *p.Upper,
+		StartFrom: *p.Lower,
+		Overflow:  p.Overflow,
+	}
+	if settings.Increment < 0 {
+		settings.StartFrom = settings.Upper
+	}
+	if p.StartFrom != nil {
+		settings.StartFrom = *p.StartFrom
+	}
+	return service.UpdateCounterSettings(p.ID, settings, p.ValueMode, p.Version)
+}
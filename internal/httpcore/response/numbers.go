@@ -0,0 +1,39 @@
+package response
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// NumbersAsStrings - returns generic copy of data, where all JSON numbers are replaced with strings.
+// It allows clients which are unable to keep int64 precision (e.g. JavaScript) to get exact values.
+func NumbersAsStrings(data interface{}) (interface{}, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	var generic interface{}
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, err
+	}
+	return stringifyNumbers(generic), nil
+}
+
+// stringifyNumbers - replaces numbers within generic JSON value recursively.
+func stringifyNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		return v.String()
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = stringifyNumbers(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = stringifyNumbers(item)
+		}
+	}
+	return value
+}
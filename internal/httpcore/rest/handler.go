@@ -1,25 +1,67 @@
 package rest
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/wtask-go/auracounter/internal/httpcore"
 	"github.com/wtask-go/auracounter/internal/httpcore/response"
 
 	"github.com/wtask-go/auracounter/internal/api"
 
 	"github.com/gorilla/mux"
+
+	"github.com/wtask-go/auracounter/pkg/logging"
 )
 
+// handlerOption - enables optional feature of the handler
+type handlerOption func(*handlerOptions)
+
+// handlerOptions - optional features of the handler
+type handlerOptions struct {
+	events         api.CounterEventSource
+	streamLifetime time.Duration
+}
+
+// WithEventStream - enables `/counters/{id}/events/` route, which streams changes of the counter
+// from given source as Server-Sent Events. Every stream is closed after `lifetime`,
+// EventSource clients reconnect automatically. Streams are not limited with write timeout of HTTP server
+// when it is served with httpcore.WriteDeadlines, the deadline is extended before every write of the stream.
+// When the source implements api.CounterEventHistory, events are sent with IDs
+// and reconnected client receives the events it missed (see `Last-Event-ID` header).
+func WithEventStream(source api.CounterEventSource, lifetime time.Duration) handlerOption {
+	if source == nil {
+		panic(errors.New("rest.WithEventStream: CounterEventSource is not implemented"))
+	}
+	if lifetime <= 0 {
+		panic(fmt.Errorf("rest.WithEventStream: invalid lifetime (%s)", lifetime))
+	}
+	return func(o *handlerOptions) {
+		o.events = source
+		o.streamLifetime = lifetime
+	}
+}
+
 // NewCounterHandler - builds main http handler for api.CounterService implementation.
-// If there is no a plan to log requests and responses, pass Logger as nil,
-// otherwise make an adapter to expose rest.Logger interface.
-func NewCounterHandler(baseURI string, service api.CyclicCounterService, l Logger) http.Handler {
+// All counters are addressed by ID within URI, see `/counters/{id}/...` routes.
+// Routes of the second API version are served under v2 base URI, see `v2BaseURI`.
+// If there is no a plan to log requests and responses, pass logging.Printer as nil,
+// otherwise make an adapter to expose logging.Printer interface.
+func NewCounterHandler(baseURI string, service api.CyclicCounterService, l logging.Printer, options ...handlerOption) http.Handler {
 	if service == nil {
 		panic(errors.New("rest.NewHandler: CounterService is not implemented"))
 	}
+	o := &handlerOptions{}
+	for _, option := range options {
+		if option != nil {
+			option(o)
+		}
+	}
 	r := mux.NewRouter()
 	r.NotFoundHandler = handleNotFound(l)
 	r.MethodNotAllowedHandler = handleMethodNotAllowed(l)
@@ -29,25 +71,76 @@ func NewCounterHandler(baseURI string, service api.CyclicCounterService, l Logge
 		// v1.Use(logMiddleware())
 
 		v1.NewRoute().
-			Path("/getnumber/").
+			Path("/counters/{id:[0-9]+}/getnumber/").
 			Methods("GET").
 			HandlerFunc(handleGetCounterValue(service, l))
 
 		v1.NewRoute().
-			Path("/incrementnumber/").
+			Path("/counters/{id:[0-9]+}/incrementnumber/").
 			Methods("POST").
 			HandlerFunc(handleIncreaseCounter(service, l))
 
 		v1.NewRoute().
-			Path("/setsettings/{increment:[0-9]+}/{upper:[0-9]+}/").
+			Path("/counters/{id:[0-9]+}/decrementnumber/").
+			Methods("POST").
+			HandlerFunc(handleDecreaseCounter(service, l))
+
+		v1.NewRoute().
+			Path("/counters/{id:[0-9]+}/resetnumber/").
+			Methods("POST").
+			HandlerFunc(handleResetCounter(service, l))
+
+		v1.NewRoute().
+			Path("/counters/{id:[0-9]+}/reservenumbers/{size:[0-9]+}/").
+			Methods("POST").
+			HandlerFunc(handleReserveCounterBlock(service, l))
+
+		v1.NewRoute().
+			Path("/counters/{id:[0-9]+}/setsettings/{increment:[0-9]+}/{upper:[0-9]+}/").
 			Methods("PUT").
 			HandlerFunc(handleSetSettings(service, l))
+
+		v1.NewRoute().
+			Path("/counters/{id:[0-9]+}/audit/").
+			Methods("GET").
+			HandlerFunc(handleGetAudit(service, l))
+
+		if o.events != nil {
+			v1.NewRoute().
+				Path("/counters/{id:[0-9]+}/events/").
+				Methods("GET").
+				HandlerFunc(handleEvents(service, o.events, o.streamLifetime, l))
+		}
+	}
+
+	{
+		v2 := r.PathPrefix(v2BaseURI(baseURI)).Subrouter()
+
+		v2.NewRoute().
+			Path("/counters/{id:[0-9]+}/settings/").
+			Methods("GET").
+			HandlerFunc(handleGetSettings(service, l))
+
+		v2.NewRoute().
+			Path("/counters/{id:[0-9]+}/settings/").
+			Methods("PUT").
+			HandlerFunc(handleUpdateSettings(service, l))
 	}
 
 	// return logRequestMiddleware(l, r)
 	return r
 }
 
+// v2BaseURI - derives base URI of the second API version from the base URI of the first one:
+// trailing `v1/` is replaced with `v2/` (`/counter/v1/` -> `/counter/v2/`), otherwise `v2/` is appended.
+func v2BaseURI(baseURI string) string {
+	base := strings.TrimSuffix(baseURI, "/")
+	if strings.HasSuffix(base, "/v1") || base == "v1" {
+		return strings.TrimSuffix(base, "v1") + "v2/"
+	}
+	return base + "/v2/"
+}
+
 func httpStatusFactory(err error) int {
 	switch e := err.(type) {
 	case nil:
@@ -59,108 +152,530 @@ func httpStatusFactory(err error) int {
 		if e.IsInternal() {
 			return http.StatusInternalServerError
 		}
+		switch e.Code {
+		case api.CounterOverflowCode:
+			return http.StatusConflict
+		case api.VersionMismatchCode:
+			return http.StatusPreconditionFailed
+		}
 		return http.StatusBadRequest
 	default:
 		return http.StatusServiceUnavailable
 	}
 }
 
-func handleGetCounterValue(service api.CyclicCounterService, l Logger) http.HandlerFunc {
+// handleFail - logs error and responds with failure description.
+// Code of client API error is exposed within description.
+func handleFail(w http.ResponseWriter, r *http.Request, l logging.Printer, status int, message string, err error) {
+	logging.PrintError(l, status, httpcore.FormatRequest(r), logging.QuoteError(err))
+	code := 0
+	if e, ok := err.(*api.Error); ok && e != nil {
+		code = e.Code
+	}
+	response.HandleJSON(
+		status,
+		&response.Fail{Error: response.ErrorDescription{Code: code, Message: message}},
+	)(w, r)
+}
+
+// handleSuccess - logs request and responds with result.
+// Numbers within result are encoded as strings when client passes `numbers=string` query parameter.
+func handleSuccess(w http.ResponseWriter, r *http.Request, l logging.Printer, status int, result interface{}) {
+	if r.URL.Query().Get("numbers") == "string" {
+		stringified, err := response.NumbersAsStrings(result)
+		if err != nil {
+			handleFail(w, r, l, http.StatusInternalServerError, "Failed to encode result", err)
+			return
+		}
+		result = stringified
+	}
+	logging.PrintInfo(l, status, httpcore.FormatRequest(r))
+	response.HandleJSON(status, &response.Success{Result: result})(w, r)
+}
+
+// Headers of idempotent increase
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
+	// replayedHeader - is set in response when the result of previous request with the same key is returned
+	replayedHeader = "Idempotent-Replayed"
+)
+
+// settingsETag - formats version of counter settings as entity tag.
+func settingsETag(version int) string {
+	return fmt.Sprintf("%q", strconv.Itoa(version))
+}
+
+// ifMatchVersion - extracts expected version of counter settings from `If-Match` header.
+// Zero version is returned when the header is omitted or matches any version (`*`).
+// Error is returned when the header does not contain single strong entity tag of settings,
+// such header can not match any version.
+func ifMatchVersion(r *http.Request) (int, error) {
+	tag := strings.TrimSpace(r.Header.Get("If-Match"))
+	if tag == "" || tag == "*" {
+		return 0, nil
+	}
+	unquoted, err := strconv.Unquote(tag)
+	if err != nil || !strings.HasPrefix(tag, `"`) {
+		return 0, fmt.Errorf("unsupported If-Match (%s)", tag)
+	}
+	version, err := strconv.Atoi(unquoted)
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("unknown settings version (%s)", tag)
+	}
+	return version, nil
+}
+
+// counterID - extracts counter ID from request URI.
+func counterID(r *http.Request) (int, error) {
+	return strconv.Atoi(mux.Vars(r)["id"])
+}
+
+func handleGetCounterValue(service api.CyclicCounterService, l logging.Printer) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		result, apiErr := service.GetCounterValue()
+		id, err := counterID(r)
+		if err != nil {
+			handleFail(w, r, l, http.StatusBadRequest, "Invalid or bad counter ID", err)
+			return
+		}
+		result, apiErr := service.GetCounterValue(id)
 		status := httpStatusFactory(apiErr)
 		if apiErr != nil {
-			logError(l, status, formatRequest(r), formatError(apiErr.ExposeError()))
-			response.HandleJSON(
-				status,
-				&response.Fail{response.ErrorDescription{0, fmt.Sprint(apiErr)}},
-			)(w, r)
+			handleFail(w, r, l, status, fmt.Sprint(apiErr), apiErr.ExposeError())
 			return
 		}
-		logInfo(l, status, formatRequest(r))
-		response.HandleJSON(status, &response.Success{Result: result})(w, r)
+		handleSuccess(w, r, l, status, result)
 	}
 }
 
-func handleIncreaseCounter(service api.CyclicCounterService, l Logger) http.HandlerFunc {
+func handleIncreaseCounter(service api.CyclicCounterService, l logging.Printer) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		result, apiErr := service.IncreaseCounter()
+		id, err := counterID(r)
+		if err != nil {
+			handleFail(w, r, l, http.StatusBadRequest, "Invalid or bad counter ID", err)
+			return
+		}
+		var (
+			result *api.IntValueResult
+			apiErr *api.Error
+		)
+		service := service.WithCaller(httpcore.CallerOf(w, r))
+		if key := r.Header.Get(idempotencyKeyHeader); key != "" {
+			result, apiErr = service.IncreaseCounterOnce(id, key)
+		} else {
+			result, apiErr = service.IncreaseCounter(id)
+		}
 		status := httpStatusFactory(apiErr)
 		if apiErr != nil {
-			logError(l, status, formatRequest(r), formatError(apiErr.ExposeError()))
-			response.HandleJSON(
-				status,
-				&response.Fail{response.ErrorDescription{0, fmt.Sprint(apiErr)}},
-			)(w, r)
+			handleFail(w, r, l, status, fmt.Sprint(apiErr), apiErr.ExposeError())
 			return
 		}
-		logInfo(l, status, formatRequest(r))
-		response.HandleJSON(status, &response.Success{Result: result})(w, r)
+		if result.Replayed {
+			w.Header().Set(replayedHeader, "true")
+		}
+		handleSuccess(w, r, l, status, result)
 	}
 }
 
-func handleSetSettings(service api.CyclicCounterService, l Logger) http.HandlerFunc {
+func handleDecreaseCounter(service api.CyclicCounterService, l logging.Printer) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		increment, err := strconv.Atoi(mux.Vars(r)["increment"])
+		id, err := counterID(r)
 		if err != nil {
-			logError(l, http.StatusBadRequest, formatRequest(r), formatError(err))
-			response.HandleJSON(
-				http.StatusBadRequest,
-				&response.Fail{response.ErrorDescription{0, fmt.Sprint("Invalid or bad increment")}},
-			)(w, r)
+			handleFail(w, r, l, http.StatusBadRequest, "Invalid or bad counter ID", err)
+			return
+		}
+		result, apiErr := service.WithCaller(httpcore.CallerOf(w, r)).DecreaseCounter(id)
+		status := httpStatusFactory(apiErr)
+		if apiErr != nil {
+			handleFail(w, r, l, status, fmt.Sprint(apiErr), apiErr.ExposeError())
 			return
 		}
-		upper, err := strconv.Atoi(mux.Vars(r)["upper"])
+		handleSuccess(w, r, l, status, result)
+	}
+}
+
+func handleResetCounter(service api.CyclicCounterService, l logging.Printer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := counterID(r)
 		if err != nil {
-			logError(l, http.StatusBadRequest, formatRequest(r), formatError(err))
-			response.HandleJSON(
-				http.StatusBadRequest,
-				&response.Fail{response.ErrorDescription{0, fmt.Sprint("Invalid or bad upper limit value")}},
-			)(w, r)
+			handleFail(w, r, l, http.StatusBadRequest, "Invalid or bad counter ID", err)
 			return
 		}
-		// TODO Change URI to allow 3 parameters
-		result, apiErr := service.SetCounterSettings(increment, 0, upper)
+		result, apiErr := service.WithCaller(httpcore.CallerOf(w, r)).ResetCounter(id)
 		status := httpStatusFactory(apiErr)
 		if apiErr != nil {
-			logError(l, status, formatRequest(r), formatError(apiErr.ExposeError()))
-			response.HandleJSON(
-				status,
-				&response.Fail{response.ErrorDescription{0, fmt.Sprint(apiErr)}},
-			)(w, r)
+			handleFail(w, r, l, status, fmt.Sprint(apiErr), apiErr.ExposeError())
+			return
+		}
+		handleSuccess(w, r, l, status, result)
+	}
+}
+
+func handleReserveCounterBlock(service api.CyclicCounterService, l logging.Printer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := counterID(r)
+		if err != nil {
+			handleFail(w, r, l, http.StatusBadRequest, "Invalid or bad counter ID", err)
+			return
+		}
+		size, err := strconv.Atoi(mux.Vars(r)["size"])
+		if err != nil {
+			handleFail(w, r, l, http.StatusBadRequest, "Invalid or bad block size", err)
+			return
+		}
+		result, apiErr := service.WithCaller(httpcore.CallerOf(w, r)).ReserveCounterBlock(id, size)
+		status := httpStatusFactory(apiErr)
+		if apiErr != nil {
+			handleFail(w, r, l, status, fmt.Sprint(apiErr), apiErr.ExposeError())
+			return
+		}
+		handleSuccess(w, r, l, status, result)
+	}
+}
+
+func handleSetSettings(service api.CyclicCounterService, l logging.Printer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := counterID(r)
+		if err != nil {
+			handleFail(w, r, l, http.StatusBadRequest, "Invalid or bad counter ID", err)
+			return
+		}
+		increment, err := strconv.ParseInt(mux.Vars(r)["increment"], 10, 64)
+		if err != nil {
+			handleFail(w, r, l, http.StatusBadRequest, "Invalid or bad increment", err)
+			return
+		}
+		upper, err := strconv.ParseInt(mux.Vars(r)["upper"], 10, 64)
+		if err != nil {
+			handleFail(w, r, l, http.StatusBadRequest, "Invalid or bad upper limit value", err)
 			return
 		}
-		logInfo(l, status, formatRequest(r))
-		response.HandleJSON(status, &response.Success{Result: result})(w, r)
+		version, err := ifMatchVersion(r)
+		if err != nil {
+			handleFail(w, r, l, http.StatusPreconditionFailed, fmt.Sprintf("Precondition failed: %s", err), err)
+			return
+		}
+		// lower limit is always 0 for this route, use v2 `/counters/{id}/settings/` to set all settings
+		result, apiErr := service.WithCaller(httpcore.CallerOf(w, r)).SetCounterSettings(id, increment, 0, upper, version)
+		status := httpStatusFactory(apiErr)
+		if apiErr != nil {
+			handleFail(w, r, l, status, fmt.Sprint(apiErr), apiErr.ExposeError())
+			return
+		}
+		w.Header().Set("ETag", settingsETag(result.Version))
+		handleSuccess(w, r, l, status, result)
+	}
+}
+
+func handleGetSettings(service api.CyclicCounterService, l logging.Printer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := counterID(r)
+		if err != nil {
+			handleFail(w, r, l, http.StatusBadRequest, "Invalid or bad counter ID", err)
+			return
+		}
+		result, apiErr := service.GetCounterSettings(id)
+		status := httpStatusFactory(apiErr)
+		if apiErr != nil {
+			handleFail(w, r, l, status, fmt.Sprint(apiErr), apiErr.ExposeError())
+			return
+		}
+		w.Header().Set("ETag", settingsETag(result.Version))
+		handleSuccess(w, r, l, status, result)
+	}
+}
+
+// int64Value - int64 which is decoded from JSON number or string,
+// so clients without int64 support are able to pass exact values.
+type int64Value int64
+
+// UnmarshalJSON - implements json.Unmarshaler interface.
+func (v *int64Value) UnmarshalJSON(data []byte) error {
+	text := string(data)
+	if len(text) > 1 && strings.HasPrefix(text, `"`) && strings.HasSuffix(text, `"`) {
+		text = text[1 : len(text)-1]
+	}
+	n, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid int64 value %s", data)
+	}
+	*v = int64Value(n)
+	return nil
+}
+
+// settingsRequest - JSON body of settings update request.
+// Increment, lower and upper are required, start value is equal to lower when omitted
+// or to upper for descending counter.
+type settingsRequest struct {
+	Increment *int64Value `json:"increment"`
+	Lower     *int64Value `json:"lower"`
+	Upper     *int64Value `json:"upper"`
+	StartFrom *int64Value `json:"start_from"`
+	Overflow  string      `json:"overflow"`
+	ValueMode string      `json:"value_mode"`
+}
+
+// settings - checks required fields and converts request into api.CounterSettings.
+func (req *settingsRequest) settings() (*api.CounterSettings, error) {
+	switch {
+	case req.Increment == nil:
+		return nil, errors.New("increment is required")
+	case req.Lower == nil:
+		return nil, errors.New("lower is required")
+	case req.Upper == nil:
+		return nil, errors.New("upper is required")
+	}
+	settings := &api.CounterSettings{
+		Increment: int64(*req.Increment),
+		Lower:     int64(*req.Lower),
+		Upper:     int64(*req.Upper),
+		StartFrom: int64(*req.Lower),
+		Overflow:  req.Overflow,
+	}
+	if settings.Increment < 0 {
+		settings.StartFrom = settings.Upper
+	}
+	if req.StartFrom != nil {
+		settings.StartFrom = int64(*req.StartFrom)
+	}
+	return settings, nil
+}
+
+func handleUpdateSettings(service api.CyclicCounterService, l logging.Printer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := counterID(r)
+		if err != nil {
+			handleFail(w, r, l, http.StatusBadRequest, "Invalid or bad counter ID", err)
+			return
+		}
+		req := &settingsRequest{}
+		decoder := json.NewDecoder(r.Body)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(req); err != nil {
+			handleFail(w, r, l, http.StatusBadRequest, "Invalid or bad settings", err)
+			return
+		}
+		settings, err := req.settings()
+		if err != nil {
+			handleFail(w, r, l, http.StatusBadRequest, fmt.Sprintf("Invalid or bad settings: %s", err), err)
+			return
+		}
+		version, err := ifMatchVersion(r)
+		if err != nil {
+			handleFail(w, r, l, http.StatusPreconditionFailed, fmt.Sprintf("Precondition failed: %s", err), err)
+			return
+		}
+		result, apiErr := service.WithCaller(httpcore.CallerOf(w, r)).UpdateCounterSettings(id, settings, req.ValueMode, version)
+		status := httpStatusFactory(apiErr)
+		if apiErr != nil {
+			handleFail(w, r, l, status, fmt.Sprint(apiErr), apiErr.ExposeError())
+			return
+		}
+		w.Header().Set("ETag", settingsETag(result.Version))
+		handleSuccess(w, r, l, status, result)
+	}
+}
+
+// defaultAuditLimit - audit page size when it is omitted
+const defaultAuditLimit = 100
+
+// auditPageParams - extracts optional `after` and `limit` query parameters of audit page,
+// the range of page size is checked by the service.
+func auditPageParams(r *http.Request) (after int64, limit int, err error) {
+	query := r.URL.Query()
+	if v := query.Get("after"); v != "" {
+		if after, err = strconv.ParseInt(v, 10, 64); err != nil || after < 0 {
+			return 0, 0, fmt.Errorf("invalid after (%q)", v)
+		}
+	}
+	limit = defaultAuditLimit
+	if v := query.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil {
+			return 0, 0, fmt.Errorf("invalid limit (%q)", v)
+		}
+	}
+	return after, limit, nil
+}
+
+func handleGetAudit(service api.CyclicCounterService, l logging.Printer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := counterID(r)
+		if err != nil {
+			handleFail(w, r, l, http.StatusBadRequest, "Invalid or bad counter ID", err)
+			return
+		}
+		after, limit, err := auditPageParams(r)
+		if err != nil {
+			handleFail(w, r, l, http.StatusBadRequest, fmt.Sprintf("Invalid or bad page: %s", err), err)
+			return
+		}
+		result, apiErr := service.GetCounterAudit(id, after, limit)
+		status := httpStatusFactory(apiErr)
+		if apiErr != nil {
+			handleFail(w, r, l, status, fmt.Sprint(apiErr), apiErr.ExposeError())
+			return
+		}
+		handleSuccess(w, r, l, status, result)
+	}
+}
+
+// Server-Sent Events params
+const (
+	// eventQueueSize - how many events are queued for single stream, slow client is disconnected on overflow
+	eventQueueSize = 64
+	// keepAliveInterval - how often the comment is sent into idle stream to keep connection alive,
+	// it is shortened to the half of stream lifetime for short-lived streams
+	keepAliveInterval = 15 * time.Second
+	// reconnectDelay - how long EventSource client waits before reconnect
+	reconnectDelay = time.Second
+)
+
+// writeEvent - writes counter event into the stream in SSE format, event type is used as the name of event.
+// Not negative `id` is written as the ID of SSE event.
+func writeEvent(w http.ResponseWriter, id int64, event api.CounterEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if id >= 0 {
+		if _, err := fmt.Fprintf(w, "id: %d\n", id); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+	return err
+}
+
+// lastEventID - returns ID of the latest event received by reconnected client or -1 when it is unknown.
+func lastEventID(r *http.Request) int64 {
+	id, err := strconv.ParseInt(r.Header.Get("Last-Event-ID"), 10, 64)
+	if err != nil || id < 0 {
+		return -1
+	}
+	return id
+}
+
+// subscribe - subscribes to events of the counter, the subscription is resumed after the latest event of client
+// when source keeps history of events. `last` is ID of the latest published event,
+// it is -1 when events have no IDs. `resumed` is false when client has to receive current state of the counter.
+func subscribe(source api.CounterEventSource, counterID int, r *http.Request) (<-chan api.CounterEvent, func(), int64, bool) {
+	history, ok := source.(api.CounterEventHistory)
+	if !ok {
+		events, cancel := source.SubscribeCounterEvents(counterID, eventQueueSize)
+		return events, cancel, -1, false
+	}
+	return history.ResumeCounterEvents(counterID, eventQueueSize, lastEventID(r))
+}
+
+func handleEvents(service api.CyclicCounterService, source api.CounterEventSource, lifetime time.Duration, l logging.Printer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := counterID(r)
+		if err != nil {
+			handleFail(w, r, l, http.StatusBadRequest, "Invalid or bad counter ID", err)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			handleFail(w, r, l, http.StatusInternalServerError, "Streaming is not supported", errors.New("http.Flusher is not implemented"))
+			return
+		}
+		events, cancel, last, resumed := subscribe(source, id, r)
+		defer cancel()
+		var current *api.IntValueResult
+		if !resumed {
+			// current value is sent first, client is subscribed already, so the changes after it are not missed
+			result, apiErr := service.GetCounterValue(id)
+			if apiErr != nil {
+				status := httpStatusFactory(apiErr)
+				handleFail(w, r, l, status, fmt.Sprint(apiErr), apiErr.ExposeError())
+				return
+			}
+			current = result
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		logging.PrintInfo(l, http.StatusOK, httpcore.FormatRequest(r))
+		httpcore.ExtendWriteDeadline(r)
+		fmt.Fprintf(w, "retry: %d\n\n", reconnectDelay/time.Millisecond)
+		if current != nil {
+			// the state includes all events up to the latest published one, so client resumes after it
+			event := api.CounterEvent{
+				Type:      api.ValueEvent,
+				CounterID: id,
+				Time:      time.Now().UTC(),
+				Value:     current.Value,
+				Cycle:     current.Cycle,
+			}
+			if err := writeEvent(w, last, event); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+
+		expired := time.NewTimer(lifetime)
+		defer expired.Stop()
+		interval := keepAliveInterval
+		if lifetime/2 < interval {
+			interval = lifetime / 2
+		}
+		keepAlive := time.NewTicker(interval)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					// client does not keep up or the source is closed
+					return
+				}
+				id := event.ID
+				if last < 0 {
+					id = -1
+				}
+				httpcore.ExtendWriteDeadline(r)
+				if err := writeEvent(w, id, event); err != nil {
+					return
+				}
+			case <-keepAlive.C:
+				httpcore.ExtendWriteDeadline(r)
+				if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+					return
+				}
+			case <-expired.C:
+				return
+			case <-r.Context().Done():
+				return
+			}
+			flusher.Flush()
+		}
 	}
 }
 
-func handleNotFound(l Logger) http.HandlerFunc {
+func handleNotFound(l logging.Printer) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		status := http.StatusNotFound
-		logError(l, status, formatRequest(r))
+		logging.PrintError(l, status, httpcore.FormatRequest(r))
 		response.HandleJSON(
 			status,
-			&response.Fail{response.ErrorDescription{0, "Not Found"}},
+			&response.Fail{Error: response.ErrorDescription{Message: "Not Found"}},
 		)(w, r)
 	}
 }
 
-func handleMethodNotAllowed(l Logger) http.HandlerFunc {
+func handleMethodNotAllowed(l logging.Printer) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		status := http.StatusMethodNotAllowed
-		logError(l, status, formatRequest(r))
+		logging.PrintError(l, status, httpcore.FormatRequest(r))
 		// NOTE If the reason for this handler is HEAD request - gorilla.mux will not send response body to client!
 		response.HandleJSON(
 			status,
-			&response.Fail{response.ErrorDescription{0, fmt.Sprintf("Method Not Allowed (%s)", r.Method)}},
+			&response.Fail{Error: response.ErrorDescription{Message: fmt.Sprintf("Method Not Allowed (%s)", r.Method)}},
 		)(w, r)
 	}
 }
 
-func logRequestMiddleware(l Logger, next http.Handler) http.Handler {
+func logRequestMiddleware(l logging.Printer, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		logInfo(l, formatRequest(r))
+		logging.PrintInfo(l, httpcore.FormatRequest(r))
 		next.ServeHTTP(w, r)
 	})
 }
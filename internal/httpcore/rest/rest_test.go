@@ -0,0 +1,149 @@
+package rest
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wtask-go/auracounter/internal/counter"
+	"github.com/wtask-go/auracounter/internal/counter/datastore/memory"
+	"github.com/wtask-go/auracounter/internal/httpcore"
+)
+
+func TestV2BaseURI(t *testing.T) {
+	cases := []struct {
+		baseURI, expected string
+	}{
+		{"/counter/v1/", "/counter/v2/"},
+		{"/counter/v1", "/counter/v2/"},
+		{"/v1/", "/v2/"},
+		{"/", "/v2/"},
+		{"/api/", "/api/v2/"},
+		{"/apiv1/", "/apiv1/v2/"},
+	}
+	for _, c := range cases {
+		if actual := v2BaseURI(c.baseURI); actual != c.expected {
+			t.Errorf("v2BaseURI(%q): expected %q, got %q", c.baseURI, c.expected, actual)
+		}
+	}
+}
+
+func TestCounterHandler_V2Settings(t *testing.T) {
+	service, err := counter.NewCyclicCounterService(memory.NewStorage().Repository())
+	if err != nil {
+		t.Fatalf("Unable to build counter service: %v", err)
+	}
+	handler := NewCounterHandler("/counter/v1/", service, nil)
+
+	cases := []struct {
+		method, uri, body string
+		status            int
+	}{
+		{"PUT", "/counter/v2/counters/1/settings/", `{"increment": 2, "lower": 1, "upper": 9}`, http.StatusOK},
+		{"GET", "/counter/v2/counters/1/settings/", "", http.StatusOK},
+		{"GET", "/counter/v1/counters/1/settings/", "", http.StatusNotFound},
+		{"PUT", "/counter/v1/counters/1/settings/", `{"increment": 2, "lower": 1, "upper": 9}`, http.StatusNotFound},
+		{"GET", "/counter/v1/counters/1/getnumber/", "", http.StatusOK},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(c.method, c.uri, strings.NewReader(c.body)))
+		if w.Code != c.status {
+			t.Errorf("%s %s: expected status %d, got %d (%s)", c.method, c.uri, c.status, w.Code, w.Body)
+		}
+	}
+	settings, _ := service.GetCounterSettings(1)
+	if settings == nil || settings.Increment != 2 || settings.Lower != 1 || settings.Upper != 9 {
+		t.Errorf("Unexpected settings after v2 PUT: %+v", settings)
+	}
+}
+
+// readStream - reads lines of SSE stream until the empty line (`events` times) or until the stream is closed.
+func readStream(t *testing.T, r *bufio.Reader, events int) []string {
+	lines := []string{}
+	for events != 0 {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			break
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			events--
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestCounterHandler_Events(t *testing.T) {
+	bus := counter.NewEventBus()
+	service, err := counter.NewCyclicCounterService(memory.NewStorage().Repository(), counter.WithEventBus(bus))
+	if err != nil {
+		t.Fatalf("Unable to build counter service: %v", err)
+	}
+	service.IncreaseCounter(1)
+	// stream lives longer than write timeout, keep-alive comments extend the deadline
+	deadlines := httpcore.NewWriteDeadlines(100 * time.Millisecond)
+	server := httptest.NewUnstartedServer(deadlines.Handler(
+		NewCounterHandler("/counter/v1/", service, nil, WithEventStream(bus, 500*time.Millisecond)),
+	))
+	server.Config.ConnState = deadlines.ConnState
+	server.Start()
+	defer server.Close()
+
+	connect := func(lastEventID string) *bufio.Reader {
+		request, _ := http.NewRequest("GET", server.URL+"/counter/v1/counters/1/events/", nil)
+		if lastEventID != "" {
+			request.Header.Set("Last-Event-ID", lastEventID)
+		}
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("Unable to connect: %v", err)
+		}
+		if response.StatusCode != http.StatusOK || response.Header.Get("Content-Type") != "text/event-stream" {
+			t.Fatalf("Unexpected response: %s %s", response.Status, response.Header.Get("Content-Type"))
+		}
+		return bufio.NewReader(response.Body)
+	}
+
+	t.Log("Case: the first connection")
+	stream := connect("")
+	lines := readStream(t, stream, 2)
+	if len(lines) != 4 || lines[0] != "retry: 1000" || lines[1] != "id: 1" || lines[2] != "event: value" ||
+		!strings.Contains(lines[3], `"value":1}`) {
+		t.Fatalf("Expected retry and current value, got %q", lines)
+	}
+	service.IncreaseCounter(1)
+	started := time.Now()
+	lines = readStream(t, stream, -1)
+	if time.Since(started) < 300*time.Millisecond {
+		t.Errorf("Stream was closed before its lifetime")
+	}
+	if len(lines) < 4 || lines[0] != "id: 2" || !strings.Contains(lines[2], `"operation":"increase"`) {
+		t.Fatalf("Expected increase event, got %q", lines)
+	}
+	if lines[len(lines)-1] != ": keep-alive" {
+		t.Errorf("Expected keep-alive comments, got %q", lines)
+	}
+
+	t.Log("Case: reconnection after missed events")
+	service.IncreaseCounter(1)
+	service.DecreaseCounter(1)
+	lines = readStream(t, connect("2"), 3)
+	expected := []string{"retry: 1000", "id: 3", "event: value", "id: 4", "event: value"}
+	if len(lines) != 7 || lines[0] != expected[0] || lines[1] != expected[1] || lines[4] != expected[3] ||
+		!strings.Contains(lines[6], `"operation":"decrease"`) {
+		t.Errorf("Expected missed events %q, got %q", expected, lines)
+	}
+
+	t.Log("Case: reconnection with unknown event")
+	lines = readStream(t, connect("100"), 2)
+	if len(lines) != 4 || lines[1] != "id: 4" || !strings.Contains(lines[3], `"value":2}`) ||
+		strings.Contains(lines[3], "operation") {
+		t.Errorf("Expected current value, got %q", lines)
+	}
+}
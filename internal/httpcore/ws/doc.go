@@ -0,0 +1,5 @@
+/*
+Package ws define public API of counter service over WebSocket,
+so browser clients are able to subscribe to changes of counters and to change them over the same connection.
+*/
+package ws
@@ -0,0 +1,321 @@
+package ws
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/wtask-go/auracounter/internal/api"
+	"github.com/wtask-go/auracounter/internal/httpcore"
+	"github.com/wtask-go/auracounter/internal/httpcore/response"
+
+	"github.com/wtask-go/auracounter/pkg/logging"
+)
+
+const (
+	// MaxSubscriptions - the largest number of counters which single connection is subscribed to
+	MaxSubscriptions = 100
+	// maxMessageSize - the largest size of client message in bytes
+	maxMessageSize = 4096
+	// queueSize - how many messages are queued for the client,
+	// commands are not read while the queue is full and the connection is closed when there is no room for event
+	queueSize = 64
+	// eventQueueSize - how many events of single subscription are queued before they are passed to the client queue
+	eventQueueSize = 16
+	// writeTimeout - time allowed to write single message to the client
+	writeTimeout = 10 * time.Second
+	// pongTimeout - time allowed to read the next pong from the client
+	pongTimeout = 60 * time.Second
+	// pingInterval - how often the client is pinged, it must be less than pongTimeout
+	pingInterval = pongTimeout * 9 / 10
+	// closeTimeout - time allowed to the client to answer the close message
+	closeTimeout = time.Second
+)
+
+// command - client message, `id` is any JSON value, it is returned within the reply of command.
+type command struct {
+	ID             json.RawMessage `json:"id"`
+	Command        string          `json:"command"`
+	CounterID      int             `json:"counter_id"`
+	IdempotencyKey string          `json:"idempotency_key"`
+}
+
+// message - server message, result and error have the same shapes as for REST API.
+// Reply of command has ID of the command, pushed event has type of the event.
+type message struct {
+	ID    json.RawMessage `json:"id,omitempty"`
+	Event string          `json:"event,omitempty"`
+	*response.Success
+	*response.Fail
+}
+
+// okResult - result of command which does not return any data
+type okResult struct {
+	OK bool `json:"ok"`
+}
+
+// NewCounterHandler - builds http handler, which upgrades connection to WebSocket
+// and serves commands of the client for api.CounterService implementation, see `execute`.
+// Handler serves requests of any path, so it can be mounted under any URI.
+// Numbers within messages are encoded as strings when client passes `numbers=string` query parameter.
+// If there is no a plan to log commands, pass logging.Printer as nil,
+// otherwise make an adapter to expose logging.Printer interface.
+func NewCounterHandler(service api.CyclicCounterService, events api.CounterEventSource, l logging.Printer) http.Handler {
+	if service == nil {
+		panic(errors.New("ws.NewCounterHandler: CounterService is not implemented"))
+	}
+	if events == nil {
+		panic(errors.New("ws.NewCounterHandler: CounterEventSource is not implemented"))
+	}
+	upgrader := &websocket.Upgrader{ReadBufferSize: 1024, WriteBufferSize: 1024}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		caller := httpcore.CallerOf(w, r)
+		conn, err := upgrader.Upgrade(w, r, w.Header())
+		if err != nil {
+			// upgrader has already responded with error
+			logging.PrintError(l, http.StatusBadRequest, httpcore.FormatRequest(r), logging.QuoteError(err))
+			return
+		}
+		logging.PrintInfo(l, http.StatusSwitchingProtocols, httpcore.FormatRequest(r))
+		s := &session{
+			service:       service.WithCaller(caller),
+			events:        events,
+			l:             l,
+			request:       httpcore.FormatRequest(r),
+			stringify:     r.URL.Query().Get("numbers") == "string",
+			conn:          conn,
+			out:           make(chan *message, queueSize),
+			done:          make(chan struct{}),
+			subscriptions: map[int]*subscription{},
+		}
+		s.serve()
+	})
+}
+
+// session - state of single client connection
+type session struct {
+	service   api.CyclicCounterService
+	events    api.CounterEventSource
+	l         logging.Printer
+	request   string // formatted upgrade request to log commands
+	stringify bool   // numbers are encoded as strings
+	conn      *websocket.Conn
+	out       chan *message
+	// done - is closed when the session is stopped, see `stop`
+	done         chan struct{}
+	stopOnce     sync.Once
+	closeMessage []byte
+
+	mx            sync.Mutex
+	subscriptions map[int]*subscription
+}
+
+// subscription - events of single counter
+type subscription struct {
+	events <-chan api.CounterEvent
+	cancel func()
+}
+
+// serve - reads and executes commands until the client or the server closes the connection.
+func (s *session) serve() {
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		s.write()
+	}()
+	s.read()
+	s.stop(websocket.CloseNormalClosure, "")
+	s.mx.Lock()
+	for id, sub := range s.subscriptions {
+		delete(s.subscriptions, id)
+		sub.cancel()
+	}
+	s.mx.Unlock()
+	<-written
+	s.conn.Close()
+}
+
+// stop - stops the session, connection is closed with given code and reason.
+// Only the first call takes effect.
+func (s *session) stop(code int, reason string) {
+	s.stopOnce.Do(func() {
+		s.closeMessage = websocket.FormatCloseMessage(code, reason)
+		close(s.done)
+	})
+}
+
+// read - executes client commands, reply is queued before the next command is read,
+// so the client which does not read replies is not able to send commands too.
+func (s *session) read() {
+	s.conn.SetReadLimit(maxMessageSize)
+	s.conn.SetReadDeadline(time.Now().Add(pongTimeout))
+	s.conn.SetPongHandler(func(string) error {
+		select {
+		case <-s.done:
+			return nil
+		default:
+			return s.conn.SetReadDeadline(time.Now().Add(pongTimeout))
+		}
+	})
+	for {
+		_, data, err := s.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		reply := s.execute(data)
+		select {
+		case s.out <- reply:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// write - writes queued messages and pings the client until the session is stopped.
+func (s *session) write() {
+	ping := time.NewTicker(pingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case m := <-s.out:
+			s.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := s.conn.WriteJSON(m); err != nil {
+				// reading is interrupted too
+				s.conn.Close()
+				return
+			}
+		case <-ping.C:
+			if err := s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout)); err != nil {
+				s.conn.Close()
+				return
+			}
+		case <-s.done:
+			s.conn.WriteControl(websocket.CloseMessage, s.closeMessage, time.Now().Add(writeTimeout))
+			// client is expected to answer the close message, then reading fails
+			s.conn.SetReadDeadline(time.Now().Add(closeTimeout))
+			return
+		}
+	}
+}
+
+// reply - builds message with result, numbers are encoded as strings if client asked it.
+func (s *session) reply(id json.RawMessage, event string, result interface{}) *message {
+	if s.stringify {
+		stringified, err := response.NumbersAsStrings(result)
+		if err != nil {
+			logging.PrintError(s.l, "encoding", s.request, logging.QuoteError(err))
+			return fail(id, 0, "Failed to encode result")
+		}
+		result = stringified
+	}
+	return &message{ID: id, Event: event, Success: &response.Success{Result: result}}
+}
+
+// fail - builds message with error.
+func fail(id json.RawMessage, code int, text string) *message {
+	return &message{ID: id, Fail: &response.Fail{Error: response.ErrorDescription{Code: code, Message: text}}}
+}
+
+// execute - decodes and executes single command, returns the reply.
+// Supported commands: `subscribe`, `unsubscribe`, `get` and `increment` (with optional `idempotency_key`),
+// all of them address counter with `counter_id`.
+func (s *session) execute(data []byte) *message {
+	c := &command{}
+	if err := json.Unmarshal(data, c); err != nil {
+		logging.PrintError(s.l, "invalid", s.request, logging.QuoteError(err))
+		return fail(nil, 0, "Invalid or bad message")
+	}
+	var (
+		result interface{}
+		apiErr *api.Error
+	)
+	switch c.Command {
+	case "subscribe":
+		result, apiErr = s.subscribe(c.CounterID)
+	case "unsubscribe":
+		s.unsubscribe(c.CounterID)
+		result = &okResult{OK: true}
+	case "get":
+		result, apiErr = s.service.GetCounterValue(c.CounterID)
+	case "increment":
+		if c.IdempotencyKey != "" {
+			result, apiErr = s.service.IncreaseCounterOnce(c.CounterID, c.IdempotencyKey)
+		} else {
+			result, apiErr = s.service.IncreaseCounter(c.CounterID)
+		}
+	default:
+		err := fmt.Errorf("unknown command (%q)", c.Command)
+		logging.PrintError(s.l, "unknown", s.request, logging.QuoteError(err))
+		return fail(c.ID, 0, fmt.Sprintf("Unknown command (%q)", c.Command))
+	}
+	if apiErr != nil {
+		logging.PrintError(s.l, c.Command, s.request, logging.QuoteError(apiErr.ExposeError()))
+		return fail(c.ID, apiErr.Code, apiErr.Error())
+	}
+	logging.PrintInfo(s.l, c.Command, s.request)
+	return s.reply(c.ID, "", result)
+}
+
+// subscribe - subscribes to events of the counter and returns current value of the counter,
+// the value is read after subscription, so the changes after it are not missed.
+func (s *session) subscribe(counterID int) (*api.IntValueResult, *api.Error) {
+	if counterID < 1 {
+		// zero ID subscribes to all counters
+		return nil, &api.Error{Message: fmt.Sprintf("invalid counter ID (%d)", counterID)}
+	}
+	s.mx.Lock()
+	_, subscribed := s.subscriptions[counterID]
+	if !subscribed {
+		if len(s.subscriptions) >= MaxSubscriptions {
+			s.mx.Unlock()
+			return nil, &api.Error{Message: fmt.Sprintf("too many subscriptions, up to %d counters are allowed", MaxSubscriptions)}
+		}
+		events, cancel := s.events.SubscribeCounterEvents(counterID, eventQueueSize)
+		sub := &subscription{events: events, cancel: cancel}
+		s.subscriptions[counterID] = sub
+		go s.forward(counterID, sub)
+	}
+	s.mx.Unlock()
+	result, apiErr := s.service.GetCounterValue(counterID)
+	if apiErr != nil && !subscribed {
+		s.unsubscribe(counterID)
+	}
+	return result, apiErr
+}
+
+// unsubscribe - cancels subscription to events of the counter if it exists.
+func (s *session) unsubscribe(counterID int) {
+	s.mx.Lock()
+	sub, ok := s.subscriptions[counterID]
+	delete(s.subscriptions, counterID)
+	s.mx.Unlock()
+	if ok {
+		sub.cancel()
+	}
+}
+
+// forward - passes events of subscription to the client until subscription is cancelled.
+// Session is stopped when the client does not keep up with events or the source closes subscription.
+func (s *session) forward(counterID int, sub *subscription) {
+	for event := range sub.events {
+		select {
+		case s.out <- s.reply(nil, event.Type, event):
+		default:
+			logging.PrintError(s.l, "lagging", s.request, logging.QuoteError(errors.New("queue of messages is full")))
+			s.stop(websocket.ClosePolicyViolation, "client does not keep up with events")
+			return
+		}
+	}
+	s.mx.Lock()
+	current := s.subscriptions[counterID]
+	s.mx.Unlock()
+	if current == sub {
+		// subscription is closed by the source, not by the client
+		s.stop(websocket.CloseTryAgainLater, "subscription is closed")
+	}
+}
@@ -0,0 +1,110 @@
+package ws
+
+import (
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/wtask-go/auracounter/internal/counter"
+	"github.com/wtask-go/auracounter/internal/counter/datastore/memory"
+)
+
+// eventTime - matches time of event within message
+var eventTime = regexp.MustCompile(`"time":"[^"]+"`)
+
+func TestCounterHandler(t *testing.T) {
+	bus := counter.NewEventBus()
+	service, err := counter.NewCyclicCounterService(memory.NewStorage().Repository(), counter.WithEventBus(bus))
+	if err != nil {
+		t.Fatalf("Unable to build counter service: %v", err)
+	}
+	server := httptest.NewServer(NewCounterHandler(service, bus, nil))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Unable to connect: %v", err)
+	}
+	defer conn.Close()
+	// read - reads next n messages in any order, events and replies are not ordered
+	read := func(n int) map[string]bool {
+		messages := map[string]bool{}
+		for i := 0; i < n; i++ {
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				t.Fatalf("Unable to read message: %v", err)
+			}
+			// time of events is not predictable
+			message := eventTime.ReplaceAllString(strings.TrimSpace(string(data)), `"time":"*"`)
+			messages[message] = true
+		}
+		return messages
+	}
+
+	cases := []struct {
+		command  string
+		expected []string
+	}{
+		{`{"id":1,"command":"subscribe","counter_id":1}`, []string{`{"id":1,"result":{"value":0}}`}},
+		{`{"id":2,"command":"subscribe","counter_id":1}`, []string{`{"id":2,"result":{"value":0}}`}},
+		{
+			`{"id":"a","command":"increment","counter_id":1}`,
+			[]string{
+				`{"id":"a","result":{"value":1}}`,
+				`{"event":"value","result":{"id":1,"type":"value","counter_id":1,"time":"*","operation":"increase","value":1}}`,
+			},
+		},
+		{
+			`{"id":3,"command":"increment","counter_id":1,"idempotency_key":"k"}`,
+			[]string{
+				`{"id":3,"result":{"value":2}}`,
+				`{"event":"value","result":{"id":2,"type":"value","counter_id":1,"time":"*","operation":"increase","value":2}}`,
+			},
+		},
+		{`{"id":4,"command":"increment","counter_id":1,"idempotency_key":"k"}`, []string{`{"id":4,"result":{"value":2,"replayed":true}}`}},
+		{`{"id":5,"command":"get","counter_id":2}`, []string{`{"id":5,"result":{"value":0}}`}},
+		{`{"id":6,"command":"get","counter_id":0}`, []string{`{"id":6,"error":{"message":"invalid counter ID (0)"}}`}},
+		{`{"id":7,"command":"subscribe","counter_id":0}`, []string{`{"id":7,"error":{"message":"invalid counter ID (0)"}}`}},
+		{`{"id":8,"command":"unsubscribe","counter_id":1}`, []string{`{"id":8,"result":{"ok":true}}`}},
+		// there is no event after unsubscribe
+		{`{"id":9,"command":"increment","counter_id":1}`, []string{`{"id":9,"result":{"value":3}}`}},
+		{`{"id":10,"command":"reset","counter_id":1}`, []string{`{"id":10,"error":{"message":"Unknown command (\"reset\")"}}`}},
+		{`[1]`, []string{`{"error":{"message":"Invalid or bad message"}}`}},
+	}
+	for _, c := range cases {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(c.command)); err != nil {
+			t.Fatalf("%s: unable to write: %v", c.command, err)
+		}
+		messages := read(len(c.expected))
+		for _, expected := range c.expected {
+			if !messages[expected] {
+				t.Errorf("%s: expected message %s, got %v", c.command, expected, messages)
+			}
+		}
+	}
+
+	stringified, _, err := websocket.DefaultDialer.Dial(url+"?numbers=string", nil)
+	if err != nil {
+		t.Fatalf("Unable to connect: %v", err)
+	}
+	defer stringified.Close()
+	stringified.WriteMessage(websocket.TextMessage, []byte(`{"id":1,"command":"subscribe","counter_id":1}`))
+	stringified.SetReadDeadline(time.Now().Add(time.Second))
+	if _, data, err := stringified.ReadMessage(); err != nil || strings.TrimSpace(string(data)) != `{"id":1,"result":{"value":"3"}}` {
+		t.Errorf("Expected value as string, got %s, %v", data, err)
+	}
+
+	// connections with subscriptions are closed when the source is closed
+	bus.Close()
+	stringified.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, err = stringified.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseTryAgainLater) {
+		t.Errorf("Expected close error (%d), got %v", websocket.CloseTryAgainLater, err)
+	}
+}
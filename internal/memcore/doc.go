@@ -0,0 +1,5 @@
+/*
+Package memcore define public API of counter service in memcached text protocol,
+so existing memcached clients are able to increase, decrease and get counters.
+*/
+package memcore
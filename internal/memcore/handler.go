@@ -0,0 +1,276 @@
+package memcore
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/wtask-go/auracounter/internal/api"
+	"github.com/wtask-go/auracounter/internal/tcpcore"
+
+	"github.com/wtask-go/auracounter/pkg/logging"
+)
+
+const (
+	// maxKeyLength - the longest key, the same as memcached limit
+	maxKeyLength = 250
+	// maxDelta - the largest delta of incr and decr commands, the same as the largest block of counter values
+	maxDelta = 10000
+	// maxDataSize - the largest data block of storage command, the same as memcached default item size
+	maxDataSize = 1024 * 1024
+	// version - reported by `version` command
+	version = "aurasrv"
+)
+
+// NewCounterHandler - builds connection handler, which speaks subset of memcached text protocol
+// for api.CyclicCounterService implementation: incr, decr, get, version and quit.
+// Every key addresses counter by ID, see tcpcore.CounterID; other keys are not found.
+// If there is no a plan to log commands, pass logging.Printer as nil,
+// otherwise make an adapter to expose logging.Printer interface.
+func NewCounterHandler(service api.CyclicCounterService, l logging.Printer) tcpcore.Handler {
+	if service == nil {
+		panic(errors.New("memcore.NewCounterHandler: CounterService is not implemented"))
+	}
+	return func(conn net.Conn) {
+		s := &session{
+			service: service,
+			l:       l,
+			remote:  conn.RemoteAddr().String(),
+			r:       bufio.NewReader(conn),
+			w:       bufio.NewWriter(conn),
+		}
+		s.serve()
+	}
+}
+
+// session - state of single client connection
+type session struct {
+	service api.CyclicCounterService
+	l       logging.Printer
+	remote  string // remote address of the client
+	r       *bufio.Reader
+	w       *bufio.Writer
+}
+
+// clientError - command is malformed, it is replied with CLIENT_ERROR
+type clientError string
+
+func (e clientError) Error() string {
+	return string(e)
+}
+
+var (
+	// errUnknownCommand - command is unknown or has unexpected number of arguments, it is replied with ERROR
+	errUnknownCommand = errors.New("unknown command")
+	// errLineTooLong - command line does not fit the buffer, connection is closed after reply
+	errLineTooLong = clientError("line is too long")
+	// errBadFormat - command line contains invalid key
+	errBadFormat = clientError("bad command line format")
+	// errBadDataChunk - data size of storage command is invalid, so its data block can not be skipped,
+	// connection is closed after reply
+	errBadDataChunk = clientError("bad data chunk")
+	// errNotStored - values of counters are changed with incr and decr only
+	errNotStored = errors.New("storage commands are not supported")
+)
+
+// serve - executes commands until client quits or connection fails.
+// Replies of pipelined commands are flushed at once.
+func (s *session) serve() {
+	for {
+		line, err := s.r.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			logging.PrintError(s.l, "memcached", s.remote, logging.QuoteError(errLineTooLong))
+			fmt.Fprintf(s.w, "CLIENT_ERROR %s\r\n", errLineTooLong)
+			s.w.Flush()
+			return
+		}
+		if err != nil {
+			return
+		}
+		quit := s.execute(strings.Fields(string(line)))
+		if quit || s.r.Buffered() == 0 {
+			if err := s.w.Flush(); err != nil {
+				return
+			}
+		}
+		if quit {
+			return
+		}
+	}
+}
+
+// execute - executes single command and writes its reply, returns true when client quits
+// or when the connection must be closed after reply (see errBadDataChunk).
+// Replies of commands with `noreply` option are not written, except the reply before close.
+func (s *session) execute(args []string) (quit bool) {
+	command := ""
+	if len(args) > 0 {
+		command = args[0]
+		args = args[1:]
+	}
+	noreply := false
+	var (
+		reply string
+		err   error
+	)
+	switch command {
+	case "get":
+		reply, err = s.get(args)
+	case "incr", "decr":
+		args, noreply = noreplyOption(args)
+		reply, err = s.change(args, command == "decr")
+	case "set", "add", "replace", "append", "prepend", "cas":
+		args, noreply = noreplyOption(args)
+		err = s.discard(args)
+	case "version":
+		reply = "VERSION " + version
+	case "quit":
+		quit = true
+	default:
+		err = errUnknownCommand
+	}
+	if err != nil {
+		switch e := err.(type) {
+		case *api.Error:
+			// internal details are logged only
+			reply = "CLIENT_ERROR " + e.Error()
+			if e.IsInternal() {
+				reply = "SERVER_ERROR " + e.Error()
+			}
+			err = e.ExposeError()
+		case clientError:
+			reply = "CLIENT_ERROR " + e.Error()
+		default:
+			reply = "SERVER_ERROR " + e.Error()
+			if err == errUnknownCommand {
+				reply = "ERROR"
+			}
+		}
+		logging.PrintError(s.l, "memcached", command, s.remote, logging.QuoteError(err))
+	} else if !quit {
+		logging.PrintInfo(s.l, "memcached", command, s.remote)
+	}
+	if err == errBadDataChunk {
+		// the rest of connection is out of sync with commands
+		fmt.Fprintf(s.w, "%s\r\n", reply)
+		return true
+	}
+	if !quit && !noreply {
+		fmt.Fprintf(s.w, "%s\r\n", reply)
+	}
+	return quit
+}
+
+// noreplyOption - detects and cuts `noreply` option which must be the last argument of command.
+func noreplyOption(args []string) ([]string, bool) {
+	if len(args) > 0 && args[len(args)-1] == "noreply" {
+		return args[:len(args)-1], true
+	}
+	return args, false
+}
+
+// caller - identifies the client by its address.
+func (s *session) caller() api.Caller {
+	caller := api.Caller{Client: s.remote}
+	if host, _, err := net.SplitHostPort(s.remote); err == nil {
+		caller.Client = host
+	}
+	return caller
+}
+
+// get - get <key>*, only found counters are returned, reply is terminated with END
+func (s *session) get(keys []string) (string, error) {
+	if len(keys) == 0 {
+		return "", errUnknownCommand
+	}
+	reply := strings.Builder{}
+	for _, key := range keys {
+		if len(key) > maxKeyLength {
+			return "", errBadFormat
+		}
+		id, ok := tcpcore.CounterID(key)
+		if !ok {
+			continue
+		}
+		result, apiErr := s.service.GetCounterValue(id)
+		if apiErr != nil {
+			if apiErr.IsInternal() {
+				return "", apiErr
+			}
+			continue
+		}
+		value := strconv.FormatInt(result.Value, 10)
+		fmt.Fprintf(&reply, "VALUE %s 0 %d\r\n%s\r\n", key, len(value), value)
+	}
+	reply.WriteString("END")
+	return reply.String(), nil
+}
+
+// change - incr <key> <delta> or decr <key> <delta>, counter is changed `delta` times by its own increment,
+// so it is the same as for memcached when counter increment is 1 (default), the last value is returned.
+// Unlike memcached, counters wrap according to their settings and decr does not stop at zero.
+// Both incr and decr are atomic, decr reverts incr with the same delta.
+func (s *session) change(args []string, decrease bool) (string, error) {
+	if len(args) != 2 {
+		return "", errUnknownCommand
+	}
+	if len(args[0]) > maxKeyLength {
+		return "", errBadFormat
+	}
+	delta, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		return "", clientError("invalid numeric delta argument")
+	}
+	if delta > maxDelta {
+		return "", clientError(fmt.Sprintf("delta is out of the range [0:%d]", maxDelta))
+	}
+	id, ok := tcpcore.CounterID(args[0])
+	if !ok {
+		return "NOT_FOUND", nil
+	}
+	service := s.service.WithCaller(s.caller())
+	var value int64
+	switch {
+	case delta == 0:
+		result, apiErr := service.GetCounterValue(id)
+		if apiErr != nil {
+			return "", apiErr
+		}
+		value = result.Value
+	case decrease:
+		result, apiErr := service.DecreaseCounterBy(id, int(delta))
+		if apiErr != nil {
+			return "", apiErr
+		}
+		value = result.Value
+	default:
+		result, apiErr := service.ReserveCounterBlock(id, int(delta))
+		if apiErr != nil {
+			return "", apiErr
+		}
+		// the last value of the last range is the new counter value
+		value = result.Ranges[len(result.Ranges)-1].Last
+	}
+	return strconv.FormatInt(value, 10), nil
+}
+
+// discard - skips data block of storage command to keep the protocol in sync,
+// values of counters are not stored with storage commands.
+func (s *session) discard(args []string) error {
+	if len(args) < 4 {
+		return errUnknownCommand
+	}
+	size, err := strconv.Atoi(args[3])
+	if err != nil || size < 0 || size > maxDataSize {
+		return errBadDataChunk
+	}
+	if _, err := io.CopyN(ioutil.Discard, s.r, int64(size)+2); err != nil {
+		return err
+	}
+	return errNotStored
+}
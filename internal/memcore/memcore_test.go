@@ -0,0 +1,125 @@
+package memcore
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wtask-go/auracounter/internal/counter"
+	"github.com/wtask-go/auracounter/internal/counter/datastore/memory"
+	"github.com/wtask-go/auracounter/internal/tcpcore"
+)
+
+func TestCounterHandler(t *testing.T) {
+	service, err := counter.NewCyclicCounterService(memory.NewStorage().Repository())
+	if err != nil {
+		t.Fatalf("Unable to build counter service: %v", err)
+	}
+	if _, apiErr := service.SetCounterSettings(2, 1, 0, 10, 0); apiErr != nil {
+		t.Fatalf("Unable to set counter settings: %v", apiErr)
+	}
+	server := &tcpcore.Server{Addr: "127.0.0.1:0", Handler: NewCounterHandler(service, nil)}
+	listener, err := server.Listen()
+	if err != nil {
+		t.Fatalf("Unable to listen: %v", err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(listener) }()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Unable to connect: %v", err)
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	cases := []struct {
+		command, expected string
+	}{
+		{"version\r\n", "VERSION aurasrv\r\n"},
+		{"incr 1 1\r\n", "1\r\n"},
+		{"incr counter:1 1\r\n", "2\r\n"},
+		{"incr 1 1 noreply\r\nget 1\r\n", "VALUE 1 0 1\r\n3\r\nEND\r\n"},
+		{"decr 1 1\r\n", "2\r\n"},
+		{"incr 1 0\r\n", "2\r\n"},
+		{"get 1 counter x:2 counter:1\r\n", "VALUE 1 0 1\r\n2\r\nVALUE x:2 0 1\r\n0\r\nVALUE counter:1 0 1\r\n2\r\nEND\r\n"},
+		// counter #2 wraps within range [0:10]
+		{"incr 2 12\r\n", "1\r\n"},
+		{"decr 2 2\r\n", "10\r\n"},
+		{"decr 2 10000\r\n", "9\r\n"},
+		{"incr counter 1\r\n", "NOT_FOUND\r\n"},
+		{"incr 2 x\r\n", "CLIENT_ERROR invalid numeric delta argument\r\n"},
+		{"decr 2 -1\r\n", "CLIENT_ERROR invalid numeric delta argument\r\n"},
+		{"decr 2 10001\r\n", "CLIENT_ERROR delta is out of the range [0:10000]\r\n"},
+		{"incr " + strings.Repeat("1", 251) + " 1\r\n", "CLIENT_ERROR bad command line format\r\n"},
+		{"set 1 0 0 2\r\n10\r\n", "SERVER_ERROR storage commands are not supported\r\n"},
+		{"set 1 0 0 2 noreply\r\n10\r\nget 1\r\n", "VALUE 1 0 1\r\n2\r\nEND\r\n"},
+		{"incr 1\r\n", "ERROR\r\n"},
+		{"get\r\n", "ERROR\r\n"},
+		{"\r\n", "ERROR\r\n"},
+		{"flush_all\r\n", "ERROR\r\n"},
+	}
+	for _, c := range cases {
+		if _, err := io.WriteString(conn, c.command); err != nil {
+			t.Fatalf("%q: unable to write: %v", c.command, err)
+		}
+		reply := make([]byte, len(c.expected))
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		if _, err := io.ReadFull(r, reply); err != nil {
+			t.Fatalf("%q: unable to read reply: %v", c.command, err)
+		}
+		if string(reply) != c.expected {
+			t.Errorf("%q: expected reply %q, got %q", c.command, c.expected, reply)
+		}
+	}
+
+	if _, err := io.WriteString(conn, "quit\r\n"); err != nil {
+		t.Fatalf("Unable to write: %v", err)
+	}
+	if _, err := r.ReadByte(); err != io.EOF {
+		t.Errorf("Expected connection closed after quit, got %v", err)
+	}
+
+	long, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Unable to connect: %v", err)
+	}
+	defer long.Close()
+	io.WriteString(long, "get "+strings.Repeat("1 ", 4096)+"\r\n")
+	lr := bufio.NewReader(long)
+	if line, _ := lr.ReadString('\n'); line != "CLIENT_ERROR line is too long\r\n" {
+		t.Errorf("Expected error of too long line, got %q", line)
+	}
+	// connection may be reset, because the rest of line is not read by server
+	if _, err := lr.ReadByte(); err == nil {
+		t.Errorf("Expected connection closed after too long line")
+	}
+
+	for _, command := range []string{"set 1 0 0 x\r\n", "set 1 0 0 -1 noreply\r\n", "set 1 0 0 1048577\r\n"} {
+		bad, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			t.Fatalf("Unable to connect: %v", err)
+		}
+		// data block must not be parsed as command
+		io.WriteString(bad, command+"incr 1 1\r\n")
+		br := bufio.NewReader(bad)
+		bad.SetReadDeadline(time.Now().Add(time.Second))
+		if line, _ := br.ReadString('\n'); line != "CLIENT_ERROR bad data chunk\r\n" {
+			t.Errorf("%q: expected error of bad data chunk, got %q", command, line)
+		}
+		if _, err := br.ReadByte(); err == nil {
+			t.Errorf("%q: expected connection closed after bad data chunk", command)
+		}
+		bad.Close()
+	}
+
+	if err := server.Shutdown(time.Second); err != nil {
+		t.Errorf("Shutdown(): unexpected error %v", err)
+	}
+	if err := <-served; err != tcpcore.ErrServerClosed {
+		t.Errorf("Serve(): expected ErrServerClosed, got %v", err)
+	}
+}
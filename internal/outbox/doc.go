@@ -0,0 +1,6 @@
+/*
+Package outbox delivers events of counter changes from outbox of storage to downstream consumers.
+Relay polls the outbox, passes pending events to sink (webhook, file or writer) and forgets them after delivery,
+so every event is delivered at least once and consumers are expected to drop duplicates by event ID.
+*/
+package outbox
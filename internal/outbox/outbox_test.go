@@ -0,0 +1,302 @@
+package outbox
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wtask-go/auracounter/internal/counter"
+)
+
+// memoryOutbox - outbox which fails the first `failures` calls of Pending,
+// events with `corrupt` IDs are moved to `dead` like undecodable events
+type memoryOutbox struct {
+	mx       sync.Mutex
+	events   []counter.OutboxEvent
+	failures int
+	corrupt  map[int64]bool
+	dead     []int64
+}
+
+func (o *memoryOutbox) Pending(limit int) ([]counter.OutboxEvent, []int64, error) {
+	o.mx.Lock()
+	defer o.mx.Unlock()
+	if o.failures > 0 {
+		o.failures--
+		return nil, nil, errors.New("outbox is not available")
+	}
+	if len(o.events) < limit {
+		limit = len(o.events)
+	}
+	events, dead := []counter.OutboxEvent{}, []int64(nil)
+	for _, e := range o.events[:limit] {
+		if o.corrupt[e.ID] {
+			dead = append(dead, e.ID)
+			continue
+		}
+		events = append(events, e)
+	}
+	o.dead = append(o.dead, dead...)
+	o.forget(dead)
+	return events, dead, nil
+}
+
+// forget - removes events with given IDs, caller must hold the lock.
+func (o *memoryOutbox) forget(ids []int64) {
+	forgotten := map[int64]bool{}
+	for _, id := range ids {
+		forgotten[id] = true
+	}
+	pending := o.events[:0]
+	for _, e := range o.events {
+		if !forgotten[e.ID] {
+			pending = append(pending, e)
+		}
+	}
+	o.events = pending
+}
+
+func (o *memoryOutbox) Delivered(ids []int64) error {
+	o.mx.Lock()
+	defer o.mx.Unlock()
+	o.forget(ids)
+	return nil
+}
+
+func (o *memoryOutbox) size() int {
+	o.mx.Lock()
+	defer o.mx.Unlock()
+	return len(o.events)
+}
+
+// memorySink - sink which rejects the first `failures` batches
+type memorySink struct {
+	mx       sync.Mutex
+	batches  [][]counter.OutboxEvent
+	failures int
+}
+
+func (s *memorySink) Deliver(events []counter.OutboxEvent) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("sink is not available")
+	}
+	s.batches = append(s.batches, events)
+	return nil
+}
+
+func newEvents(n int) []counter.OutboxEvent {
+	events := make([]counter.OutboxEvent, n)
+	for i := range events {
+		events[i] = counter.OutboxEvent{ID: int64(i + 1), CounterID: 1, Operation: counter.IncreaseOperation, Value: int64(i + 1)}
+	}
+	return events
+}
+
+func TestRelay(t *testing.T) {
+	outbox := &memoryOutbox{events: newEvents(5), failures: 1}
+	sink := &memorySink{failures: 2}
+	relay, err := NewRelay(outbox, sink, nil,
+		WithBatchSize(2),
+		WithPollInterval(10*time.Millisecond),
+		WithBackoff(time.Millisecond, 2*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("NewRelay() failed: %v", err)
+	}
+	relay.Start()
+	deadline := time.Now().Add(time.Second)
+	for outbox.size() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if err := relay.Stop(time.Second); err != nil {
+		t.Errorf("Stop() failed: %v", err)
+	}
+	if outbox.size() != 0 {
+		t.Fatalf("Expected all events delivered, %d left", outbox.size())
+	}
+	sizes := []int{}
+	next := int64(1)
+	for _, batch := range sink.batches {
+		sizes = append(sizes, len(batch))
+		for _, e := range batch {
+			if e.ID != next {
+				t.Errorf("Expected event #%d, got #%d", next, e.ID)
+			}
+			next++
+		}
+	}
+	if len(sizes) != 3 || sizes[0] != 2 || sizes[1] != 2 || sizes[2] != 1 {
+		t.Errorf("Unexpected batches: %v", sizes)
+	}
+}
+
+// memoryPrinter - logging.Printer which keeps error messages
+type memoryPrinter struct {
+	mx     sync.Mutex
+	errors []string
+}
+
+func (p *memoryPrinter) Error(v ...interface{}) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	p.errors = append(p.errors, fmt.Sprint(v...))
+}
+
+func (p *memoryPrinter) Info(v ...interface{}) {}
+
+func TestRelay_DeadEvents(t *testing.T) {
+	outbox := &memoryOutbox{events: newEvents(5), corrupt: map[int64]bool{2: true}}
+	sink := &memorySink{}
+	printer := &memoryPrinter{}
+	relay, err := NewRelay(outbox, sink, printer, WithBatchSize(2), WithPollInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewRelay() failed: %v", err)
+	}
+	relay.Start()
+	deadline := time.Now().Add(time.Second)
+	for outbox.size() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if err := relay.Stop(time.Second); err != nil {
+		t.Errorf("Stop() failed: %v", err)
+	}
+	if outbox.size() != 0 {
+		t.Fatalf("Corrupt event must not block delivery, %d event(s) left", outbox.size())
+	}
+	delivered := []int64{}
+	for _, batch := range sink.batches {
+		for _, e := range batch {
+			delivered = append(delivered, e.ID)
+		}
+	}
+	if fmt.Sprint(delivered) != "[1 3 4 5]" {
+		t.Errorf("Expected events [1 3 4 5] delivered, got %v", delivered)
+	}
+	if len(printer.errors) != 1 || !strings.Contains(printer.errors[0], "[2]") {
+		t.Errorf("Expected dead event #2 reported, got %q", printer.errors)
+	}
+}
+
+func TestRelay_Options(t *testing.T) {
+	cases := []struct {
+		name   string
+		option relayOption
+	}{
+		{"batch size", WithBatchSize(0)},
+		{"poll interval", WithPollInterval(0)},
+		{"backoff", WithBackoff(time.Second, time.Millisecond)},
+	}
+	for _, c := range cases {
+		if _, err := NewRelay(&memoryOutbox{}, &memorySink{}, nil, c.option); err == nil {
+			t.Errorf("%s: expected option error", c.name)
+		}
+	}
+	if _, err := NewRelay(nil, &memorySink{}, nil); err == nil {
+		t.Error("Expected error for nil outbox")
+	}
+	if _, err := NewRelay(&memoryOutbox{}, nil, nil); err == nil {
+		t.Error("Expected error for nil sink")
+	}
+	relay, err := NewRelay(&memoryOutbox{}, &memorySink{}, nil, nil)
+	if err != nil {
+		t.Fatalf("NewRelay() failed: %v", err)
+	}
+	if err := relay.Stop(time.Second); err != nil {
+		t.Errorf("Stop() of not started relay failed: %v", err)
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	status := http.StatusNoContent
+	var received []counter.OutboxEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Unexpected request: %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		received = nil
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("Unable to decode events: %v", err)
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sink, err := NewWebhookSink(server.URL, time.Second)
+	if err != nil {
+		t.Fatalf("NewWebhookSink() failed: %v", err)
+	}
+	if err := sink.Deliver(newEvents(2)); err != nil {
+		t.Errorf("Deliver() failed: %v", err)
+	}
+	if len(received) != 2 || received[1].ID != 2 || received[1].Value != 2 {
+		t.Errorf("Unexpected received events: %+v", received)
+	}
+	status = http.StatusServiceUnavailable
+	if err := sink.Deliver(newEvents(1)); err == nil {
+		t.Error("Expected error for non-2xx status")
+	}
+	if _, err := NewWebhookSink("", time.Second); err == nil {
+		t.Error("Expected error for empty URL")
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "outbox")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "events.jsonl")
+	sink, err := NewFileSink(path)
+	if err != nil {
+		t.Fatalf("NewFileSink() failed: %v", err)
+	}
+	events := newEvents(3)
+	if err := sink.Deliver(events[:2]); err != nil {
+		t.Errorf("Deliver() failed: %v", err)
+	}
+	if err := sink.Deliver(events[2:]); err != nil {
+		t.Errorf("Deliver() failed: %v", err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unable to read file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 lines, got %q", data)
+	}
+	for i, line := range lines {
+		event := counter.OutboxEvent{}
+		if err := json.Unmarshal([]byte(line), &event); err != nil || event.ID != int64(i+1) {
+			t.Errorf("Unexpected line %q (%v)", line, err)
+		}
+	}
+	if _, err := NewFileSink(""); err == nil {
+		t.Error("Expected error for empty path")
+	}
+}
+
+func TestWriterSink(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := NewWriterSink(buf).Deliver(newEvents(1)); err != nil {
+		t.Fatalf("Deliver() failed: %v", err)
+	}
+	expected := `{"id":1,"counter_id":1,"time":"0001-01-01T00:00:00Z","operation":"increase","value":1,"previous":0,"cycle":0}` + "\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+}
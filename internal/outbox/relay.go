@@ -0,0 +1,232 @@
+package outbox
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/wtask-go/auracounter/internal/counter"
+
+	"github.com/wtask-go/auracounter/pkg/logging"
+)
+
+type (
+	// Relay - moves events from outbox to sink in background, see NewRelay.
+	Relay struct {
+		outbox       counter.Outbox
+		sink         Sink
+		l            logging.Printer
+		batchSize    int
+		pollInterval time.Duration
+		minBackoff   time.Duration
+		maxBackoff   time.Duration
+
+		startOnce sync.Once
+		stopOnce  sync.Once
+		stop      chan struct{}
+		done      chan struct{}
+	}
+
+	relayOption func() (func(*Relay), error)
+)
+
+// failedOption - helper to expose error from option builder
+func failedOption(err error) relayOption {
+	return func() (func(*Relay), error) {
+		return nil, err
+	}
+}
+
+// properOption - helper to expose setter from option builder
+func properOption(setter func(*Relay)) relayOption {
+	return func() (func(*Relay), error) {
+		return setter, nil
+	}
+}
+
+// setup - set relay options
+func (r *Relay) setup(options ...relayOption) error {
+	if r == nil {
+		return nil
+	}
+	for _, option := range options {
+		if option == nil {
+			continue
+		}
+		setter, err := option()
+		if err != nil {
+			return err
+		}
+		if setter != nil {
+			setter(r)
+		}
+	}
+	return nil
+}
+
+// WithBatchSize - the largest number of events which are delivered to sink at once, 100 by default.
+func WithBatchSize(size int) relayOption {
+	if size < 1 {
+		return failedOption(fmt.Errorf("batch size must be positive, got %d", size))
+	}
+	return properOption(func(r *Relay) {
+		r.batchSize = size
+	})
+}
+
+// WithPollInterval - how often outbox is checked when there are no pending events, 1 second by default.
+func WithPollInterval(interval time.Duration) relayOption {
+	if interval <= 0 {
+		return failedOption(fmt.Errorf("poll interval must be positive, got %s", interval))
+	}
+	return properOption(func(r *Relay) {
+		r.pollInterval = interval
+	})
+}
+
+// WithBackoff - delay before retry of failed delivery, it starts with `min` and doubles after every failure up to `max`.
+// By default, delay grows from 1 second up to 1 minute.
+func WithBackoff(min, max time.Duration) relayOption {
+	if min <= 0 || max < min {
+		return failedOption(fmt.Errorf("invalid backoff range [%s:%s]", min, max))
+	}
+	return properOption(func(r *Relay) {
+		r.minBackoff = min
+		r.maxBackoff = max
+	})
+}
+
+// NewRelay - builds relay of events from outbox to sink.
+// Events are forgotten only after the sink has accepted them, failed batch is retried with backoff,
+// so every event is delivered at least once, but it may be delivered more than once.
+// If there is no a plan to log failures, pass logging.Printer as nil,
+// otherwise make an adapter to expose logging.Printer interface.
+func NewRelay(outbox counter.Outbox, sink Sink, l logging.Printer, options ...relayOption) (*Relay, error) {
+	if outbox == nil {
+		return nil, errors.New("outbox.NewRelay: Outbox is not implemented")
+	}
+	if sink == nil {
+		return nil, errors.New("outbox.NewRelay: Sink is not implemented")
+	}
+	r := &Relay{
+		outbox:       outbox,
+		sink:         sink,
+		l:            l,
+		batchSize:    100,
+		pollInterval: time.Second,
+		minBackoff:   time.Second,
+		maxBackoff:   time.Minute,
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	if err := r.setup(options...); err != nil {
+		return nil, errors.Wrap(err, "outbox.NewRelay: option error")
+	}
+	return r, nil
+}
+
+// Start - starts delivery in background, only the first call takes effect.
+func (r *Relay) Start() {
+	r.startOnce.Do(func() {
+		go func() {
+			defer close(r.done)
+			r.run()
+		}()
+	})
+}
+
+// Stop - stops delivery and waits up to `timeout` for the current batch to be finished.
+// Undelivered events stay in outbox until the next start.
+func (r *Relay) Stop(timeout time.Duration) error {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+	})
+	r.startOnce.Do(func() {
+		// relay was never started
+		close(r.done)
+	})
+	select {
+	case <-r.done:
+		return nil
+	case <-time.After(timeout):
+		return errors.New("outbox.Relay.Stop: timeout exceeded")
+	}
+}
+
+// run - delivers batches of pending events until relay is stopped.
+// Full batch means there are more events, so the next batch is delivered without delay.
+func (r *Relay) run() {
+	backoff := time.Duration(0)
+	for {
+		n, err := r.relay()
+		delay := r.pollInterval
+		switch {
+		case err != nil:
+			logging.PrintError(r.l, "outbox", logging.QuoteError(err))
+			if backoff == 0 {
+				backoff = r.minBackoff
+			} else if backoff *= 2; backoff > r.maxBackoff {
+				backoff = r.maxBackoff
+			}
+			delay = backoff
+		case n == r.batchSize:
+			backoff = 0
+			delay = 0
+		default:
+			backoff = 0
+		}
+		if !r.wait(delay) {
+			return
+		}
+	}
+}
+
+// wait - pauses the loop, returns false when relay is stopped.
+func (r *Relay) wait(delay time.Duration) bool {
+	if delay == 0 {
+		select {
+		case <-r.stop:
+			return false
+		default:
+			return true
+		}
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-r.stop:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// relay - delivers single batch of pending events and forgets them, returns size of the batch.
+// Dead events of the batch are reported and are counted in the size, so the next batch follows without delay.
+func (r *Relay) relay() (int, error) {
+	events, dead, err := r.outbox.Pending(r.batchSize)
+	if err != nil {
+		return 0, errors.Wrap(err, "outbox.Relay: failed to read pending events")
+	}
+	if len(dead) > 0 {
+		logging.PrintError(r.l, "outbox", fmt.Sprintf("%d undecodable event(s) moved to dead letters: %v", len(dead), dead))
+	}
+	if len(events) == 0 {
+		return len(dead), nil
+	}
+	if err := r.sink.Deliver(events); err != nil {
+		return 0, errors.Wrap(err, "outbox.Relay: failed to deliver events")
+	}
+	ids := make([]int64, len(events))
+	for i := range events {
+		ids[i] = events[i].ID
+	}
+	if err := r.outbox.Delivered(ids); err != nil {
+		// events will be delivered once again
+		return 0, errors.Wrap(err, "outbox.Relay: failed to forget delivered events")
+	}
+	logging.PrintInfo(r.l, "outbox", fmt.Sprintf("%d event(s) delivered", len(events)))
+	return len(events) + len(dead), nil
+}
@@ -0,0 +1,116 @@
+package outbox
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/wtask-go/auracounter/internal/counter"
+)
+
+// Sink - destination of events, batch is delivered completely or is not delivered at all.
+// Sink is called from single goroutine of Relay.
+type Sink interface {
+	Deliver(events []counter.OutboxEvent) error
+}
+
+// webhookSink - posts batch of events as JSON array
+type webhookSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookSink - builds sink which posts every batch of events as JSON array to the URL,
+// batch is delivered when webhook responds with any 2xx status.
+func NewWebhookSink(url string, timeout time.Duration) (Sink, error) {
+	if url == "" {
+		return nil, errors.New("outbox.NewWebhookSink: required URL is missed")
+	}
+	return &webhookSink{url: url, client: &http.Client{Timeout: timeout}}, nil
+}
+
+func (s *webhookSink) Deliver(events []counter.OutboxEvent) error {
+	body, err := json.Marshal(events)
+	if err != nil {
+		return errors.Wrap(err, "outbox.webhookSink.Deliver: failed to encode events")
+	}
+	response, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "outbox.webhookSink.Deliver: failed")
+	}
+	defer response.Body.Close()
+	// drain body to reuse connection
+	io.Copy(ioutil.Discard, response.Body)
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("outbox.webhookSink.Deliver: unexpected status %q", response.Status)
+	}
+	return nil
+}
+
+// fileSink - appends events to the file as JSON lines
+type fileSink struct {
+	path string
+}
+
+// NewFileSink - builds sink which appends every event to the file as single JSON line,
+// the file is created if it does not exist and is synced after every batch.
+func NewFileSink(path string) (Sink, error) {
+	if path == "" {
+		return nil, errors.New("outbox.NewFileSink: required path is missed")
+	}
+	return &fileSink{path: path}, nil
+}
+
+func (s *fileSink) Deliver(events []counter.OutboxEvent) error {
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return errors.Wrap(err, "outbox.fileSink.Deliver: failed to open file")
+	}
+	defer f.Close()
+	if err := writeLines(f, events); err != nil {
+		return errors.Wrap(err, "outbox.fileSink.Deliver: failed")
+	}
+	return errors.Wrap(f.Sync(), "outbox.fileSink.Deliver: failed to sync file")
+}
+
+// writerSink - writes events to the writer as JSON lines
+type writerSink struct {
+	mx sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterSink - builds sink which writes every event to `w` as single JSON line, for example to os.Stdout.
+func NewWriterSink(w io.Writer) Sink {
+	if w == nil {
+		panic(errors.New("outbox.NewWriterSink: writer is nil"))
+	}
+	return &writerSink{w: w}
+}
+
+func (s *writerSink) Deliver(events []counter.OutboxEvent) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return errors.Wrap(writeLines(s.w, events), "outbox.writerSink.Deliver: failed")
+}
+
+// writeLines - writes events as JSON lines with single write call,
+// so lines of the batch are not interleaved with other output.
+func writeLines(w io.Writer, events []counter.OutboxEvent) error {
+	buf := bytes.Buffer{}
+	encoder := json.NewEncoder(&buf)
+	for i := range events {
+		if err := encoder.Encode(&events[i]); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,5 @@
+/*
+Package respcore define public API of counter service in Redis protocol (RESP),
+so existing Redis clients are able to increase and get counters.
+*/
+package respcore
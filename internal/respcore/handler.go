@@ -0,0 +1,231 @@
+package respcore
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/wtask-go/auracounter/internal/api"
+	"github.com/wtask-go/auracounter/internal/tcpcore"
+
+	"github.com/wtask-go/auracounter/pkg/logging"
+)
+
+// NewCounterHandler - builds connection handler, which speaks subset of Redis protocol (RESP)
+// for api.CyclicCounterService implementation: INCR, INCRBY, DECR, GET, PING, CLIENT SETNAME and QUIT.
+// Every key addresses counter by ID, see tcpcore.CounterID.
+// If there is no a plan to log commands, pass logging.Printer as nil,
+// otherwise make an adapter to expose logging.Printer interface.
+func NewCounterHandler(service api.CyclicCounterService, l logging.Printer) tcpcore.Handler {
+	if service == nil {
+		panic(errors.New("respcore.NewCounterHandler: CounterService is not implemented"))
+	}
+	return func(conn net.Conn) {
+		s := &session{
+			service: service,
+			l:       l,
+			remote:  conn.RemoteAddr().String(),
+			r:       bufio.NewReader(conn),
+			w:       bufio.NewWriter(conn),
+		}
+		s.serve()
+	}
+}
+
+// session - state of single client connection
+type session struct {
+	service api.CyclicCounterService
+	l       logging.Printer
+	remote  string // remote address of the client
+	name    string // name of the connection, see CLIENT SETNAME
+	r       *bufio.Reader
+	w       *bufio.Writer
+}
+
+// serve - executes commands until client quits or connection fails.
+// Replies of pipelined commands are flushed at once.
+func (s *session) serve() {
+	for {
+		args, err := readCommand(s.r)
+		if err != nil {
+			if e, ok := err.(protocolError); ok {
+				logging.PrintError(s.l, "RESP", s.remote, logging.QuoteError(e))
+				writeError(s.w, "ERR "+e.Error())
+				s.w.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		quit := s.execute(args)
+		if quit || s.r.Buffered() == 0 {
+			if err := s.w.Flush(); err != nil {
+				return
+			}
+		}
+		if quit {
+			return
+		}
+	}
+}
+
+// execute - executes single command and writes its reply, returns true when client quits.
+func (s *session) execute(args []string) (quit bool) {
+	command := strings.ToUpper(args[0])
+	var err error
+	switch command {
+	case "PING":
+		err = s.ping(args[1:])
+	case "QUIT":
+		writeSimple(s.w, "OK")
+		quit = true
+	case "CLIENT":
+		err = s.client(args[1:])
+	case "GET":
+		err = s.get(args[1:])
+	case "INCR":
+		err = s.change(args[1:], api.CyclicCounterService.IncreaseCounter)
+	case "DECR":
+		err = s.change(args[1:], api.CyclicCounterService.DecreaseCounter)
+	case "INCRBY":
+		err = s.increaseBy(args[1:])
+	default:
+		err = fmt.Errorf("ERR unknown command '%s'", args[0])
+	}
+	if err == errWrongArgs {
+		err = fmt.Errorf("ERR wrong number of arguments for '%s' command", strings.ToLower(command))
+	}
+	if err != nil {
+		message := err.Error()
+		if apiErr, ok := err.(*api.Error); ok {
+			// internal details are logged only
+			message = "ERR " + apiErr.Error()
+			err = apiErr.ExposeError()
+		}
+		writeError(s.w, message)
+		logging.PrintError(s.l, "RESP", command, s.remote, logging.QuoteError(err))
+		return quit
+	}
+	logging.PrintInfo(s.l, "RESP", command, s.remote)
+	return quit
+}
+
+// errWrongArgs - command is called with unexpected number of arguments
+var errWrongArgs = errors.New("wrong number of arguments")
+
+// caller - identifies the client by name of the connection or by its address.
+func (s *session) caller() api.Caller {
+	if s.name != "" {
+		return api.Caller{Client: s.name}
+	}
+	caller := api.Caller{Client: s.remote}
+	if host, _, err := net.SplitHostPort(s.remote); err == nil {
+		caller.Client = host
+	}
+	return caller
+}
+
+// ping - PING [message]
+func (s *session) ping(args []string) error {
+	switch len(args) {
+	case 0:
+		writeSimple(s.w, "PONG")
+	case 1:
+		writeBulk(s.w, args[0])
+	default:
+		return errWrongArgs
+	}
+	return nil
+}
+
+// client - CLIENT SETNAME name
+func (s *session) client(args []string) error {
+	if len(args) == 0 {
+		return errWrongArgs
+	}
+	if strings.ToUpper(args[0]) != "SETNAME" {
+		return fmt.Errorf("ERR unknown subcommand '%s'", args[0])
+	}
+	if len(args) != 2 {
+		return errWrongArgs
+	}
+	if strings.ContainsAny(args[1], " \n") {
+		return errors.New("ERR Client names cannot contain spaces, newlines or special characters.")
+	}
+	s.name = args[1]
+	writeSimple(s.w, "OK")
+	return nil
+}
+
+// get - GET key, value is returned as bulk string
+func (s *session) get(args []string) error {
+	if len(args) != 1 {
+		return errWrongArgs
+	}
+	id, err := counterID(args[0])
+	if err != nil {
+		return err
+	}
+	result, apiErr := s.service.GetCounterValue(id)
+	if apiErr != nil {
+		return apiErr
+	}
+	writeBulk(s.w, strconv.FormatInt(result.Value, 10))
+	return nil
+}
+
+// change - INCR key or DECR key, counter wraps according to its settings
+func (s *session) change(args []string, method func(api.CyclicCounterService, int) (*api.IntValueResult, *api.Error)) error {
+	if len(args) != 1 {
+		return errWrongArgs
+	}
+	id, err := counterID(args[0])
+	if err != nil {
+		return err
+	}
+	result, apiErr := method(s.service.WithCaller(s.caller()), id)
+	if apiErr != nil {
+		return apiErr
+	}
+	writeInteger(s.w, result.Value)
+	return nil
+}
+
+// increaseBy - INCRBY key n, counter is increased `n` times by its own increment,
+// so it is the same as for Redis when counter increment is 1 (default), the last value is returned.
+func (s *session) increaseBy(args []string) error {
+	if len(args) != 2 {
+		return errWrongArgs
+	}
+	id, err := counterID(args[0])
+	if err != nil {
+		return err
+	}
+	n, err := strconv.Atoi(args[1])
+	if err != nil {
+		return errors.New("ERR value is not an integer or out of range")
+	}
+	if n < 1 {
+		return errors.New("ERR increment must be positive")
+	}
+	result, apiErr := s.service.WithCaller(s.caller()).ReserveCounterBlock(id, n)
+	if apiErr != nil {
+		return apiErr
+	}
+	// the last value of the last range is the new counter value
+	writeInteger(s.w, result.Ranges[len(result.Ranges)-1].Last)
+	return nil
+}
+
+// counterID - maps key to counter ID, see tcpcore.CounterID.
+func counterID(key string) (int, error) {
+	id, ok := tcpcore.CounterID(key)
+	if !ok {
+		return 0, fmt.Errorf("ERR key '%s' does not address counter ID", key)
+	}
+	return id, nil
+}
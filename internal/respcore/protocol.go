@@ -0,0 +1,98 @@
+package respcore
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxArgs - the largest number of command arguments
+	maxArgs = 64
+	// maxBulkSize - the largest size of single argument in bytes
+	maxBulkSize = 64 * 1024
+)
+
+// protocolError - client sent something what is not RESP, connection must be closed after reply
+type protocolError string
+
+func (e protocolError) Error() string {
+	return "Protocol error: " + string(e)
+}
+
+// readLine - reads line terminated with CRLF (or LF for inline commands) and returns it without terminator.
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return "", protocolError("too big line")
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+// readCommand - reads command as array of bulk strings or as inline command (e.g. typed with telnet).
+// Empty command is returned for empty inline command, empty or null array.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil
+	}
+	n, err := strconv.Atoi(line[1:])
+	if n == -1 && err == nil {
+		// null array is skipped as empty command
+		return nil, nil
+	}
+	if err != nil || n < 0 || n > maxArgs {
+		return nil, protocolError("invalid multibulk length")
+	}
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, "$") {
+			return nil, protocolError(fmt.Sprintf("expected '$', got %q", line))
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 || size > maxBulkSize {
+			return nil, protocolError("invalid bulk length")
+		}
+		bulk := make([]byte, size+2)
+		if _, err := io.ReadFull(r, bulk); err != nil {
+			return nil, err
+		}
+		if bulk[size] != '\r' || bulk[size+1] != '\n' {
+			return nil, protocolError("bulk string is not terminated with CRLF")
+		}
+		args = append(args, string(bulk[:size]))
+	}
+	return args, nil
+}
+
+// writeSimple - writes simple string reply, e.g. OK or PONG.
+func writeSimple(w *bufio.Writer, s string) {
+	fmt.Fprintf(w, "+%s\r\n", s)
+}
+
+// writeError - writes error reply, message must start with error kind, e.g. ERR.
+func writeError(w *bufio.Writer, message string) {
+	fmt.Fprintf(w, "-%s\r\n", strings.NewReplacer("\r", " ", "\n", " ").Replace(message))
+}
+
+// writeInteger - writes integer reply.
+func writeInteger(w *bufio.Writer, n int64) {
+	fmt.Fprintf(w, ":%d\r\n", n)
+}
+
+// writeBulk - writes bulk string reply.
+func writeBulk(w *bufio.Writer, s string) {
+	fmt.Fprintf(w, "$%d\r\n%s\r\n", len(s), s)
+}
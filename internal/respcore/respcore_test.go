@@ -0,0 +1,110 @@
+package respcore
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wtask-go/auracounter/internal/counter"
+	"github.com/wtask-go/auracounter/internal/counter/datastore/memory"
+	"github.com/wtask-go/auracounter/internal/tcpcore"
+)
+
+func TestCounterHandler(t *testing.T) {
+	service, err := counter.NewCyclicCounterService(memory.NewStorage().Repository())
+	if err != nil {
+		t.Fatalf("Unable to build counter service: %v", err)
+	}
+	if _, apiErr := service.SetCounterSettings(2, 1, 0, 10, 0); apiErr != nil {
+		t.Fatalf("Unable to set counter settings: %v", apiErr)
+	}
+	server := &tcpcore.Server{Addr: "127.0.0.1:0", Handler: NewCounterHandler(service, nil)}
+	listener, err := server.Listen()
+	if err != nil {
+		t.Fatalf("Unable to listen: %v", err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(listener) }()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Unable to connect: %v", err)
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	cases := []struct {
+		command, expected string
+	}{
+		{"PING\r\n", "+PONG\r\n"},
+		{"*2\r\n$4\r\nPING\r\n$5\r\nhello\r\n", "$5\r\nhello\r\n"},
+		{"*3\r\n$6\r\nCLIENT\r\n$7\r\nSETNAME\r\n$4\r\ntest\r\n", "+OK\r\n"},
+		{"*2\r\n$4\r\nINCR\r\n$1\r\n1\r\n", ":1\r\n"},
+		{"*2\r\n$4\r\nincr\r\n$9\r\ncounter:1\r\n", ":2\r\n"},
+		{"*2\r\n$3\r\nGET\r\n$1\r\n1\r\n", "$1\r\n2\r\n"},
+		{"*2\r\n$4\r\nDECR\r\n$1\r\n1\r\n", ":1\r\n"},
+		// counter #2 wraps within range [0:10]
+		{"*3\r\n$6\r\nINCRBY\r\n$1\r\n2\r\n$2\r\n12\r\n", ":1\r\n"},
+		{"INCR 2\r\n", ":2\r\n"},
+		{"DECR 2\r\nDECR 2\r\nDECR 2\r\n", ":1\r\n:0\r\n:10\r\n"},
+		{"INCRBY 2 0\r\n", "-ERR increment must be positive\r\n"},
+		{"INCRBY 2 x\r\n", "-ERR value is not an integer or out of range\r\n"},
+		{"INCR counter\r\n", "-ERR key 'counter' does not address counter ID\r\n"},
+		{"GET 0\r\n", "-ERR key '0' does not address counter ID\r\n"},
+		{"INCR\r\n", "-ERR wrong number of arguments for 'incr' command\r\n"},
+		{"CLIENT KILL\r\n", "-ERR unknown subcommand 'KILL'\r\n"},
+		{"SET 1 1\r\n", "-ERR unknown command 'SET'\r\n"},
+		// null and empty arrays are skipped
+		{"*-1\r\n*0\r\nPING\r\n", "+PONG\r\n"},
+	}
+	for _, c := range cases {
+		if _, err := io.WriteString(conn, c.command); err != nil {
+			t.Fatalf("%q: unable to write: %v", c.command, err)
+		}
+		reply := make([]byte, len(c.expected))
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		if _, err := io.ReadFull(r, reply); err != nil {
+			t.Fatalf("%q: unable to read reply: %v", c.command, err)
+		}
+		if string(reply) != c.expected {
+			t.Errorf("%q: expected reply %q, got %q", c.command, c.expected, reply)
+		}
+	}
+
+	if _, err := io.WriteString(conn, "*x\r\n"); err != nil {
+		t.Fatalf("Unable to write: %v", err)
+	}
+	if line, _ := r.ReadString('\n'); !strings.HasPrefix(line, "-ERR Protocol error") {
+		t.Errorf("Expected protocol error, got %q", line)
+	}
+	if _, err := r.ReadByte(); err != io.EOF {
+		t.Errorf("Expected connection closed after protocol error, got %v", err)
+	}
+
+	negative, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Unable to connect: %v", err)
+	}
+	defer negative.Close()
+	io.WriteString(negative, "*-2\r\n")
+	if line, _ := bufio.NewReader(negative).ReadString('\n'); line != "-ERR Protocol error: invalid multibulk length\r\n" {
+		t.Errorf("Expected invalid multibulk length error, got %q", line)
+	}
+
+	idle, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Unable to connect: %v", err)
+	}
+	defer idle.Close()
+	io.WriteString(idle, "PING\r\n")
+	bufio.NewReader(idle).ReadString('\n')
+	if err := server.Shutdown(time.Second); err != nil {
+		t.Errorf("Shutdown(): unexpected error %v", err)
+	}
+	if err := <-served; err != tcpcore.ErrServerClosed {
+		t.Errorf("Serve(): expected ErrServerClosed, got %v", err)
+	}
+}
@@ -0,0 +1,4 @@
+/*
+Package tcpcore contains common logic for applications of TCP based text protocols.
+*/
+package tcpcore
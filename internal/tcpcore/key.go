@@ -0,0 +1,17 @@
+package tcpcore
+
+import (
+	"strconv"
+	"strings"
+)
+
+// CounterID - maps key of key-value protocol to counter ID,
+// key is the ID itself (`42`) or ends with it after colon (`counter:42`).
+// False is returned when key does not address any counter.
+func CounterID(key string) (int, bool) {
+	id, err := strconv.Atoi(key[strings.LastIndexByte(key, ':')+1:])
+	if err != nil || id < 1 {
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,157 @@
+package tcpcore
+
+import (
+	"errors"
+	"log"
+	"net"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// ErrServerClosed - is returned by Serve after server shutdown.
+var ErrServerClosed = errors.New("tcpcore: Server closed")
+
+// Handler - serves single connection until client closes it or reading from connection fails.
+// Connection is closed by server when handler returns.
+type Handler func(conn net.Conn)
+
+// Server - serves every accepted TCP connection with handler in its own goroutine.
+// Panic of handler closes its connection only, it is logged with ErrorLog (or with standard logger, if it is nil).
+type Server struct {
+	Addr     string
+	Handler  Handler
+	ErrorLog *log.Logger
+
+	mx       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// Listen - listens TCP address of the server, see Serve.
+func (s *Server) Listen() (net.Listener, error) {
+	return net.Listen("tcp", s.Addr)
+}
+
+// Serve - accepts connections until server shutdown, always returns non-nil error.
+func (s *Server) Serve(listener net.Listener) error {
+	s.mx.Lock()
+	if s.closed {
+		s.mx.Unlock()
+		listener.Close()
+		return ErrServerClosed
+	}
+	s.listener = listener
+	s.mx.Unlock()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			s.mx.Lock()
+			closed := s.closed
+			s.mx.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+		if !s.track(conn) {
+			conn.Close()
+			return ErrServerClosed
+		}
+		go func() {
+			defer s.untrack(conn)
+			defer conn.Close()
+			defer s.recoverHandler(conn)
+			s.Handler(conn)
+		}()
+	}
+}
+
+// recoverHandler - recovers panic of handler, so single connection is not able to take down the whole process.
+func (s *Server) recoverHandler(conn net.Conn) {
+	e := recover()
+	if e == nil {
+		return
+	}
+	stack := make([]byte, 64<<10)
+	stack = stack[:runtime.Stack(stack, false)]
+	logf := log.Printf
+	if s.ErrorLog != nil {
+		logf = s.ErrorLog.Printf
+	}
+	logf("tcpcore: panic serving %s: %v\n%s", conn.RemoteAddr(), e, stack)
+}
+
+// track - registers accepted connection, returns false if server is closed.
+func (s *Server) track(conn net.Conn) bool {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.closed {
+		return false
+	}
+	if s.conns == nil {
+		s.conns = map[net.Conn]struct{}{}
+	}
+	s.conns[conn] = struct{}{}
+	s.wg.Add(1)
+	return true
+}
+
+// untrack - forgets served connection.
+func (s *Server) untrack(conn net.Conn) {
+	s.mx.Lock()
+	delete(s.conns, conn)
+	s.mx.Unlock()
+	s.wg.Done()
+}
+
+// Shutdown - stops accepting connections and gracefully stops serving of accepted ones:
+// reading from connections is interrupted, so handlers finish the commands which are in progress.
+// Connections are closed forcibly when handlers are not finished within timeout.
+func (s *Server) Shutdown(timeout time.Duration) error {
+	s.mx.Lock()
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for conn := range s.conns {
+		conn.SetReadDeadline(time.Now())
+	}
+	s.mx.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		s.mx.Lock()
+		for conn := range s.conns {
+			conn.Close()
+		}
+		s.mx.Unlock()
+		<-done
+	}
+	return err
+}
+
+// LaunchServer - listens server address and starts serving in background.
+// First returned value is a shutdown function for started server.
+// Second returned value is a startup error.
+func LaunchServer(server *Server) (shutdown func(timeout time.Duration) error, startup error) {
+	listener, err := server.Listen()
+	if err != nil {
+		return nil, err
+	}
+	go server.Serve(listener)
+	return server.Shutdown, nil
+}
@@ -0,0 +1,65 @@
+package tcpcore
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer_RecoversHandlerPanic(t *testing.T) {
+	logs := &bytes.Buffer{}
+	server := &Server{
+		Addr: "127.0.0.1:0",
+		Handler: func(conn net.Conn) {
+			line, _ := bufio.NewReader(conn).ReadString('\n')
+			if line == "panic\n" {
+				panic("bad connection")
+			}
+			io.WriteString(conn, line)
+		},
+		ErrorLog: log.New(logs, "", 0),
+	}
+	listener, err := server.Listen()
+	if err != nil {
+		t.Fatalf("Unable to listen: %v", err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(listener) }()
+
+	bad, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Unable to connect: %v", err)
+	}
+	defer bad.Close()
+	io.WriteString(bad, "panic\n")
+	bad.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := bad.Read(make([]byte, 1)); err == nil {
+		t.Error("Expected connection closed after panic")
+	}
+
+	good, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Unable to connect after panic: %v", err)
+	}
+	defer good.Close()
+	io.WriteString(good, "echo\n")
+	good.SetReadDeadline(time.Now().Add(time.Second))
+	if line, err := bufio.NewReader(good).ReadString('\n'); line != "echo\n" {
+		t.Errorf("Expected echo after panic, got %q (%v)", line, err)
+	}
+
+	if err := server.Shutdown(time.Second); err != nil {
+		t.Errorf("Shutdown(): unexpected error %v", err)
+	}
+	if err := <-served; err != ErrServerClosed {
+		t.Errorf("Serve(): expected ErrServerClosed, got %v", err)
+	}
+	if !strings.Contains(logs.String(), "panic serving") || !strings.Contains(logs.String(), "bad connection") {
+		t.Errorf("Expected panic is logged, got %q", logs.String())
+	}
+}
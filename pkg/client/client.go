@@ -0,0 +1,238 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type (
+	// Client - hands out values of single counter from blocks leased from aurasrv.
+	// Client is safe for concurrent use.
+	Client struct {
+		http       *http.Client
+		url        string // complete URL to reserve the block
+		blockSize  int
+		threshold  int
+		retries    int
+		retryDelay time.Duration
+
+		mx        sync.Mutex
+		ranges    []valueRange // leased ranges, the first one is handed out now
+		remaining int          // total number of values in leased ranges
+		refilling *refill      // not nil while the next block is requested
+		ctx       context.Context
+		cancel    context.CancelFunc
+		wg        sync.WaitGroup
+	}
+
+	// valueRange - arithmetic progression of counter values, see `reservenumbers` route response
+	valueRange struct {
+		First int64 `json:"first"`
+		Last  int64 `json:"last"`
+		Step  int64 `json:"step"`
+		Count int   `json:"count"`
+	}
+
+	// refill - single attempt to lease the next block
+	refill struct {
+		done chan struct{} // is closed when attempt is completed
+		err  error
+	}
+
+	// Error - failure reported by the server.
+	Error struct {
+		// Status - HTTP status code of response
+		Status int
+		// Message - error message from the server
+		Message string
+	}
+)
+
+// Error - formats error message.
+func (e *Error) Error() string {
+	return fmt.Sprintf("aurasrv responded with %d status: %s", e.Status, e.Message)
+}
+
+// temporary - checks the failure may disappear if the request will be repeated.
+func (e *Error) temporary() bool {
+	return e.Status == http.StatusInternalServerError || e.Status == http.StatusServiceUnavailable
+}
+
+// New - builds client for counter with given ID.
+// Parameter `baseURL` is a complete URL of aurasrv REST API, for example `http://127.0.0.1:33333/counter/v1/`.
+// The first block is leased on the first call of `Next()`.
+func New(baseURL string, counterID int, options ...clientOption) (*Client, error) {
+	if baseURL == "" {
+		return nil, errors.New("client.New: base URL is missed")
+	}
+	if counterID <= 0 {
+		return nil, errors.Errorf("client.New: invalid counter ID (%d)", counterID)
+	}
+	c := &Client{
+		http:       &http.Client{Timeout: 10 * time.Second},
+		blockSize:  100,
+		threshold:  -1,
+		retries:    3,
+		retryDelay: 100 * time.Millisecond,
+	}
+	if err := c.setup(options...); err != nil {
+		return nil, errors.WithMessage(err, "client.New: setup error")
+	}
+	if c.threshold < 0 {
+		c.threshold = c.blockSize / 4
+	}
+	c.url = fmt.Sprintf("%s/counters/%d/reservenumbers/%d/", strings.TrimRight(baseURL, "/"), counterID, c.blockSize)
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	return c, nil
+}
+
+// Next - returns next counter value.
+// If leased values are exhausted, method waits for the next block or context cancellation.
+func (c *Client) Next(ctx context.Context) (int64, error) {
+	for {
+		c.mx.Lock()
+		if c.ctx.Err() != nil {
+			c.mx.Unlock()
+			return 0, errors.New("client.Next: client is closed")
+		}
+		if c.remaining > 0 {
+			value := c.pop()
+			if c.remaining <= c.threshold {
+				c.startRefill()
+			}
+			c.mx.Unlock()
+			return value, nil
+		}
+		r := c.startRefill()
+		c.mx.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-r.done:
+		}
+		if r.err != nil {
+			return 0, errors.WithMessage(r.err, "client.Next: failed to lease values")
+		}
+		// values could be taken by concurrent calls, so try again
+	}
+}
+
+// Close - stops background requests, the rest of leased values is lost.
+func (c *Client) Close() error {
+	if c == nil {
+		return nil
+	}
+	// cancellation under lock guarantees Next does not start refill after it, so waiting is safe
+	c.mx.Lock()
+	c.cancel()
+	c.mx.Unlock()
+	c.wg.Wait()
+	c.mx.Lock()
+	c.ranges, c.remaining = nil, 0
+	c.mx.Unlock()
+	return nil
+}
+
+// pop - takes next value from leased ranges, must be called under lock with non-zero remaining.
+func (c *Client) pop() int64 {
+	r := &c.ranges[0]
+	value := r.First
+	r.First += r.Step
+	r.Count--
+	if r.Count == 0 {
+		c.ranges = c.ranges[1:]
+	}
+	c.remaining--
+	return value
+}
+
+// startRefill - requests the next block in background if it is not requested yet.
+// Must be called under lock.
+func (c *Client) startRefill() *refill {
+	if c.refilling != nil {
+		return c.refilling
+	}
+	r := &refill{done: make(chan struct{})}
+	c.refilling = r
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		ranges, err := c.lease(c.ctx)
+		c.mx.Lock()
+		for _, vr := range ranges {
+			if vr.Count > 0 {
+				c.ranges = append(c.ranges, vr)
+				c.remaining += vr.Count
+			}
+		}
+		c.refilling = nil
+		r.err = err
+		c.mx.Unlock()
+		close(r.done)
+	}()
+	return r
+}
+
+// lease - requests block of values, repeats the request on temporary failures.
+func (c *Client) lease(ctx context.Context) ([]valueRange, error) {
+	delay := c.retryDelay
+	for attempt := 0; ; attempt++ {
+		ranges, err := c.reserve(ctx)
+		if err == nil {
+			return ranges, nil
+		}
+		if e, ok := err.(*Error); (ok && !e.temporary()) || attempt >= c.retries || ctx.Err() != nil {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// reserve - makes single request to reserve block of values.
+func (c *Client) reserve(ctx context.Context) ([]valueRange, error) {
+	req, err := http.NewRequest(http.MethodPost, c.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body := struct {
+		Result *struct {
+			Ranges []valueRange `json:"ranges"`
+		} `json:"result"`
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}{}
+	decodeErr := json.NewDecoder(resp.Body).Decode(&body)
+	if resp.StatusCode != http.StatusOK {
+		e := &Error{Status: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+		if decodeErr == nil && body.Error != nil {
+			e.Message = body.Error.Message
+		}
+		return nil, e
+	}
+	if decodeErr != nil {
+		return nil, errors.Wrap(decodeErr, "malformed response")
+	}
+	if body.Result == nil {
+		return nil, errors.New("malformed response, result is missed")
+	}
+	return body.Result.Ranges, nil
+}
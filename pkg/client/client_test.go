@@ -0,0 +1,253 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wtask-go/auracounter/internal/counter"
+	"github.com/wtask-go/auracounter/internal/counter/datastore/memory"
+	"github.com/wtask-go/auracounter/internal/httpcore/rest"
+)
+
+// newServer - starts aurasrv REST API with in-memory storage.
+func newServer(t *testing.T, counterID int, settings *counter.Settings) *httptest.Server {
+	service, err := counter.NewCyclicCounterService(memory.NewStorage().Repository())
+	if err != nil {
+		t.Fatalf("Unable to build counter service: %v", err)
+	}
+	if _, apiErr := service.SetCounterSettings(counterID, settings.Increment, settings.Lower, settings.Upper, 0); apiErr != nil {
+		t.Fatalf("Unable to set counter settings: %v", apiErr)
+	}
+	return httptest.NewServer(rest.NewCounterHandler("/counter/v1/", service, nil))
+}
+
+func TestNew(t *testing.T) {
+	cases := []struct {
+		signature      string
+		build          func() (*Client, error)
+		mustSuccessful bool
+	}{
+		{"New(\"\", 1)", func() (*Client, error) { return New("", 1) }, false},
+		{"New(url, 0)", func() (*Client, error) { return New("http://127.0.0.1/", 0) }, false},
+		{"New(url, 1)", func() (*Client, error) { return New("http://127.0.0.1/", 1) }, true},
+		{
+			"New(url, 1, WithBlockSize(0))",
+			func() (*Client, error) { return New("http://127.0.0.1/", 1, WithBlockSize(0)) },
+			false,
+		},
+		{
+			"New(url, 1, WithRefillThreshold(-1))",
+			func() (*Client, error) { return New("http://127.0.0.1/", 1, WithRefillThreshold(-1)) },
+			false,
+		},
+		{
+			"New(url, 1, WithRetry(-1, 0))",
+			func() (*Client, error) { return New("http://127.0.0.1/", 1, WithRetry(-1, 0)) },
+			false,
+		},
+		{
+			"New(url, 1, WithHTTPClient(nil))",
+			func() (*Client, error) { return New("http://127.0.0.1/", 1, WithHTTPClient(nil)) },
+			false,
+		},
+		{
+			"New(url, 1, WithBlockSize(10), WithRefillThreshold(0), WithRetry(0, 0))",
+			func() (*Client, error) {
+				return New("http://127.0.0.1/", 1, WithBlockSize(10), WithRefillThreshold(0), WithRetry(0, 0))
+			},
+			true,
+		},
+	}
+
+	for _, c := range cases {
+		client, err := c.build()
+		if c.mustSuccessful && (err != nil || client == nil) {
+			t.Errorf("%s was expected to be successful but error occurred: %v", c.signature, err)
+		}
+		if !c.mustSuccessful && (err == nil || client != nil) {
+			t.Errorf("%s was expected to be failed, but not", c.signature)
+		}
+		client.Close()
+	}
+}
+
+func TestClient_Next(t *testing.T) {
+	settings := &counter.Settings{Increment: 3, Lower: 0, Upper: 20}
+	server := newServer(t, 1, settings)
+	defer server.Close()
+
+	client, err := New(server.URL+"/counter/v1/", 1, WithBlockSize(4))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer client.Close()
+
+	// values are handed out in the same order as the counter is increased, including wrap
+	expected := int64(0)
+	for i := 0; i < 30; i++ {
+		expected, _ = settings.Next(expected)
+		value, err := client.Next(context.Background())
+		if err != nil {
+			t.Fatalf("Next(): unexpected error: %v", err)
+		}
+		if value != expected {
+			t.Errorf("Next(): expected %d, got %d", expected, value)
+		}
+	}
+}
+
+func TestClient_ConcurrentNext(t *testing.T) {
+	server := newServer(t, 1, &counter.Settings{Increment: 1, Lower: 0, Upper: 100000})
+	defer server.Close()
+
+	// two clients lease blocks of the same counter
+	clients := make([]*Client, 2)
+	for i := range clients {
+		client, err := New(server.URL+"/counter/v1/", 1, WithBlockSize(7), WithRefillThreshold(2))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer client.Close()
+		clients[i] = client
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mx     sync.Mutex
+		values = map[int64]int{}
+	)
+	for w := 0; w < 10; w++ {
+		wg.Add(1)
+		go func(client *Client) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				value, err := client.Next(context.Background())
+				if err != nil {
+					t.Errorf("Next(): unexpected error: %v", err)
+					return
+				}
+				mx.Lock()
+				values[value]++
+				mx.Unlock()
+			}
+		}(clients[w%2])
+	}
+	wg.Wait()
+
+	if len(values) != 500 {
+		t.Errorf("Expected %d distinct values, got %d", 500, len(values))
+	}
+	for value, n := range values {
+		if n > 1 {
+			t.Errorf("Value %d was handed out %d times", value, n)
+		}
+	}
+}
+
+func TestClient_Retry(t *testing.T) {
+	server := newServer(t, 1, &counter.Settings{Increment: 1, Lower: 0, Upper: 100})
+	defer server.Close()
+
+	var calls int32
+	failing := func(failures int32, status int) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&calls, 1) <= failures {
+				w.WriteHeader(status)
+				w.Write([]byte(`{"error":{"message":"failure"}}`))
+				return
+			}
+			server.Config.Handler.ServeHTTP(w, r)
+		}))
+	}
+
+	cases := []struct {
+		failures int32
+		status   int
+		retries  int
+		success  bool
+		calls    int32
+	}{
+		{2, http.StatusServiceUnavailable, 2, true, 3},
+		{2, http.StatusInternalServerError, 2, true, 3},
+		{3, http.StatusServiceUnavailable, 2, false, 3},
+		{1, http.StatusBadRequest, 2, false, 1},
+	}
+
+	for _, c := range cases {
+		atomic.StoreInt32(&calls, 0)
+		proxy := failing(c.failures, c.status)
+		client, err := New(proxy.URL+"/counter/v1/", 1, WithRetry(c.retries, time.Millisecond))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		_, err = client.Next(context.Background())
+		switch {
+		case c.success && err != nil:
+			t.Errorf("%d x %d status: unexpected error: %v", c.failures, c.status, err)
+		case !c.success && err == nil:
+			t.Errorf("%d x %d status: expected error, got nothing", c.failures, c.status)
+		}
+		if n := atomic.LoadInt32(&calls); n != c.calls {
+			t.Errorf("%d x %d status: expected %d requests, got %d", c.failures, c.status, c.calls, n)
+		}
+		client.Close()
+		proxy.Close()
+	}
+}
+
+func TestClient_NextCancel(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client, err := New(server.URL, 1, WithRetry(0, 0))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.Next(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Next(): expected %v, got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestClient_CloseConcurrentNext(t *testing.T) {
+	server := newServer(t, 1, &counter.Settings{Increment: 1, Lower: 0, Upper: 100000})
+	defer server.Close()
+
+	for i := 0; i < 20; i++ {
+		client, err := New(server.URL+"/counter/v1/", 1, WithBlockSize(2), WithRefillThreshold(1))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		var wg sync.WaitGroup
+		for w := 0; w < 4; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					if _, err := client.Next(context.Background()); err != nil {
+						return
+					}
+				}
+			}()
+		}
+		time.Sleep(time.Millisecond)
+		client.Close()
+		wg.Wait()
+		if _, err := client.Next(context.Background()); err == nil {
+			t.Fatal("Next(): expected error after Close()")
+		}
+	}
+}
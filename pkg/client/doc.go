@@ -0,0 +1,12 @@
+/*
+Package client implements Go client of aurasrv REST API, which hands out counter values locally.
+
+Client leases blocks of values from the server (see `reservenumbers` route)
+and refills them in background before the block is exhausted,
+so most of calls of `Client.Next()` do not make any network round trip.
+
+Values are handed out in the same order as the server reserved them,
+including wrapping of the counter. Leased values which were not handed out
+before the client is closed are lost, so they will not be used until the counter wraps around.
+*/
+package client
@@ -0,0 +1,92 @@
+package client
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type clientOption func() (func(*Client), error)
+
+// failedOption - helper to expose error from option builder
+func failedOption(err error) clientOption {
+	return func() (func(*Client), error) {
+		return nil, err
+	}
+}
+
+// properOption - helper to expose setter from option builder
+func properOption(setter func(*Client)) clientOption {
+	return func() (func(*Client), error) {
+		return setter, nil
+	}
+}
+
+// setup - applies options, but stops on first error
+func (c *Client) setup(options ...clientOption) error {
+	if c == nil {
+		return nil
+	}
+	for _, option := range options {
+		if option == nil {
+			continue
+		}
+		setter, err := option()
+		if err != nil {
+			return err
+		}
+		if setter != nil {
+			setter(c)
+		}
+	}
+	return nil
+}
+
+// WithHTTPClient - sets custom http client to make requests, by default client with 10 seconds timeout is used.
+func WithHTTPClient(hc *http.Client) clientOption {
+	if hc == nil {
+		return failedOption(errors.New("client.WithHTTPClient: unable to use nil http client"))
+	}
+	return properOption(func(c *Client) {
+		c.http = hc
+	})
+}
+
+// WithBlockSize - sets number of values leased at once, 100 by default.
+// Server limits max block size, currently it is 10000.
+func WithBlockSize(size int) clientOption {
+	if size < 1 {
+		return failedOption(errors.Errorf("client.WithBlockSize: invalid block size (%d)", size))
+	}
+	return properOption(func(c *Client) {
+		c.blockSize = size
+	})
+}
+
+// WithRefillThreshold - sets number of remaining values when the next block is requested in background.
+// By default it is a quarter of block size. Zero threshold means the next block is requested on exhaustion only.
+func WithRefillThreshold(threshold int) clientOption {
+	if threshold < 0 {
+		return failedOption(errors.Errorf("client.WithRefillThreshold: negative threshold (%d)", threshold))
+	}
+	return properOption(func(c *Client) {
+		c.threshold = threshold
+	})
+}
+
+// WithRetry - sets how many times a failed request will be repeated and the delay before first repetition,
+// every next delay is doubled. By default the request is repeated 3 times starting with 100ms delay.
+// Only server failures (500, 503 statuses) and network errors are repeated.
+func WithRetry(retries int, delay time.Duration) clientOption {
+	if retries < 0 {
+		return failedOption(errors.Errorf("client.WithRetry: negative number of retries (%d)", retries))
+	}
+	if delay < 0 {
+		return failedOption(errors.Errorf("client.WithRetry: negative delay (%s)", delay))
+	}
+	return properOption(func(c *Client) {
+		c.retries = retries
+		c.retryDelay = delay
+	})
+}
@@ -0,0 +1,34 @@
+package logging
+
+import "fmt"
+
+// Printer - the smallest set of methods to log two types of messages,
+// it is expected by packages which log optionally, Facade implements Printer.
+type Printer interface {
+	Error(v ...interface{})
+	Info(v ...interface{})
+}
+
+// PrintInfo - logs informational message, nothing is logged when printer is nil.
+func PrintInfo(p Printer, v ...interface{}) {
+	if p == nil {
+		return
+	}
+	p.Info(v...)
+}
+
+// PrintError - logs error message, nothing is logged when printer is nil.
+func PrintError(p Printer, v ...interface{}) {
+	if p == nil {
+		return
+	}
+	p.Error(v...)
+}
+
+// QuoteError - formats the error with +v specifier and returns result in quotes, nil error is formatted as empty string.
+func QuoteError(e error) string {
+	if e == nil {
+		return ""
+	}
+	return fmt.Sprintf("%q", fmt.Sprintf("%+v", e))
+}
@@ -0,0 +1,31 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPrinter(t *testing.T) {
+	// nil printer discards messages
+	PrintInfo(nil, "info")
+	PrintError(nil, "error")
+
+	buf := &bytes.Buffer{}
+	l := NewBuffer(buf)
+	PrintInfo(l, "info", "message")
+	PrintError(l, "error", "message")
+	l.Close()
+	output := buf.String()
+	if !strings.Contains(output, "info message") || !strings.Contains(output, "error message") {
+		t.Errorf("Unexpected output: %q", output)
+	}
+
+	if QuoteError(nil) != "" {
+		t.Errorf("Expected empty string for nil error, got %q", QuoteError(nil))
+	}
+	if q := QuoteError(errors.New("failed \"call\"")); q != `"failed \"call\""` {
+		t.Errorf("Unexpected quoted error: %s", q)
+	}
+}